@@ -1,85 +1,73 @@
 package cgroup
 
 import (
+	"os"
+	"path"
+
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	subSysV1 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v1"
 	subSysV2 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v2"
-	log "github.com/sirupsen/logrus"
 )
 
 /*
-把所有不同的 subsystem 中的 cgroup 管理起来，并与容器建立关系
+Manager 把所有不同的 subsystem 中的 cgroup 管理起来，并与容器建立关系
+cgroup v1 和 v2 分别实现，调用方不需要关心宿主机使用的是哪个版本
 */
-type CgroupManager struct {
-	Path     string                    //  cgroup在hierarchy中的路径 相当于创建的cgroup目录相对于root cgroup目录的路径
-	Resource *subsystem.ResourceConfig // 资源配置
+type Manager interface {
+	// 将进程加入到 cgroup 中
+	Apply(pid int) error
+	// 设置 cgroup 的资源限制，resConf 中为零值的字段不修改
+	Set(resConf *subsystem.ResourceConfig) error
+	// 删除 cgroup，cgroup 不存在时不返回错误
+	Destroy() error
+	// 读取 cgroup 的统计信息
+	Stats() (*subsystem.Stats, error)
+	// 冻结或解冻 cgroup 中的所有进程
+	Freeze(frozen bool) error
+	// 获取 cgroup 中的所有进程
+	GetPids() ([]int, error)
+	// 获取 cgroup 在某个 subsystem 中的绝对路径，v2 只有一个 hierarchy，忽略 subsystem
+	Path(subsystem string) string
 }
 
-// 工厂函数
-func NewCgroupManager(path string) *CgroupManager {
-	return &CgroupManager{
-		Path: path,
+// 工厂函数，根据宿主机的 cgroup 模式创建 Manager
+// path 为 cgroup 相对于 dockergsh 目录的路径
+func NewCgroupManager(path string) Manager {
+	if GetMode() == Unified {
+		return &managerV2{path: path}
 	}
+	return &managerV1{path: path}
 }
 
-// 将进程加入到 cgroup v1 的每个 cgroup 中
-func (cm *CgroupManager) ApplyV1(pid int) error {
-	for _, subSystemIns := range subSysV1.SubsystemIns {
-		if err := subSystemIns.Apply(cm.Path, pid); err != nil {
-			return err
+// ListCgroups 列出 dockergsh 目录下所有容器的 cgroup 路径，v1 中合并所有 subsystem 中的 cgroup
+func ListCgroups() ([]string, error) {
+	var parentDirs []string
+	if GetMode() == Unified {
+		parentDirs = append(parentDirs, subSysV2.ParentPath())
+	} else {
+		for _, subSystemIns := range subSysV1.SubsystemIns {
+			if mountPoint := subSysV1.FindCgroupMountPoint(subSystemIns.Name()); mountPoint != "" {
+				parentDirs = append(parentDirs, path.Join(mountPoint, "dockergsh"))
+			}
 		}
 	}
-	return nil
-}
 
-// 设置各个挂载 subsystem 的 cgroup 的资源限制
-func (cm *CgroupManager) SetV1(resConf *subsystem.ResourceConfig) error {
-	for _, subSystemIns := range subSysV1.SubsystemIns {
-		if err := subSystemIns.Set(cm.Path, resConf); err != nil {
-			return err
+	seen := make(map[string]bool)
+	var cgroupPaths []string
+	for _, parentDir := range parentDirs {
+		entries, err := os.ReadDir(parentDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
 		}
-	}
-	return nil
-}
-
-// 释放各个 subsystem 挂在中的 cgroup
-func (cm *CgroupManager) DestroyV1() error {
-	for _, subsystemIns := range subSysV1.SubsystemIns {
-		if err := subsystemIns.Remove(cm.Path); err != nil {
-			log.Warnf("remove cgroup fail %v", err)
-			return err
-		}
-	}
-	return nil
-}
-
-// 将进程加入到 cgroup v2 的每个 cgroup 中
-func (cm *CgroupManager) ApplyV2(pid int) error {
-	for _, subSystemIns := range subSysV2.SubsystemIns {
-		if err := subSystemIns.Apply(cm.Path, pid); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// 设置各个挂载 subsystem 的 cgroup 的资源限制
-func (cm *CgroupManager) SetV2(resConf *subsystem.ResourceConfig) error {
-	for _, subSystemIns := range subSysV2.SubsystemIns {
-		if err := subSystemIns.Set(cm.Path, resConf); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// 释放各个 subsystem 挂在中的 cgroup
-func (cm *CgroupManager) DestroyV2() error {
-	for _, subsystemIns := range subSysV2.SubsystemIns {
-		if err := subsystemIns.Remove(cm.Path); err != nil {
-			log.Warnf("remove cgroup fail %v", err)
-			return err
+		for _, entry := range entries {
+			if entry.IsDir() && !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				cgroupPaths = append(cgroupPaths, entry.Name())
+			}
 		}
 	}
-	return nil
+	return cgroupPaths, nil
 }
@@ -0,0 +1,378 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	subSysV2 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v2"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+)
+
+const testCgroupPath = "test-container"
+
+func mustRAMInBytes(t *testing.T, size string) int64 {
+	bytes, err := units.RAMInBytes(size)
+	if err != nil {
+		t.Fatalf("RAMInBytes(%q) error %v", size, err)
+	}
+	return bytes
+}
+
+// 在临时目录中模拟 v1 的 cgroupfs，每个 subsystem 一个 hierarchy
+// 新建 cgroup 时内核会创建空的 cpuset.cpus 和 cpuset.mems，这里提前创建好
+func fakeCgroupV1(t *testing.T) string {
+	root := t.TempDir()
+	for _, name := range []string{"cpu", "cpuacct", "cpuset", "memory", "devices", "freezer", "pids", "blkio"} {
+		if err := os.MkdirAll(path.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cpusetDirs := map[string]string{
+		path.Join(root, "cpuset"):                              "0-3",
+		path.Join(root, "cpuset", "dockergsh"):                 "",
+		path.Join(root, "cpuset", "dockergsh", testCgroupPath): "",
+	}
+	for dir, cpus := range cpusetDirs {
+		mems := ""
+		if cpus != "" {
+			mems = "0"
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "cpuset.cpus"), []byte(cpus), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "cpuset.mems"), []byte(mems), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func assertFiles(t *testing.T, dir string, expected map[string]string) {
+	t.Helper()
+	for file, want := range expected {
+		content, err := os.ReadFile(path.Join(dir, file))
+		if err != nil {
+			t.Errorf("read %s error %v", file, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(content)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+// 同一份 ResourceConfig 在 v1 和 v2 中写入的文件不同，但效果要一致
+func TestSetResources(t *testing.T) {
+	quota, period := subsystem.CpusToQuota(1.5)
+
+	tests := []struct {
+		name    string
+		resConf *subsystem.ResourceConfig
+		v1      map[string]string // 相对于 v1 cgroupfs 根目录，<subsystem>/<文件>
+		v2      map[string]string // 相对于容器的 v2 cgroup 目录
+	}{
+		{
+			name:    "cpus",
+			resConf: &subsystem.ResourceConfig{CpuQuota: quota, CpuPeriod: period},
+			v1:      map[string]string{"cpu/cpu.cfs_quota_us": "150000", "cpu/cpu.cfs_period_us": "100000"},
+			v2:      map[string]string{"cpu.max": "150000 100000"},
+		},
+		{
+			name:    "cpu quota and period",
+			resConf: &subsystem.ResourceConfig{CpuQuota: 50000, CpuPeriod: 200000},
+			v1:      map[string]string{"cpu/cpu.cfs_quota_us": "50000", "cpu/cpu.cfs_period_us": "200000"},
+			v2:      map[string]string{"cpu.max": "50000 200000"},
+		},
+		{
+			name:    "unlimited cpu quota",
+			resConf: &subsystem.ResourceConfig{CpuQuota: -1},
+			v1:      map[string]string{"cpu/cpu.cfs_quota_us": "-1"},
+			v2:      map[string]string{"cpu.max": "max"},
+		},
+		{
+			name:    "default cpu shares",
+			resConf: &subsystem.ResourceConfig{CpuShares: 1024},
+			v1:      map[string]string{"cpu/cpu.shares": "1024"},
+			v2:      map[string]string{"cpu.weight": "39"},
+		},
+		{
+			name:    "maximum cpu shares",
+			resConf: &subsystem.ResourceConfig{CpuShares: 262144},
+			v1:      map[string]string{"cpu/cpu.shares": "262144"},
+			v2:      map[string]string{"cpu.weight": "10000"},
+		},
+		{
+			name:    "cpuset",
+			resConf: &subsystem.ResourceConfig{CpusetCpus: "0-1"},
+			v1:      map[string]string{"cpuset/cpuset.cpus": "0-1", "cpuset/cpuset.mems": "0"},
+			v2:      map[string]string{"cpuset.cpus": "0-1"},
+		},
+		{
+			name:    "memory",
+			resConf: &subsystem.ResourceConfig{Memory: mustRAMInBytes(t, "512m")},
+			v1:      map[string]string{"memory/memory.limit_in_bytes": "536870912"},
+			v2:      map[string]string{"memory.max": "536870912"},
+		},
+		{
+			name:    "memory oom kill group",
+			resConf: &subsystem.ResourceConfig{Memory: mustRAMInBytes(t, "512m"), OomKillGroup: true},
+			v1:      map[string]string{"memory/memory.limit_in_bytes": "536870912"},
+			v2:      map[string]string{"memory.max": "536870912", "memory.oom.group": "1"},
+		},
+		{
+			name:    "memory and swap",
+			resConf: &subsystem.ResourceConfig{Memory: mustRAMInBytes(t, "512m"), MemorySwap: mustRAMInBytes(t, "1g")},
+			v1:      map[string]string{"memory/memory.limit_in_bytes": "536870912", "memory/memory.memsw.limit_in_bytes": "1073741824"},
+			v2:      map[string]string{"memory.max": "536870912", "memory.swap.max": "536870912"},
+		},
+		{
+			name:    "unlimited swap",
+			resConf: &subsystem.ResourceConfig{Memory: mustRAMInBytes(t, "100MB"), MemorySwap: -1},
+			v1:      map[string]string{"memory/memory.limit_in_bytes": "104857600", "memory/memory.memsw.limit_in_bytes": "-1"},
+			v2:      map[string]string{"memory.max": "104857600", "memory.swap.max": "max"},
+		},
+		{
+			name:    "memory reservation",
+			resConf: &subsystem.ResourceConfig{MemoryReservation: mustRAMInBytes(t, "256m")},
+			v1:      map[string]string{"memory/memory.soft_limit_in_bytes": "268435456"},
+			v2:      map[string]string{"memory.low": "268435456"},
+		},
+		{
+			name:    "pids limit",
+			resConf: &subsystem.ResourceConfig{PidsLimit: 100},
+			v1:      map[string]string{"pids/pids.max": "100"},
+			v2:      map[string]string{"pids.max": "100"},
+		},
+		{
+			name:    "unlimited pids",
+			resConf: &subsystem.ResourceConfig{PidsLimit: -1},
+			v1:      map[string]string{"pids/pids.max": "max"},
+			v2:      map[string]string{"pids.max": "max"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+"/v1", func(t *testing.T) {
+			root := fakeCgroupV1(t)
+			SetRoot(root, Legacy)
+
+			if err := NewCgroupManager(testCgroupPath).Set(test.resConf); err != nil {
+				t.Fatalf("Set error %v", err)
+			}
+			expected := make(map[string]string, len(test.v1))
+			for file, want := range test.v1 {
+				// cpu/cpu.shares -> cpu/dockergsh/<cgroup>/cpu.shares
+				subsystemName, name := path.Split(file)
+				expected[path.Join(subsystemName, "dockergsh", testCgroupPath, name)] = want
+			}
+			assertFiles(t, root, expected)
+		})
+
+		t.Run(test.name+"/v2", func(t *testing.T) {
+			root := t.TempDir()
+			SetRoot(root, Unified)
+
+			if err := NewCgroupManager(testCgroupPath).Set(test.resConf); err != nil {
+				t.Fatalf("Set error %v", err)
+			}
+			assertFiles(t, path.Join(root, "dockergsh", testCgroupPath), test.v2)
+			// memory.oom.group 只有指定了 --oom-kill-group 时才写入
+			if _, err := os.Stat(path.Join(root, "dockergsh", testCgroupPath, "memory.oom.group")); err == nil && !test.resConf.OomKillGroup {
+				t.Errorf("memory.oom.group should not be written")
+			}
+		})
+	}
+}
+
+// 在模拟的 cgroupfs 中走一遍容器 cgroup 的生命周期：加入进程、读取进程、冻结、删除
+func TestManagerLifecycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  Mode
+		setup func(t *testing.T) string
+		// 内核在 cgroup 中自动生成的文件，模拟的 cgroupfs 中需要手动写入
+		kernelFiles map[string]string
+	}{
+		{
+			name:  "v1",
+			mode:  Legacy,
+			setup: fakeCgroupV1,
+		},
+		{
+			name:  "v2",
+			mode:  Unified,
+			setup: func(t *testing.T) string { return t.TempDir() },
+			kernelFiles: map[string]string{
+				"cgroup.events": "populated 1\nfrozen 1\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetRoot(test.setup(t), test.mode)
+			manager := NewCgroupManager(testCgroupPath)
+
+			if err := manager.Set(&subsystem.ResourceConfig{}); err != nil {
+				t.Fatalf("Set error %v", err)
+			}
+			if err := manager.Apply(1234); err != nil {
+				t.Fatalf("Apply error %v", err)
+			}
+
+			// v1 中第一个 subsystem 为 cpu，v2 中只有一个目录
+			cgroupDir := manager.Path("cpu")
+			for file, content := range test.kernelFiles {
+				if err := os.WriteFile(path.Join(manager.Path("freezer"), file), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(path.Join(cgroupDir, "cgroup.procs"), []byte("1234\n1235\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pids, err := manager.GetPids()
+			if err != nil {
+				t.Fatalf("GetPids error %v", err)
+			}
+			if len(pids) != 2 || pids[0] != 1234 || pids[1] != 1235 {
+				t.Errorf("GetPids = %v, want [1234 1235]", pids)
+			}
+
+			if err := manager.Freeze(true); err != nil {
+				t.Fatalf("Freeze error %v", err)
+			}
+
+			if err := manager.Destroy(); err != nil {
+				t.Fatalf("Destroy error %v", err)
+			}
+			if _, err := os.Stat(cgroupDir); !os.IsNotExist(err) {
+				t.Errorf("cgroup %s still exists after Destroy", cgroupDir)
+			}
+			// 重复删除不返回错误
+			if err := manager.Destroy(); err != nil {
+				t.Errorf("Destroy again error %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectMode(t *testing.T) {
+	// 普通目录既不是 cgroup2 也没有挂载 unified
+	if mode := DetectMode(t.TempDir()); mode != Legacy {
+		t.Errorf("DetectMode(tempdir) = %s, want legacy", mode)
+	}
+	t.Logf("host cgroup mode: %s", DetectMode(DefaultRoot))
+}
+
+// 只开启 cgroup.controllers 中列出的控制器
+func TestEnableAdvertisedControllers(t *testing.T) {
+	root := t.TempDir()
+	SetRoot(root, Unified)
+	if err := os.WriteFile(path.Join(root, "cgroup.controllers"), []byte("cpu memory pids\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewCgroupManager(testCgroupPath).Apply(1234); err != nil {
+		t.Fatalf("Apply error %v", err)
+	}
+	assertFiles(t, root, map[string]string{
+		"cgroup.subtree_control":                               "+cpu +memory +pids",
+		path.Join("dockergsh", testCgroupPath, "cgroup.procs"): "1234",
+	})
+}
+
+// 普通用户在 systemd 委派的 user@<uid>.service 中时，dockergsh 目录放在 user@<uid>.service 下
+func TestDelegatedParent(t *testing.T) {
+	service := fmt.Sprintf("user@%d.service", os.Geteuid())
+	userService := path.Join("user.slice", fmt.Sprintf("user-%d.slice", os.Geteuid()), service)
+
+	tests := []struct {
+		name       string
+		procCgroup string
+		dirs       []string // 模拟的 cgroupfs 中已经存在的 cgroup
+		wantParent string   // 相对于 cgroupfs 根目录
+	}{
+		{
+			name:       "root cgroup",
+			procCgroup: "0::/\n",
+			wantParent: "dockergsh",
+		},
+		{
+			name:       "user service",
+			procCgroup: "0::/" + userService + "/app.slice/vte-spawn.scope\n",
+			dirs:       []string{userService},
+			wantParent: path.Join(userService, "dockergsh"),
+		},
+		{
+			name:       "hybrid",
+			procCgroup: "1:name=systemd:/" + userService + "/app.slice/vte-spawn.scope\n0::/" + userService + "/session.slice/dbus.service\n",
+			dirs:       []string{userService},
+			wantParent: path.Join(userService, "dockergsh"),
+		},
+		// 其他用户的 user@<uid>.service 不能修改，sudo 运行时仍然使用根 cgroup
+		{
+			name:       "other user service",
+			procCgroup: "0::/user.slice/user-4242.slice/user@4242.service/app.slice/vte-spawn.scope\n",
+			dirs:       []string{"user.slice/user-4242.slice/user@4242.service"},
+			wantParent: "dockergsh",
+		},
+		{
+			name:       "session scope",
+			procCgroup: "0::/user.slice/user-1000.slice/session-2.scope\n",
+			dirs:       []string{"user.slice/user-1000.slice/session-2.scope"},
+			wantParent: "dockergsh",
+		},
+		// 没有 cgroup namespace 时记录的是宿主机上的路径
+		{
+			name:       "missing service dir",
+			procCgroup: "0::/" + userService + "/app.slice/vte-spawn.scope\n",
+			wantParent: "dockergsh",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			SetRoot(root, Unified)
+			procCgroup := path.Join(t.TempDir(), "cgroup")
+			if err := os.WriteFile(procCgroup, []byte(test.procCgroup), 0644); err != nil {
+				t.Fatal(err)
+			}
+			oldProcCgroup := subSysV2.ProcCgroupFile
+			subSysV2.ProcCgroupFile = procCgroup
+			t.Cleanup(func() { subSysV2.ProcCgroupFile = oldProcCgroup })
+			for _, dir := range test.dirs {
+				if err := os.MkdirAll(path.Join(root, dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manager := NewCgroupManager(testCgroupPath)
+			if err := manager.Apply(1234); err != nil {
+				t.Fatalf("Apply error %v", err)
+			}
+			wantDir := path.Join(root, test.wantParent, testCgroupPath)
+			if got := manager.Path("cpu"); got != wantDir {
+				t.Errorf("Path = %s, want %s", got, wantDir)
+			}
+			assertFiles(t, wantDir, map[string]string{"cgroup.procs": "1234"})
+			// 控制器在 dockergsh 的上一级和 dockergsh 中开启
+			assertFiles(t, path.Dir(path.Join(root, test.wantParent)), map[string]string{"cgroup.subtree_control": "+cpu +cpuset +memory +io +pids"})
+
+			cgroups, err := ListCgroups()
+			if err != nil {
+				t.Fatalf("ListCgroups error %v", err)
+			}
+			if len(cgroups) != 1 || cgroups[0] != testCgroupPath {
+				t.Errorf("ListCgroups = %v, want [%s]", cgroups, testCgroupPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cgroup
+
+import (
+	"path"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	subSysV1 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// cgroup v1 中每个 subsystem 是一个单独的 hierarchy，容器在每个 hierarchy 中都有一个 cgroup
+type managerV1 struct {
+	path string // cgroup 在 hierarchy 中相对于 dockergsh 目录的路径
+}
+
+// 将进程加入到 cgroup v1 的每个 cgroup 中
+func (m *managerV1) Apply(pid int) error {
+	for _, subSystemIns := range subSysV1.SubsystemIns {
+		if err := subSystemIns.Apply(m.path, pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 设置各个挂载 subsystem 的 cgroup 的资源限制
+func (m *managerV1) Set(resConf *subsystem.ResourceConfig) error {
+	for _, subSystemIns := range subSysV1.SubsystemIns {
+		if err := subSystemIns.Set(m.path, resConf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 释放各个 subsystem 挂载中的 cgroup，某个 subsystem 删除失败时继续删除其他的
+func (m *managerV1) Destroy() error {
+	var lastErr error
+	for _, subSystemIns := range subSysV1.SubsystemIns {
+		cgroupDir := m.Path(subSystemIns.Name())
+		if cgroupDir == "" {
+			continue
+		}
+		if err := subsystem.RemoveCgroupDir(cgroupDir); err != nil {
+			log.Warnf("remove cgroup fail %v", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// 读取 cgroup v1 中各个 subsystem 的统计信息
+func (m *managerV1) Stats() (*subsystem.Stats, error) {
+	stats := &subsystem.Stats{}
+	for _, subSystemIns := range subSysV1.SubsystemIns {
+		if err := subSystemIns.Stats(m.path, stats); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// 冻结或解冻 cgroup v1 中的所有进程
+func (m *managerV1) Freeze(frozen bool) error {
+	freezer := &subSysV1.FreezerSubSystem{}
+	return freezer.Freeze(m.path, frozen)
+}
+
+// 获取 cgroup v1 中的所有进程，容器进程加入了所有的 subsystem，读取其中任意一个即可
+func (m *managerV1) GetPids() ([]int, error) {
+	cgroupDir, err := subSysV1.GetCgroupPath(subSysV1.SubsystemIns[0].Name(), m.path, false)
+	if err != nil {
+		return nil, err
+	}
+	return subsystem.ReadCgroupProcs(cgroupDir)
+}
+
+// subsystem 没有挂载时返回空字符串
+func (m *managerV1) Path(subsystemName string) string {
+	mountPoint := subSysV1.FindCgroupMountPoint(subsystemName)
+	if mountPoint == "" {
+		return ""
+	}
+	return path.Join(mountPoint, "dockergsh", m.path)
+}
@@ -0,0 +1,72 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	subSysV2 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v2"
+)
+
+// cgroup v2 只有一个 hierarchy，容器只有一个 cgroup 目录，所有控制器的配置文件都在其中
+type managerV2 struct {
+	path string // cgroup 相对于 dockergsh 目录的路径
+}
+
+// 将进程加入到 cgroup v2 中，只需要写一次 cgroup.procs
+func (m *managerV2) Apply(pid int) error {
+	cgroupDir, err := subSysV2.GetCgroupPath(m.path, true)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path.Join(cgroupDir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v", err)
+	}
+	return nil
+}
+
+// 设置各个控制器的资源限制
+func (m *managerV2) Set(resConf *subsystem.ResourceConfig) error {
+	for _, subSystemIns := range subSysV2.SubsystemIns {
+		if err := subSystemIns.Set(m.path, resConf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除容器的 cgroup 目录
+func (m *managerV2) Destroy() error {
+	return subsystem.RemoveCgroupDir(m.Path(""))
+}
+
+// 读取 cgroup v2 中各个控制器的统计信息
+func (m *managerV2) Stats() (*subsystem.Stats, error) {
+	stats := &subsystem.Stats{}
+	for _, subSystemIns := range subSysV2.SubsystemIns {
+		if err := subSystemIns.Stats(m.path, stats); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// 冻结或解冻 cgroup v2 中的所有进程
+func (m *managerV2) Freeze(frozen bool) error {
+	return subSysV2.Freeze(m.path, frozen)
+}
+
+// 获取 cgroup v2 中的所有进程
+func (m *managerV2) GetPids() ([]int, error) {
+	cgroupDir, err := subSysV2.GetCgroupPath(m.path, false)
+	if err != nil {
+		return nil, err
+	}
+	return subsystem.ReadCgroupProcs(cgroupDir)
+}
+
+func (m *managerV2) Path(subsystemName string) string {
+	return path.Join(subSysV2.ParentPath(), m.path)
+}
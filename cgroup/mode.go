@@ -0,0 +1,89 @@
+package cgroup
+
+import (
+	"path"
+	"sync"
+
+	subSysV1 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v1"
+	subSysV2 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v2"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultRoot cgroup 默认的挂载目录
+const DefaultRoot = "/sys/fs/cgroup"
+
+// Mode 宿主机使用 cgroup 的方式
+type Mode int
+
+const (
+	Legacy  Mode = iota // 只挂载了 cgroup v1
+	Hybrid              // 控制器都在 v1 中，另外在 unified 目录挂载了不带控制器的 cgroup v2
+	Unified             // 只挂载了 cgroup v2
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Hybrid:
+		return "hybrid"
+	case Unified:
+		return "unified"
+	default:
+		return "legacy"
+	}
+}
+
+var (
+	mode     Mode
+	modeOnce sync.Once
+)
+
+/*
+DetectMode 根据 root 的文件系统类型判断 cgroup 模式
+- root 本身是 cgroup2 文件系统：unified
+- root 是 tmpfs，其中的 unified 目录是 cgroup2 文件系统：hybrid，控制器仍然在 v1 中
+- 其他情况：legacy
+内核支持 cgroup2（/proc/filesystems 中有 cgroup2）不代表挂载了，因此不能用来判断
+*/
+func DetectMode(root string) Mode {
+	var st unix.Statfs_t
+	if err := unix.Statfs(root, &st); err != nil {
+		log.Warnf("Statfs %s error %v, use cgroup v1", root, err)
+		return Legacy
+	}
+	if st.Type == unix.CGROUP2_SUPER_MAGIC {
+		return Unified
+	}
+	if err := unix.Statfs(path.Join(root, "unified"), &st); err == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+		return Hybrid
+	}
+	return Legacy
+}
+
+// GetMode 获取宿主机的 cgroup 模式，只检测一次
+func GetMode() Mode {
+	modeOnce.Do(func() {
+		mode = DetectMode(DefaultRoot)
+	})
+	return mode
+}
+
+// IsUnified 宿主机是否只使用 cgroup v2
+func IsUnified() bool {
+	return GetMode() == Unified
+}
+
+/*
+SetRoot 指定 cgroup 的挂载目录和模式，不再检测宿主机
+unified 模式下 root 即 v2 的挂载目录，其他模式下 root/<subsystem> 为 v1 各个 subsystem 的挂载目录
+测试时可以指向一个普通目录，在其中模拟 cgroupfs
+*/
+func SetRoot(root string, m Mode) {
+	modeOnce.Do(func() {})
+	mode = m
+	if m == Unified {
+		subSysV2.CgroupRoot = root
+	} else {
+		subSysV1.CgroupRoot = root
+	}
+}
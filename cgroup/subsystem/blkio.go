@@ -0,0 +1,20 @@
+package subsystem
+
+import "fmt"
+
+// ThrottleDevice 块设备的读写限速，对应 v1 blkio.throttle.* 和 v2 io.max 中的一个设备
+type ThrottleDevice struct {
+	Major int64  `json:"major"`
+	Minor int64  `json:"minor"`
+	Rate  uint64 `json:"rate"` // 每秒的字节数或 IO 次数
+}
+
+// String 返回 v1 blkio.throttle.* 的格式，例如 8:0 1048576
+func (td *ThrottleDevice) String() string {
+	return fmt.Sprintf("%d:%d %d", td.Major, td.Minor, td.Rate)
+}
+
+// BlkioWeightToIOWeight 将 v1 的 blkio.weight（10 - 1000）线性转换为 v2 的 io.weight（1 - 10000）
+func BlkioWeightToIOWeight(weight uint16) uint64 {
+	return 1 + (uint64(weight)-10)*9999/990
+}
@@ -0,0 +1,45 @@
+package subsystem
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DeviceRule cgroup 的设备访问规则，对应 v1 devices.allow/devices.deny 中的一行
+type DeviceRule struct {
+	Type        string `json:"type"`        // a 表示所有设备，c 表示字符设备，b 表示块设备
+	Major       int64  `json:"major"`       // 主设备号，-1 表示 *
+	Minor       int64  `json:"minor"`       // 次设备号，-1 表示 *
+	Permissions string `json:"permissions"` // r 读 w 写 m mknod
+	Allow       bool   `json:"allow"`       // 允许还是禁止
+}
+
+// Wildcard 设备号通配符 *
+const Wildcard int64 = -1
+
+// DefaultDeviceRules 容器默认的设备白名单，与 docker 保持一致
+// 允许 mknod 任意设备，但只允许读写 null、zero、full、random、urandom、tty、pts、ptmx
+var DefaultDeviceRules = []*DeviceRule{
+	{Type: "c", Major: Wildcard, Minor: Wildcard, Permissions: "m", Allow: true},
+	{Type: "b", Major: Wildcard, Minor: Wildcard, Permissions: "m", Allow: true},
+	{Type: "c", Major: 1, Minor: 3, Permissions: "rwm", Allow: true},          // /dev/null
+	{Type: "c", Major: 1, Minor: 5, Permissions: "rwm", Allow: true},          // /dev/zero
+	{Type: "c", Major: 1, Minor: 7, Permissions: "rwm", Allow: true},          // /dev/full
+	{Type: "c", Major: 1, Minor: 8, Permissions: "rwm", Allow: true},          // /dev/random
+	{Type: "c", Major: 1, Minor: 9, Permissions: "rwm", Allow: true},          // /dev/urandom
+	{Type: "c", Major: 5, Minor: 0, Permissions: "rwm", Allow: true},          // /dev/tty
+	{Type: "c", Major: 136, Minor: Wildcard, Permissions: "rwm", Allow: true}, // /dev/pts/*
+	{Type: "c", Major: 5, Minor: 2, Permissions: "rwm", Allow: true},          // /dev/ptmx
+}
+
+// String 返回 cgroup v1 devices.allow 的格式，例如 c 1:3 rwm
+func (rule *DeviceRule) String() string {
+	return fmt.Sprintf("%s %s:%s %s", rule.Type, deviceNumber(rule.Major), deviceNumber(rule.Minor), rule.Permissions)
+}
+
+func deviceNumber(number int64) string {
+	if number == Wildcard {
+		return "*"
+	}
+	return strconv.FormatInt(number, 10)
+}
@@ -0,0 +1,78 @@
+package subsystem
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+)
+
+const hugepagesDir = "/sys/kernel/mm/hugepages"
+
+// HugetlbLimit 某种大小的大页内存的使用上限
+type HugetlbLimit struct {
+	PageSize string `json:"page_size"` // 大页的大小，与 cgroup 文件名中的一致，例如 2MB、1GB
+	Limit    uint64 `json:"limit"`     // 字节数
+}
+
+// HugePageSizes 宿主机支持的大页大小，/sys/kernel/mm/hugepages 下每种大小一个目录，例如 hugepages-2048kB
+func HugePageSizes() ([]string, error) {
+	entries, err := os.ReadDir(hugepagesDir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s error %v", hugepagesDir, err)
+	}
+	var pageSizes []string
+	for _, entry := range entries {
+		size := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), "hugepages-"), "kB")
+		kb, err := strconv.ParseUint(size, 10, 64)
+		if err != nil {
+			continue
+		}
+		pageSizes = append(pageSizes, hugePageSizeName(kb))
+	}
+	return pageSizes, nil
+}
+
+// 转换为 cgroup 文件名中使用的格式，2048kB 对应 2MB，1048576kB 对应 1GB
+func hugePageSizeName(kb uint64) string {
+	switch {
+	case kb >= 1<<20:
+		return fmt.Sprintf("%dGB", kb>>20)
+	case kb >= 1<<10:
+		return fmt.Sprintf("%dMB", kb>>10)
+	default:
+		return fmt.Sprintf("%dKB", kb)
+	}
+}
+
+// ParseHugetlbLimit 解析 --hugetlb-limit 参数，格式为 <大页大小>:<上限>，例如 2MB:100m
+func ParseHugetlbLimit(spec string) (*HugetlbLimit, error) {
+	arr := strings.Split(spec, ":")
+	if len(arr) != 2 {
+		return nil, fmt.Errorf("invalid hugetlb limit %s, it should be <page size>:<limit>", spec)
+	}
+
+	pageSizes, err := HugePageSizes()
+	if err != nil {
+		return nil, err
+	}
+	pageSize := strings.ToUpper(arr[0])
+	supported := false
+	for _, size := range pageSizes {
+		if size == pageSize {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("hugetlb page size %s is not supported, supported sizes: %s", arr[0], strings.Join(pageSizes, ", "))
+	}
+
+	limit, err := units.RAMInBytes(arr[1])
+	if err != nil {
+		return nil, err
+	}
+	return &HugetlbLimit{PageSize: pageSize, Limit: uint64(limit)}, nil
+}
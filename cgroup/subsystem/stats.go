@@ -0,0 +1,68 @@
+package subsystem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Stats 容器 cgroup 的统计信息，v1 和 v2 的各个 subsystem 统一填充到这个结构中
+type Stats struct {
+	CpuUsage    uint64 `json:"cpu_usage"`    // 累计使用的 cpu 时间，单位纳秒
+	MemoryUsage uint64 `json:"memory_usage"` // 当前使用的内存，单位字节
+	MemoryLimit uint64 `json:"memory_limit"` // 内存限制，0 表示不限制
+	MemoryCache uint64 `json:"memory_cache"` // 内存中的 page cache
+	OomKills    uint64 `json:"oom_kills"`    // 被 OOM killer 杀死的进程数
+	PidsCurrent uint64 `json:"pids_current"` // 当前的进程数
+	PidsLimit   uint64 `json:"pids_limit"`   // 进程数限制，0 表示不限制
+	BlkioRead   uint64 `json:"blkio_read"`   // 累计读取的字节数
+	BlkioWrite  uint64 `json:"blkio_write"`  // 累计写入的字节数
+}
+
+// 超过这个值的限制都认为是不限制，v1 中不限制时 limit_in_bytes 为一个接近 int64 最大值的数
+const unlimited = uint64(1) << 62
+
+// ReadUint 读取 cgroup 文件中的一个整数，max 表示不限制，返回 0
+func ReadUint(cgroupDir, file string) (uint64, error) {
+	content, err := os.ReadFile(path.Join(cgroupDir, file))
+	if err != nil {
+		return 0, fmt.Errorf("read cgroup file %s fail %v", file, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse cgroup file %s fail %v", file, err)
+	}
+	if number >= unlimited {
+		return 0, nil
+	}
+	return number, nil
+}
+
+// ReadKeyValues 读取每行格式为 key value 的 cgroup 文件，例如 memory.stat、cpu.stat
+func ReadKeyValues(cgroupDir, file string) (map[string]uint64, error) {
+	f, err := os.Open(path.Join(cgroupDir, file))
+	if err != nil {
+		return nil, fmt.Errorf("open cgroup file %s fail %v", file, err)
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, scanner.Err()
+}
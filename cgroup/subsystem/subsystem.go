@@ -1,11 +1,110 @@
 package subsystem
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
 
-// 用于传递资源限制配置的结构体，包含内存限制，CPU时间片去重，CPU核心数
+// FreezeTimeout 冻结/解冻 cgroup 时等待状态生效的最长时间
+const FreezeTimeout = 10 * time.Second
+
+// 删除 cgroup 时等待其中的进程被内核释放的最长时间
+const removeTimeout = time.Second
+
+// 用于传递资源限制配置的结构体，包含内存限制，CPU时间片去重，CPU核心数，设备白名单
+// 命令行参数统一转换成这里的类型和单位，v1 和 v2 的 subsystem 再各自转换成内核文件的格式
+// 字段为零值表示不修改对应的配置，dockergsh update 只传入需要修改的字段
 type ResourceConfig struct {
-	MemoryLimit string
-	CpuShare	string
-	CpuSet		string
+	Memory            int64             `json:"memory"`             // 内存限制，单位字节
+	MemorySwap        int64             `json:"memory_swap"`        // 内存加 swap 的总量，单位字节，-1 表示不限制 swap
+	MemoryReservation int64             `json:"memory_reservation"` // 内存软限制，宿主机内存紧张时优先回收超出的部分
+	OomKillDisable    bool              `json:"oom_kill_disable"`   // 内存超出限制时不杀死进程，只有 v1 支持
+	OomKillGroup      bool              `json:"oom_kill_group"`     // OOM 时杀死 cgroup 中的所有进程，只有 v2 支持
+	CpuShares         uint64            `json:"cpu_shares"`         // cpu 相对权重，v2 中转换为 cpu.weight
+	CpuQuota          int64             `json:"cpu_quota"`          // 一个周期内最多可以使用的 cpu 时间，单位微秒，-1 表示不限制
+	CpuPeriod         uint64            `json:"cpu_period"`         // cpu 周期，单位微秒
+	CpusetCpus        string            `json:"cpuset_cpus"`        // 可以使用的 cpu，例如 0-3、0,1
+	PidsLimit         int64             `json:"pids_limit"`         // 最大进程数，-1 表示不限制
+	BlkioWeight       uint16            `json:"blkio_weight"`       // 块设备 IO 权重，范围 10 - 1000
+	ReadBpsDevice     []*ThrottleDevice `json:"read_bps_device"`
+	WriteBpsDevice    []*ThrottleDevice `json:"write_bps_device"`
+	ReadIOpsDevice    []*ThrottleDevice `json:"read_iops_device"`
+	WriteIOpsDevice   []*ThrottleDevice `json:"write_iops_device"`
+	HugetlbLimits     []*HugetlbLimit   `json:"hugetlb_limits"`
+	DeviceRules       []*DeviceRule     `json:"-"` // 设备白名单单独记录在 ContainerInfo.DeviceRules 中
+}
+
+// DefaultCpuPeriod cpu.cfs_period_us 和 cpu.max 中使用的默认周期，单位微秒
+const DefaultCpuPeriod uint64 = 100000
+
+// CpusToQuota 将 --cpus 指定的 cpu 核数转换为默认周期内可以使用的 cpu 时间
+func CpusToQuota(cpus float64) (int64, uint64) {
+	return int64(cpus * float64(DefaultCpuPeriod)), DefaultCpuPeriod
+}
+
+// CpuSharesToWeight 将 v1 的 cpu.shares（2 - 262144）线性转换为 v2 的 cpu.weight（1 - 10000）
+func CpuSharesToWeight(shares uint64) uint64 {
+	if shares == 0 {
+		return 0
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// CpuMax 转换为 v2 cpu.max 的格式 $MAX $PERIOD，quota 不大于 0 时为 max
+func CpuMax(quota int64, period uint64) string {
+	max := "max"
+	if quota > 0 {
+		max = strconv.FormatInt(quota, 10)
+	}
+	if period > 0 {
+		return fmt.Sprintf("%s %d", max, period)
+	}
+	return max
+}
+
+// PidsMax 转换为 pids.max 的内容，负数表示不限制
+func PidsMax(limit int64) string {
+	if limit < 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// Merge 用 update 中不为零值的字段覆盖当前配置
+func (rc *ResourceConfig) Merge(update *ResourceConfig) {
+	if update.Memory != 0 {
+		rc.Memory = update.Memory
+	}
+	if update.MemorySwap != 0 {
+		rc.MemorySwap = update.MemorySwap
+	}
+	if update.MemoryReservation != 0 {
+		rc.MemoryReservation = update.MemoryReservation
+	}
+	if update.CpuShares != 0 {
+		rc.CpuShares = update.CpuShares
+	}
+	if update.CpuQuota != 0 {
+		rc.CpuQuota = update.CpuQuota
+	}
+	if update.CpuPeriod != 0 {
+		rc.CpuPeriod = update.CpuPeriod
+	}
+	if update.CpusetCpus != "" {
+		rc.CpusetCpus = update.CpusetCpus
+	}
+	if update.PidsLimit != 0 {
+		rc.PidsLimit = update.PidsLimit
+	}
+	if update.BlkioWeight != 0 {
+		rc.BlkioWeight = update.BlkioWeight
+	}
 }
 
 // Subsystem 接口，每个 subsystem 需要实现四个接口
@@ -19,4 +118,47 @@ type Subsystem interface {
 	Apply(cgroupPath string, pid int) error
 	// 删除某个 cgroup
 	Remove(cgroupPath string) error
-}
\ No newline at end of file
+	// 读取某个 cgroup 在这个 subsystem 中的统计信息，填充到 stats 中
+	Stats(cgroupPath string, stats *Stats) error
+}
+
+// ReadCgroupProcs 读取 cgroup 目录下 cgroup.procs 中的所有进程号
+func ReadCgroupProcs(cgroupDir string) ([]int, error) {
+	content, err := os.ReadFile(path.Join(cgroupDir, "cgroup.procs"))
+	if err != nil {
+		return nil, fmt.Errorf("read cgroup procs fail %v", err)
+	}
+	var pids []int
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %q in cgroup procs", line)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+/*
+RemoveCgroupDir 删除一个 cgroup 目录
+cgroupfs 中的文件不能删除，只能对目录 rmdir，因此不能使用 os.RemoveAll，否则 cgroup 中还有进程时返回的是删除文件的 EPERM
+cgroup 中的进程刚退出时内核可能还没有释放，rmdir 会返回 EBUSY，这里短暂重试
+目录不存在时认为已经删除
+*/
+func RemoveCgroupDir(cgroupDir string) error {
+	deadline := time.Now().Add(removeTimeout)
+	for {
+		err := os.Remove(cgroupDir)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		// 普通目录（例如测试中模拟的 cgroupfs）中的文件可以直接删除
+		if errors.Is(err, syscall.ENOTEMPTY) {
+			return os.RemoveAll(cgroupDir)
+		}
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			return fmt.Errorf("remove cgroup %s fail %v", cgroupDir, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type BlkioSubSystem struct{}
+
+func (bs *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+// 设置块设备 IO 的权重和每个设备的读写限速
+func (bs *BlkioSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	blkioSubSystemCgroupPath, err := GetCgroupPath(bs.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+
+	if resConf.BlkioWeight != 0 {
+		// 使用 bfq 调度器的内核没有 blkio.weight，只有 blkio.bfq.weight
+		weightFile := path.Join(blkioSubSystemCgroupPath, "blkio.weight")
+		if _, err := os.Stat(weightFile); os.IsNotExist(err) {
+			weightFile = path.Join(blkioSubSystemCgroupPath, "blkio.bfq.weight")
+		}
+		if err := os.WriteFile(weightFile, []byte(strconv.FormatUint(uint64(resConf.BlkioWeight), 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup blkio weight fail %v", err)
+		}
+	}
+
+	throttles := map[string][]*subsystem.ThrottleDevice{
+		"blkio.throttle.read_bps_device":   resConf.ReadBpsDevice,
+		"blkio.throttle.write_bps_device":  resConf.WriteBpsDevice,
+		"blkio.throttle.read_iops_device":  resConf.ReadIOpsDevice,
+		"blkio.throttle.write_iops_device": resConf.WriteIOpsDevice,
+	}
+	for file, devices := range throttles {
+		// 每次只能写入一个设备
+		for _, device := range devices {
+			if err := os.WriteFile(path.Join(blkioSubSystemCgroupPath, file), []byte(device.String()), 0644); err != nil {
+				return fmt.Errorf("set cgroup %s fail %v", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (bs *BlkioSubSystem) Apply(cgroupPath string, pid int) error {
+	if blkioSubSystemCgroupPath, err := GetCgroupPath(bs.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(blkioSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+func (bs *BlkioSubSystem) Remove(cgroupPath string) error {
+	if blkioSubSystemCgroupPath, err := GetCgroupPath(bs.Name(), cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(blkioSubSystemCgroupPath)
+	}
+}
+
+/*
+读取 blkio.throttle.io_service_bytes，统计所有设备的读写字节数，格式为：
+8:0 Read 4096
+8:0 Write 0
+...
+Total 4096
+*/
+func (bs *BlkioSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	blkioSubSystemCgroupPath, err := GetCgroupPath(bs.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path.Join(blkioSubSystemCgroupPath, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return fmt.Errorf("open cgroup blkio stats fail %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			stats.BlkioRead += value
+		case "Write":
+			stats.BlkioWrite += value
+		}
+	}
+	return scanner.Err()
+}
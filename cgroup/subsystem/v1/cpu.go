@@ -20,11 +20,22 @@ func (cs *CpuSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig
 		return err
 	} else {
 		// 设置 对应 cgroup 的 cpu 资源限制，也就是修改 cpu.shares 文件
-		if resConf.CpuShare != "" {
-			if err := os.WriteFile(path.Join(cpuSubSystemCgroupPath, "cpu.shares"), []byte(resConf.CpuShare), 0644); err != nil {
+		if resConf.CpuShares != 0 {
+			if err := os.WriteFile(path.Join(cpuSubSystemCgroupPath, "cpu.shares"), []byte(strconv.FormatUint(resConf.CpuShares, 10)), 0644); err != nil {
 				return fmt.Errorf("set cgroup cpu share fail %v", err)
 			}
 		}
+		// 一个 cpu.cfs_period_us 周期内最多可以使用 cpu.cfs_quota_us 的 cpu 时间
+		if resConf.CpuPeriod != 0 {
+			if err := os.WriteFile(path.Join(cpuSubSystemCgroupPath, "cpu.cfs_period_us"), []byte(strconv.FormatUint(resConf.CpuPeriod, 10)), 0644); err != nil {
+				return fmt.Errorf("set cgroup cpu period fail %v", err)
+			}
+		}
+		if resConf.CpuQuota != 0 {
+			if err := os.WriteFile(path.Join(cpuSubSystemCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.FormatInt(resConf.CpuQuota, 10)), 0644); err != nil {
+				return fmt.Errorf("set cgroup cpu quota fail %v", err)
+			}
+		}
 		return nil
 	}
 }
@@ -49,6 +60,11 @@ func (cs *CpuSubSystem) Remove(cgroupPath string) error {
 		return err
 	} else {
 		// 产出 cgroup 的目录，便是删除了 cgroup
-		return os.RemoveAll(cpuSubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(cpuSubSystemCgroupPath)
 	}
 }
+
+// cpu 使用时间由 cpuacct subsystem 统计
+func (cs *CpuSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
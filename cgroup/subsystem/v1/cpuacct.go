@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+)
+
+// CpuacctSubSystem 统计 cgroup 的 cpu 使用时间，很多发行版会把 cpu 和 cpuacct 挂载在同一个 hierarchy 上
+type CpuacctSubSystem struct{}
+
+func (cas *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
+
+// cpuacct 没有资源限制需要设置，这里只创建 cgroup
+func (cas *CpuacctSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	_, err := GetCgroupPath(cas.Name(), cgroupPath, true)
+	return err
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (cas *CpuacctSubSystem) Apply(cgroupPath string, pid int) error {
+	if cpuacctSubSystemCgroupPath, err := GetCgroupPath(cas.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(cpuacctSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+// 与 cpu 挂载在同一个 hierarchy 上时，目录可能已经被 cpu subsystem 删除了
+func (cas *CpuacctSubSystem) Remove(cgroupPath string) error {
+	cpuacctSubSystemCgroupPath := path.Join(FindCgroupMountPoint(cas.Name()), "dockergsh", cgroupPath)
+	if _, err := os.Stat(cpuacctSubSystemCgroupPath); os.IsNotExist(err) {
+		return nil
+	}
+	return subsystem.RemoveCgroupDir(cpuacctSubSystemCgroupPath)
+}
+
+// 读取 cpuacct.usage，单位为纳秒
+func (cas *CpuacctSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	cpuacctSubSystemCgroupPath, err := GetCgroupPath(cas.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	stats.CpuUsage, err = subsystem.ReadUint(cpuacctSubSystemCgroupPath, "cpuacct.usage")
+	return err
+}
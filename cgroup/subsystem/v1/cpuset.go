@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
 type CpuSetSubSystem struct {}
@@ -20,9 +20,13 @@ func (css *CpuSetSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceCo
 	if cpusetSubSystemCgroupPath, err := GetCgroupPath(css.Name(), cgroupPath, true); err != nil {
 		return err
 	} else {
+		// 新创建的 cgroup 中 cpuset.cpus 和 cpuset.mems 为空，需要先从父 cgroup 继承，否则进程无法加入
+		if err := initCpuset(cpusetSubSystemCgroupPath); err != nil {
+			return err
+		}
 		// 设置 对应 cgroup 的 cpu 资源限制，也就是修改 cpuset.cpus 文件
-		if resConf.CpuShare != "" {
-			if err := ioutil.WriteFile(path.Join(cpusetSubSystemCgroupPath, "cpuset.cpus"), []byte(resConf.CpuSet), 0644); err != nil {
+		if resConf.CpusetCpus != "" {
+			if err := ioutil.WriteFile(path.Join(cpusetSubSystemCgroupPath, "cpuset.cpus"), []byte(resConf.CpusetCpus), 0644); err != nil {
 				return fmt.Errorf("set cgroup cpuset fail %v", err)
 			}
 		}
@@ -50,6 +54,36 @@ func (css *CpuSetSubSystem) Remove(cgroupPath string) error {
 		return err
 	} else {
 		// 产出 cgroup 的目录，便是删除了 cgroup
-		return os.RemoveAll(cpusetSubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(cpusetSubSystemCgroupPath)
 	}
 }
+
+// cpuset.cpus 或 cpuset.mems 为空时，向这个 cgroup 中加入进程会返回 ENOSPC
+// 父 cgroup 也可能是新创建的，因此逐级向上继承
+func initCpuset(cgroupDir string) error {
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		content, err := ioutil.ReadFile(path.Join(cgroupDir, file))
+		if err != nil {
+			return fmt.Errorf("read %s fail %v", file, err)
+		}
+		if strings.TrimSpace(string(content)) != "" {
+			continue
+		}
+		parent := path.Dir(cgroupDir)
+		if err := initCpuset(parent); err != nil {
+			return err
+		}
+		if content, err = ioutil.ReadFile(path.Join(parent, file)); err != nil {
+			return fmt.Errorf("read %s fail %v", file, err)
+		}
+		if err := ioutil.WriteFile(path.Join(cgroupDir, file), content, 0644); err != nil {
+			return fmt.Errorf("init %s fail %v", file, err)
+		}
+	}
+	return nil
+}
+
+// cpuset 没有统计信息
+func (css *CpuSetSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
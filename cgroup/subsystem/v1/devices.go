@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+)
+
+type DevicesSubSystem struct{}
+
+func (ds *DevicesSubSystem) Name() string {
+	return "devices"
+}
+
+// 设置 cgroup 的设备白名单
+// 先向 devices.deny 写入 a 禁止所有设备，再逐条写入 devices.allow/devices.deny
+func (ds *DevicesSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(ds.Name(), cgroupPath, true); err != nil {
+		return err
+	} else {
+		if len(resConf.DeviceRules) == 0 {
+			return nil
+		}
+		if err := os.WriteFile(path.Join(devicesSubSystemCgroupPath, "devices.deny"), []byte("a"), 0644); err != nil {
+			return fmt.Errorf("set cgroup devices deny all fail %v", err)
+		}
+		for _, rule := range resConf.DeviceRules {
+			file := "devices.deny"
+			if rule.Allow {
+				file = "devices.allow"
+			}
+			if err := os.WriteFile(path.Join(devicesSubSystemCgroupPath, file), []byte(rule.String()), 0644); err != nil {
+				return fmt.Errorf("set cgroup devices rule %s fail %v", rule, err)
+			}
+		}
+		return nil
+	}
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (ds *DevicesSubSystem) Apply(cgroupPath string, pid int) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(ds.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(devicesSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+func (ds *DevicesSubSystem) Remove(cgroupPath string) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(ds.Name(), cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(devicesSubSystemCgroupPath)
+	}
+}
+
+// devices 没有统计信息
+func (ds *DevicesSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
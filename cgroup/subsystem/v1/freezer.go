@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FreezerSubSystem struct{}
+
+func (fs *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+// freezer 没有资源限制需要设置，这里只创建 cgroup
+func (fs *FreezerSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	_, err := GetCgroupPath(fs.Name(), cgroupPath, true)
+	return err
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (fs *FreezerSubSystem) Apply(cgroupPath string, pid int) error {
+	if freezerSubSystemCgroupPath, err := GetCgroupPath(fs.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(freezerSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+func (fs *FreezerSubSystem) Remove(cgroupPath string) error {
+	if freezerSubSystemCgroupPath, err := GetCgroupPath(fs.Name(), cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(freezerSubSystemCgroupPath)
+	}
+}
+
+/*
+Freeze 冻结或解冻 cgroup 中的所有进程，也就是修改 freezer.state 文件
+写入 FROZEN 后状态会先变成 FREEZING，等所有进程都停下来才变成 FROZEN，因此需要轮询直到状态生效
+*/
+func (fs *FreezerSubSystem) Freeze(cgroupPath string, frozen bool) error {
+	freezerSubSystemCgroupPath, err := GetCgroupPath(fs.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+
+	state := "THAWED"
+	if frozen {
+		state = "FROZEN"
+	}
+	stateFile := path.Join(freezerSubSystemCgroupPath, "freezer.state")
+	deadline := time.Now().Add(subsystem.FreezeTimeout)
+	for {
+		// 冻结过程中有进程 fork 时可能会停在 FREEZING，需要重复写入
+		if err := os.WriteFile(stateFile, []byte(state), 0644); err != nil {
+			return fmt.Errorf("set cgroup freezer state %s fail %v", state, err)
+		}
+		current, err := os.ReadFile(stateFile)
+		if err != nil {
+			return fmt.Errorf("read cgroup freezer state fail %v", err)
+		}
+		if strings.TrimSpace(string(current)) == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup freezer state is still %s, expect %s", strings.TrimSpace(string(current)), state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// freezer 没有统计信息
+func (fs *FreezerSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
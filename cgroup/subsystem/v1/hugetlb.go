@@ -0,0 +1,75 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+)
+
+// HugetlbSubSystem 限制大页内存的使用，宿主机没有挂载 hugetlb 时，不限制大页内存的容器可以正常运行
+type HugetlbSubSystem struct{}
+
+func (hs *HugetlbSubSystem) Name() string {
+	return "hugetlb"
+}
+
+func (hs *HugetlbSubSystem) mounted() bool {
+	return FindCgroupMountPoint(hs.Name()) != ""
+}
+
+// 设置每种大页的使用上限，也就是修改 hugetlb.<大页大小>.limit_in_bytes 文件
+func (hs *HugetlbSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	if !hs.mounted() {
+		if len(resConf.HugetlbLimits) > 0 {
+			return fmt.Errorf("set cgroup hugetlb fail: hugetlb subsystem is not mounted")
+		}
+		return nil
+	}
+	hugetlbSubSystemCgroupPath, err := GetCgroupPath(hs.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	for _, limit := range resConf.HugetlbLimits {
+		file := fmt.Sprintf("hugetlb.%s.limit_in_bytes", limit.PageSize)
+		if err := os.WriteFile(path.Join(hugetlbSubSystemCgroupPath, file), []byte(strconv.FormatUint(limit.Limit, 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup %s fail %v", file, err)
+		}
+	}
+	return nil
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (hs *HugetlbSubSystem) Apply(cgroupPath string, pid int) error {
+	if !hs.mounted() {
+		return nil
+	}
+	if hugetlbSubSystemCgroupPath, err := GetCgroupPath(hs.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(hugetlbSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+func (hs *HugetlbSubSystem) Remove(cgroupPath string) error {
+	if !hs.mounted() {
+		return nil
+	}
+	if hugetlbSubSystemCgroupPath, err := GetCgroupPath(hs.Name(), cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(hugetlbSubSystemCgroupPath)
+	}
+}
+
+// 大页内存没有统计信息
+func (hs *HugetlbSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
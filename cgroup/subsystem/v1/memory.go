@@ -1,12 +1,14 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
+	"syscall"
 )
 
 type MemorySubSystem struct {}
@@ -21,15 +23,65 @@ func (ms *MemorySubSystem) Set(cgroupPath string, resConf *subsystem.ResourceCon
 		return err
 	} else {
 		// 设置 对应 cgroup 的 cpu 资源限制，也就是修改 memory.limit_in_bytes 文件
-		if resConf.CpuShare != "" {
-			if err := ioutil.WriteFile(path.Join(memorySubSystemCgroupPath, "memory.limit_in_bytes"), []byte(resConf.MemoryLimit), 0644); err != nil {
-				return fmt.Errorf("set cgroup memory fail %v", err)
+		if err := setMemoryLimit(memorySubSystemCgroupPath, resConf.Memory, resConf.MemorySwap); err != nil {
+			return err
+		}
+		// 软限制，宿主机内存紧张时，超出 memory.soft_limit_in_bytes 的部分会被优先回收
+		if resConf.MemoryReservation != 0 {
+			if err := ioutil.WriteFile(path.Join(memorySubSystemCgroupPath, "memory.soft_limit_in_bytes"), []byte(strconv.FormatInt(resConf.MemoryReservation, 10)), 0644); err != nil {
+				return fmt.Errorf("set cgroup memory reservation fail %v", err)
+			}
+		}
+		// 内存超出限制时，进程会被挂起等待内存释放，而不是被 OOM killer 杀死
+		if resConf.OomKillDisable {
+			if err := ioutil.WriteFile(path.Join(memorySubSystemCgroupPath, "memory.oom_control"), []byte("1"), 0644); err != nil {
+				return fmt.Errorf("set cgroup oom kill disable fail %v", err)
 			}
 		}
 		return nil
 	}
 }
 
+/*
+设置内存限制和内存加 swap 的限制
+内核要求 memory.limit_in_bytes 始终不大于 memory.memsw.limit_in_bytes，因此两者的写入顺序取决于是调大还是调小：
+先写 memsw，失败的话说明新的 memsw 小于当前的内存限制，先写内存限制再写 memsw
+*/
+func setMemoryLimit(cgroupDir string, memoryLimit, memorySwap int64) error {
+	writeMemoryLimit := func() error {
+		if memoryLimit == 0 {
+			return nil
+		}
+		if err := ioutil.WriteFile(path.Join(cgroupDir, "memory.limit_in_bytes"), []byte(strconv.FormatInt(memoryLimit, 10)), 0644); err != nil {
+			// 新的限制低于当前的内存使用量，并且回收不了足够的内存时，内核返回 EBUSY
+			if errors.Is(err, syscall.EBUSY) {
+				return fmt.Errorf("set cgroup memory fail: memory limit %s is lower than the current memory usage", units.BytesSize(float64(memoryLimit)))
+			}
+			return fmt.Errorf("set cgroup memory fail %v", err)
+		}
+		return nil
+	}
+	writeMemorySwap := func() error {
+		return ioutil.WriteFile(path.Join(cgroupDir, "memory.memsw.limit_in_bytes"), []byte(strconv.FormatInt(memorySwap, 10)), 0644)
+	}
+
+	if memorySwap == 0 {
+		return writeMemoryLimit()
+	}
+	if err := writeMemorySwap(); err == nil {
+		return writeMemoryLimit()
+	} else if !errors.Is(err, syscall.EINVAL) {
+		return fmt.Errorf("set cgroup memory swap fail %v", err)
+	}
+	if err := writeMemoryLimit(); err != nil {
+		return err
+	}
+	if err := writeMemorySwap(); err != nil {
+		return fmt.Errorf("set cgroup memory swap fail %v", err)
+	}
+	return nil
+}
+
 // 将一个进程添加到 cgroupPath 对应的 cgroup 中
 func (ms *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 	if memorySubSystemCgroupPath, err := GetCgroupPath(ms.Name(), cgroupPath, false); err != nil {
@@ -50,6 +102,32 @@ func (ms *MemorySubSystem) Remove(cgroupPath string) error {
 		return err
 	} else {
 		// 产出 cgroup 的目录，便是删除了 cgroup
-		return os.RemoveAll(memorySubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(memorySubSystemCgroupPath)
+	}
+}
+
+// 读取内存使用量、内存限制、page cache 和 OOM 次数
+func (ms *MemorySubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	memorySubSystemCgroupPath, err := GetCgroupPath(ms.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.MemoryUsage, err = subsystem.ReadUint(memorySubSystemCgroupPath, "memory.usage_in_bytes"); err != nil {
+		return err
+	}
+	if stats.MemoryLimit, err = subsystem.ReadUint(memorySubSystemCgroupPath, "memory.limit_in_bytes"); err != nil {
+		return err
+	}
+	memoryStat, err := subsystem.ReadKeyValues(memorySubSystemCgroupPath, "memory.stat")
+	if err != nil {
+		return err
+	}
+	stats.MemoryCache = memoryStat["total_cache"]
+	// 4.13 之前的内核 memory.oom_control 中没有 oom_kill
+	oomControl, err := subsystem.ReadKeyValues(memorySubSystemCgroupPath, "memory.oom_control")
+	if err != nil {
+		return err
 	}
+	stats.OomKills = oomControl["oom_kill"]
+	return nil
 }
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+)
+
+type PidsSubSystem struct{}
+
+func (ps *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+// 设置 cgroup 中最多可以创建的进程数，也就是修改 pids.max 文件
+func (ps *PidsSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	pidsSubSystemCgroupPath, err := GetCgroupPath(ps.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if resConf.PidsLimit != 0 {
+		if err := os.WriteFile(path.Join(pidsSubSystemCgroupPath, "pids.max"), []byte(subsystem.PidsMax(resConf.PidsLimit)), 0644); err != nil {
+			return fmt.Errorf("set cgroup pids limit fail %v", err)
+		}
+	}
+	return nil
+}
+
+// 将一个进程添加到 cgroupPath 对应的 cgroup 中
+func (ps *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	if pidsSubSystemCgroupPath, err := GetCgroupPath(ps.Name(), cgroupPath, false); err != nil {
+		return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+	} else {
+		// 将进程号 pid 写入到 cgroup 的虚拟文件系统对应目录下的 tasks 文件中
+		if err := os.WriteFile(path.Join(pidsSubSystemCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		} else {
+			return nil
+		}
+	}
+}
+
+// 删除 cgroupPath 对应的 cgroup
+func (ps *PidsSubSystem) Remove(cgroupPath string) error {
+	if pidsSubSystemCgroupPath, err := GetCgroupPath(ps.Name(), cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(pidsSubSystemCgroupPath)
+	}
+}
+
+// 读取当前进程数和进程数限制
+func (ps *PidsSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	pidsSubSystemCgroupPath, err := GetCgroupPath(ps.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.PidsCurrent, err = subsystem.ReadUint(pidsSubSystemCgroupPath, "pids.current"); err != nil {
+		return err
+	}
+	stats.PidsLimit, err = subsystem.ReadUint(pidsSubSystemCgroupPath, "pids.max")
+	return err
+}
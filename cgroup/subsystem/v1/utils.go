@@ -8,9 +8,21 @@ import (
 	"strings"
 )
 
+// CgroupRoot 不为空时，每个 subsystem 的 hierarchy 都在 CgroupRoot/<subsystem> 目录下，不再从 mountinfo 中查找
+// 测试时可以指向一个普通目录模拟 cgroupfs
+var CgroupRoot string
+
 // 通过 /proc/self/mountinfo 找到挂载了某个 subsystem 的 hierarchy cgroup 的根节点所在目录
 //  使用：FindCgroupMountPoint("memory"),这里返回具体某个 cgroup 挂载的根路径
 func FindCgroupMountPoint(subsystem string) string {
+	if CgroupRoot != "" {
+		mountPoint := path.Join(CgroupRoot, subsystem)
+		if _, err := os.Stat(mountPoint); err != nil {
+			return ""
+		}
+		return mountPoint
+	}
+
 	// 打开 /proc/self/mountinfo
 	file, err := os.Open("/proc/self/mountinfo")
 	if err != nil {
@@ -37,15 +49,19 @@ func FindCgroupMountPoint(subsystem string) string {
 // GetCgroupPath 函数是找到对应 subsystem 挂载的相对路径，然后通过对这个目录的读写取操作 cgroup
 // 获取具体某个 cgroup 的具体绝对路径
 func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
-	// 获取某个 cgroup 的根路径
-	cgroupRoot := FindCgroupMountPoint(subsystem)
+	// 获取某个 cgroup 的根路径，容器的 cgroup 统一放在 dockergsh 目录下
+	mountPoint := FindCgroupMountPoint(subsystem)
+	if mountPoint == "" {
+		return "", fmt.Errorf("cgroup subsystem %s is not mounted", subsystem)
+	}
+	cgroupRoot := path.Join(mountPoint, "dockergsh")
 	// os.Stat返回描述文件 f 的 FileInfo 类型值。如果出错，错误底层类型是 *PathError
 	_, err := os.Stat(path.Join(cgroupRoot, cgroupPath))
 
 	// 如果目录不存在就创建
 	if err == nil || (autoCreate && os.IsNotExist(err)) {
 		if os.IsNotExist(err) {
-			if err := os.Mkdir(path.Join(cgroupRoot, "dockergsh", cgroupPath), 0755); err != nil {
+			if err := os.MkdirAll(path.Join(cgroupRoot, cgroupPath), 0755); err != nil {
 				return "", fmt.Errorf("error create cgroup %v", err)
 			}
 		}
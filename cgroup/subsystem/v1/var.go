@@ -7,5 +7,11 @@ var (
 		&CpuSubSystem{},
 		&CpuSetSubSystem{},
 		&MemorySubSystem{},
+		&DevicesSubSystem{},
+		&FreezerSubSystem{},
+		&CpuacctSubSystem{},
+		&PidsSubSystem{},
+		&BlkioSubSystem{},
+		&HugetlbSubSystem{},
 	}
 )
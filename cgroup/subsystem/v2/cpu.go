@@ -5,7 +5,6 @@ import (
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
 )
@@ -20,17 +19,20 @@ func (cs *CpuSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig
 	if cpuSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
 		return err
 	} else {
-		// v2 的cpu配置在文件 cpu.max, 格式为 $MAX $PERIOD，表示在一段 PERIOD 时间内，占用了多少
-		if resConf.CpuShare != "" {
-			per, _ := strconv.Atoi(resConf.CpuShare)
-			// 这里统一规划为 0-100，表示占用 %多少
-			if per < 0 || per > 100 {
-				return fmt.Errorf("set cgroup cpu fail")
+		// v2 没有 cpu.shares，相对权重写入 cpu.weight
+		if resConf.CpuShares != 0 {
+			if err := ioutil.WriteFile(
+				path.Join(cpuSubSystemCgroupPath, "cpu.weight"),
+				[]byte(strconv.FormatUint(subsystem.CpuSharesToWeight(resConf.CpuShares), 10)),
+				0644); err != nil {
+				return fmt.Errorf("set cgroup cpu weight fail %v", err)
 			}
-			cpuShare := fmt.Sprintf("%d %d", per*1000, 100000)
+		}
+		// v2 的cpu配置在文件 cpu.max, 格式为 $MAX $PERIOD，表示在一段 PERIOD 时间内，占用了多少
+		if resConf.CpuQuota != 0 || resConf.CpuPeriod != 0 {
 			if err := ioutil.WriteFile(
 				path.Join(cpuSubSystemCgroupPath, "cpu.max"),
-				[]byte(cpuShare),
+				[]byte(subsystem.CpuMax(resConf.CpuQuota, resConf.CpuPeriod)),
 				0644); err != nil {
 				return fmt.Errorf("set cgroup cpu fail %v", err)
 			}
@@ -54,9 +56,23 @@ func (cs *CpuSubSystem) Apply(cgroupPath string, pid int) error {
 }
 
 func (cs *CpuSubSystem) Remove(cgroupPath string) error {
-	if cpuSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+	if cpuSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
 		return err
 	} else {
-		return os.RemoveAll(cpuSubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(cpuSubSystemCgroupPath)
+	}
+}
+
+// 读取 cpu.stat 中的 usage_usec，转换成纳秒
+func (cs *CpuSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	cpuSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	cpuStat, err := subsystem.ReadKeyValues(cpuSubSystemCgroupPath, "cpu.stat")
+	if err != nil {
+		return err
 	}
+	stats.CpuUsage = cpuStat["usage_usec"] * 1000
+	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
 )
@@ -20,11 +19,11 @@ func (css *CpuSetSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceCo
 	if cpuSetSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
 		return err
 	} else {
-		// v2 的cpu配置在文件 cpu.max, 格式为 $MAX $PERIOD，表示在一段 PERIOD 时间内，占用了多少
-		if resConf.CpuSet != "" {
+		// 限制可以使用的 cpu，也就是修改 cpuset.cpus 文件
+		if resConf.CpusetCpus != "" {
 			if err := ioutil.WriteFile(
 				path.Join(cpuSetSubSystemCgroupPath, "cpuset.cpus"),
-				[]byte(resConf.CpuSet),
+				[]byte(resConf.CpusetCpus),
 				0644); err != nil {
 				return fmt.Errorf("set cgroup cpuset fail %v", err)
 			}
@@ -48,9 +47,14 @@ func (css *CpuSetSubSystem) Apply(cgroupPath string, pid int) error {
 }
 
 func (css *CpuSetSubSystem) Remove(cgroupPath string) error {
-	if cpuSetSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+	if cpuSetSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
 		return err
 	} else {
-		return os.RemoveAll(cpuSetSubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(cpuSetSubSystemCgroupPath)
 	}
 }
+
+// cpuset 没有统计信息
+func (css *CpuSetSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
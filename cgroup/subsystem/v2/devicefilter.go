@@ -0,0 +1,217 @@
+package v2
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"golang.org/x/sys/unix"
+)
+
+/*
+cgroup v2 没有 devices.allow/devices.deny 文件，设备访问控制需要通过 eBPF 实现：
+生成一个 BPF_PROG_TYPE_CGROUP_DEVICE 类型的程序，attach 到容器的 cgroup 目录上，
+容器内每次访问设备时，内核都会调用该程序，返回 1 表示允许，返回 0 表示拒绝
+*/
+const (
+	// bpf 系统调用的命令
+	bpfProgLoad   = 5
+	bpfProgAttach = 8
+
+	// 程序类型和 attach 类型
+	bpfProgTypeCgroupDevice = 15
+	bpfCgroupDevice         = 6
+
+	// bpf_cgroup_dev_ctx.access_type 低 16 位是设备类型，高 16 位是访问类型
+	bpfDevcgDevBlock = 1
+	bpfDevcgDevChar  = 2
+	bpfDevcgAccMknod = 1
+	bpfDevcgAccRead  = 2
+	bpfDevcgAccWrite = 4
+
+	// 用到的 eBPF 指令操作码
+	bpfLdxMemW  = 0x61 // dst = *(u32 *)(src + off)
+	bpfAlu64And = 0x57 // dst &= imm
+	bpfAlu64Rsh = 0x77 // dst >>= imm
+	bpfMov64Reg = 0xbf // dst = src
+	bpfMov64Imm = 0xb7 // dst = imm
+	bpfJeqImm   = 0x15 // if dst == imm goto pc + off
+	bpfJneImm   = 0x55 // if dst != imm goto pc + off
+	bpfJneReg   = 0x5d // if dst != src goto pc + off
+	bpfExit     = 0x95
+)
+
+// bpfInsn 对应内核的 struct bpf_insn
+type bpfInsn struct {
+	Code uint8
+	Regs uint8 // 低 4 位是 dst 寄存器，高 4 位是 src 寄存器
+	Off  int16
+	Imm  int32
+}
+
+func insn(code, dst, src uint8, off int16, imm int32) bpfInsn {
+	return bpfInsn{Code: code, Regs: dst | src<<4, Off: off, Imm: imm}
+}
+
+// bpf(BPF_PROG_LOAD) 的参数
+type bpfProgLoadAttr struct {
+	ProgType    uint32
+	InsnCnt     uint32
+	Insns       uint64
+	License     uint64
+	LogLevel    uint32
+	LogSize     uint32
+	LogBuf      uint64
+	KernVersion uint32
+	ProgFlags   uint32
+}
+
+// bpf(BPF_PROG_ATTACH) 的参数
+type bpfProgAttachAttr struct {
+	TargetFd    uint32
+	AttachBpfFd uint32
+	AttachType  uint32
+	AttachFlags uint32
+}
+
+/*
+根据设备规则生成 eBPF 程序，逻辑如下：
+1. r2 = 设备类型，r3 = 访问类型，r4 = 主设备号，r5 = 次设备号
+2. 规则从后往前匹配，与 v1 中后写入的规则覆盖先写入的规则保持一致，命中则返回规则的 Allow
+3. 都没有命中，返回 0 拒绝访问
+*/
+func generateDeviceFilter(rules []*subsystem.DeviceRule) ([]bpfInsn, error) {
+	prog := []bpfInsn{
+		insn(bpfLdxMemW, 2, 1, 0, 0),
+		insn(bpfAlu64And, 2, 0, 0, 0xFFFF),
+		insn(bpfLdxMemW, 3, 1, 0, 0),
+		insn(bpfAlu64Rsh, 3, 0, 0, 16),
+		insn(bpfLdxMemW, 4, 1, 4, 0),
+		insn(bpfLdxMemW, 5, 1, 8, 0),
+	}
+
+	for i := len(rules) - 1; i >= 0; i-- {
+		block, err := deviceRuleBlock(rules[i])
+		if err != nil {
+			return nil, err
+		}
+		prog = append(prog, block...)
+	}
+
+	// 默认拒绝
+	prog = append(prog, insn(bpfMov64Imm, 0, 0, 0, 0), insn(bpfExit, 0, 0, 0, 0))
+	return prog, nil
+}
+
+// 单条规则对应的指令块，任意条件不满足就跳到块的末尾，也就是下一条规则
+func deviceRuleBlock(rule *subsystem.DeviceRule) ([]bpfInsn, error) {
+	var block []bpfInsn
+	// 记录跳转指令的位置，块生成完以后再回填跳转偏移
+	var jumps []int
+
+	switch rule.Type {
+	case "a":
+	case "c":
+		jumps = append(jumps, len(block))
+		block = append(block, insn(bpfJneImm, 2, 0, 0, bpfDevcgDevChar))
+	case "b":
+		jumps = append(jumps, len(block))
+		block = append(block, insn(bpfJneImm, 2, 0, 0, bpfDevcgDevBlock))
+	default:
+		return nil, fmt.Errorf("invalid device type %q", rule.Type)
+	}
+
+	var access int32
+	for _, c := range rule.Permissions {
+		switch c {
+		case 'r':
+			access |= bpfDevcgAccRead
+		case 'w':
+			access |= bpfDevcgAccWrite
+		case 'm':
+			access |= bpfDevcgAccMknod
+		default:
+			return nil, fmt.Errorf("invalid device permissions %q", rule.Permissions)
+		}
+	}
+	// 规则没有覆盖全部权限时，与 v1 一致：
+	// allow 规则要求本次访问的权限是规则权限的子集：(access & mask) == access
+	// deny 规则只要本次访问的权限与规则权限有交集就拒绝：(access & mask) != 0，例如禁止 w 时 rw 打开也被拒绝
+	if access != bpfDevcgAccRead|bpfDevcgAccWrite|bpfDevcgAccMknod {
+		block = append(block,
+			insn(bpfMov64Reg, 1, 3, 0, 0),
+			insn(bpfAlu64And, 1, 0, 0, access),
+		)
+		jumps = append(jumps, len(block))
+		if rule.Allow {
+			block = append(block, insn(bpfJneReg, 1, 3, 0, 0))
+		} else {
+			block = append(block, insn(bpfJeqImm, 1, 0, 0, 0))
+		}
+	}
+
+	if rule.Type != "a" && rule.Major != subsystem.Wildcard {
+		jumps = append(jumps, len(block))
+		block = append(block, insn(bpfJneImm, 4, 0, 0, int32(rule.Major)))
+	}
+	if rule.Type != "a" && rule.Minor != subsystem.Wildcard {
+		jumps = append(jumps, len(block))
+		block = append(block, insn(bpfJneImm, 5, 0, 0, int32(rule.Minor)))
+	}
+
+	var allow int32
+	if rule.Allow {
+		allow = 1
+	}
+	block = append(block, insn(bpfMov64Imm, 0, 0, 0, allow), insn(bpfExit, 0, 0, 0, 0))
+
+	// 跳转偏移是相对于下一条指令的
+	for _, jump := range jumps {
+		block[jump].Off = int16(len(block) - jump - 1)
+	}
+	return block, nil
+}
+
+// 加载 eBPF 程序，返回程序的文件描述符
+func loadDeviceFilter(prog []bpfInsn) (int, error) {
+	license := []byte("GPL\x00")
+	logBuf := make([]byte, 65536)
+	attr := bpfProgLoadAttr{
+		ProgType: bpfProgTypeCgroupDevice,
+		InsnCnt:  uint32(len(prog)),
+		Insns:    uint64(uintptr(unsafe.Pointer(&prog[0]))),
+		License:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+		LogLevel: 1,
+		LogSize:  uint32(len(logBuf)),
+		LogBuf:   uint64(uintptr(unsafe.Pointer(&logBuf[0]))),
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, bpfProgLoad, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	runtime.KeepAlive(prog)
+	runtime.KeepAlive(license)
+	if errno != 0 {
+		return -1, fmt.Errorf("load bpf device filter fail %v: %s", errno, strings.TrimRight(string(logBuf), "\x00"))
+	}
+	return int(fd), nil
+}
+
+// 将 eBPF 程序 attach 到 cgroup 目录上
+// 不带 BPF_F_ALLOW_MULTI 标志，再次 attach 会替换掉之前的程序，因此 update 时可以重复调用
+func attachDeviceFilter(progFd int, cgroupDir string) error {
+	dirFd, err := unix.Open(cgroupDir, unix.O_DIRECTORY|unix.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open cgroup dir %s fail %v", cgroupDir, err)
+	}
+	defer unix.Close(dirFd)
+
+	attr := bpfProgAttachAttr{
+		TargetFd:    uint32(dirFd),
+		AttachBpfFd: uint32(progFd),
+		AttachType:  bpfCgroupDevice,
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_BPF, bpfProgAttach, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr)); errno != 0 {
+		return fmt.Errorf("attach bpf device filter to %s fail %v", cgroupDir, errno)
+	}
+	return nil
+}
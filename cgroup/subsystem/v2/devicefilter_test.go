@@ -0,0 +1,200 @@
+package v2
+
+import (
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+)
+
+// 每条规则生成的指令数，以及每个跳转指令都跳到块的末尾
+func TestDeviceRuleBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    subsystem.DeviceRule
+		length  int
+		jumps   map[int]int16 // 跳转指令的位置 -> 跳转偏移
+		wantErr bool
+	}{
+		{
+			name:   "all devices",
+			rule:   subsystem.DeviceRule{Type: "a", Major: subsystem.Wildcard, Minor: subsystem.Wildcard, Permissions: "rwm", Allow: true},
+			length: 2,
+			jumps:  map[int]int16{},
+		},
+		// type a 不比较设备号
+		{
+			name:   "all devices with numbers",
+			rule:   subsystem.DeviceRule{Type: "a", Major: 5, Minor: 5, Permissions: "rw", Allow: true},
+			length: 5,
+			jumps:  map[int]int16{2: 2},
+		},
+		{
+			name:   "char device",
+			rule:   subsystem.DeviceRule{Type: "c", Major: 1, Minor: 3, Permissions: "rwm", Allow: true},
+			length: 5,
+			jumps:  map[int]int16{0: 4, 1: 3, 2: 2},
+		},
+		{
+			name:   "wildcard major and minor",
+			rule:   subsystem.DeviceRule{Type: "c", Major: subsystem.Wildcard, Minor: subsystem.Wildcard, Permissions: "m", Allow: true},
+			length: 6,
+			jumps:  map[int]int16{0: 5, 3: 2},
+		},
+		{
+			name:   "wildcard minor deny",
+			rule:   subsystem.DeviceRule{Type: "b", Major: 8, Minor: subsystem.Wildcard, Permissions: "r", Allow: false},
+			length: 7,
+			jumps:  map[int]int16{0: 6, 3: 3, 4: 2},
+		},
+		{
+			name:   "wildcard major",
+			rule:   subsystem.DeviceRule{Type: "c", Major: subsystem.Wildcard, Minor: 7, Permissions: "rwm", Allow: true},
+			length: 4,
+			jumps:  map[int]int16{0: 3, 1: 2},
+		},
+		{name: "invalid type", rule: subsystem.DeviceRule{Type: "x", Permissions: "rwm"}, wantErr: true},
+		{name: "invalid permissions", rule: subsystem.DeviceRule{Type: "c", Permissions: "rx"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block, err := deviceRuleBlock(&test.rule)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("deviceRuleBlock error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("deviceRuleBlock error %v", err)
+			}
+			if len(block) != test.length {
+				t.Fatalf("len(block) = %d, want %d", len(block), test.length)
+			}
+			for i, ins := range block {
+				isJump := ins.Code == bpfJneImm || ins.Code == bpfJneReg || ins.Code == bpfJeqImm
+				off, want := test.jumps[i]
+				if isJump != want {
+					t.Errorf("instruction %d: jump = %v, want %v", i, isJump, want)
+				} else if isJump && ins.Off != off {
+					t.Errorf("instruction %d: offset = %d, want %d", i, ins.Off, off)
+				}
+			}
+			// 块以 r0 = allow; exit 结尾
+			var allow int32
+			if test.rule.Allow {
+				allow = 1
+			}
+			if ret := block[len(block)-2]; ret.Code != bpfMov64Imm || ret.Imm != allow || block[len(block)-1].Code != bpfExit {
+				t.Errorf("block does not end with return %d", allow)
+			}
+		})
+	}
+}
+
+// 按照内核的语义解释执行生成的程序，ctx 为 bpf_cgroup_dev_ctx
+func runDeviceFilter(t *testing.T, prog []bpfInsn, devType, access, major, minor uint64) uint64 {
+	t.Helper()
+	ctx := map[int16]uint64{0: devType | access<<16, 4: major, 8: minor}
+	var regs [11]uint64
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		dst, src := ins.Regs&0xf, ins.Regs>>4
+		switch ins.Code {
+		case bpfLdxMemW:
+			regs[dst] = ctx[ins.Off]
+		case bpfAlu64And:
+			regs[dst] &= uint64(int64(ins.Imm))
+		case bpfAlu64Rsh:
+			regs[dst] >>= uint64(ins.Imm)
+		case bpfMov64Reg:
+			regs[dst] = regs[src]
+		case bpfMov64Imm:
+			regs[dst] = uint64(int64(ins.Imm))
+		case bpfJneImm:
+			if regs[dst] != uint64(int64(ins.Imm)) {
+				pc += int(ins.Off)
+			}
+		case bpfJeqImm:
+			if regs[dst] == uint64(int64(ins.Imm)) {
+				pc += int(ins.Off)
+			}
+		case bpfJneReg:
+			if regs[dst] != regs[src] {
+				pc += int(ins.Off)
+			}
+		case bpfExit:
+			return regs[0]
+		default:
+			t.Fatalf("unknown instruction %#x at %d", ins.Code, pc)
+		}
+	}
+	t.Fatalf("program does not exit")
+	return 0
+}
+
+func TestGenerateDeviceFilter(t *testing.T) {
+	rules := []*subsystem.DeviceRule{
+		{Type: "a", Major: subsystem.Wildcard, Minor: subsystem.Wildcard, Permissions: "rwm", Allow: false},
+		{Type: "c", Major: 1, Minor: 3, Permissions: "rwm", Allow: true},
+		{Type: "c", Major: subsystem.Wildcard, Minor: subsystem.Wildcard, Permissions: "m", Allow: true},
+		{Type: "b", Major: 8, Minor: subsystem.Wildcard, Permissions: "r", Allow: true},
+		// 后面的规则覆盖前面的规则
+		{Type: "c", Major: 1, Minor: 3, Permissions: "w", Allow: false},
+	}
+	prog, err := generateDeviceFilter(rules)
+	if err != nil {
+		t.Fatalf("generateDeviceFilter error %v", err)
+	}
+	// 6 条加载指令 + 每条规则的指令块 + 默认拒绝的 2 条
+	if want := 6 + 2 + 5 + 6 + 7 + 8 + 2; len(prog) != want {
+		t.Errorf("len(prog) = %d, want %d", len(prog), want)
+	}
+
+	tests := []struct {
+		name                          string
+		devType, access, major, minor uint64
+		want                          uint64
+	}{
+		{"read null", bpfDevcgDevChar, bpfDevcgAccRead, 1, 3, 1},
+		{"write null", bpfDevcgDevChar, bpfDevcgAccWrite, 1, 3, 0},
+		{"read write null", bpfDevcgDevChar, bpfDevcgAccRead | bpfDevcgAccWrite, 1, 3, 0},
+		{"mknod any char", bpfDevcgDevChar, bpfDevcgAccMknod, 10, 200, 1},
+		{"read other char", bpfDevcgDevChar, bpfDevcgAccRead, 1, 5, 0},
+		{"read sda", bpfDevcgDevBlock, bpfDevcgAccRead, 8, 0, 1},
+		{"read sdb1", bpfDevcgDevBlock, bpfDevcgAccRead, 8, 17, 1},
+		{"write sda", bpfDevcgDevBlock, bpfDevcgAccWrite, 8, 0, 0},
+		{"read other block", bpfDevcgDevBlock, bpfDevcgAccRead, 9, 0, 0},
+		{"mknod block", bpfDevcgDevBlock, bpfDevcgAccMknod, 8, 0, 0},
+		// 主设备号相同但是类型不同
+		{"read block 1:3", bpfDevcgDevBlock, bpfDevcgAccRead, 1, 3, 0},
+		{"mknod null", bpfDevcgDevChar, bpfDevcgAccMknod, 1, 3, 1},
+	}
+	for _, test := range tests {
+		if got := runDeviceFilter(t, prog, test.devType, test.access, test.major, test.minor); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// 没有规则时拒绝所有设备
+	prog, err = generateDeviceFilter(nil)
+	if err != nil {
+		t.Fatalf("generateDeviceFilter error %v", err)
+	}
+	if len(prog) != 8 || runDeviceFilter(t, prog, bpfDevcgDevChar, bpfDevcgAccRead, 1, 3) != 0 {
+		t.Errorf("empty rules should deny all devices")
+	}
+
+	// 允许所有设备
+	prog, err = generateDeviceFilter([]*subsystem.DeviceRule{{Type: "a", Major: subsystem.Wildcard, Minor: subsystem.Wildcard, Permissions: "rwm", Allow: true}})
+	if err != nil {
+		t.Fatalf("generateDeviceFilter error %v", err)
+	}
+	if runDeviceFilter(t, prog, bpfDevcgDevBlock, bpfDevcgAccWrite, 259, 1) != 1 {
+		t.Errorf("a *:* rwm should allow all devices")
+	}
+
+	if _, err := generateDeviceFilter([]*subsystem.DeviceRule{{Type: "z", Permissions: "r"}}); err == nil {
+		t.Errorf("generateDeviceFilter with invalid rule error = nil")
+	}
+}
@@ -0,0 +1,64 @@
+package v2
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"golang.org/x/sys/unix"
+	"os"
+	"path"
+	"strconv"
+)
+
+type DevicesSubSystem struct{}
+
+func (ds *DevicesSubSystem) Name() string {
+	return "devices"
+}
+
+// v2 没有 devices 相关文件，根据规则生成 eBPF 程序并 attach 到容器 cgroup 上
+func (ds *DevicesSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+		return err
+	} else {
+		if len(resConf.DeviceRules) == 0 {
+			return nil
+		}
+		prog, err := generateDeviceFilter(resConf.DeviceRules)
+		if err != nil {
+			return fmt.Errorf("generate device filter fail %v", err)
+		}
+		progFd, err := loadDeviceFilter(prog)
+		if err != nil {
+			return err
+		}
+		// attach 以后 cgroup 会持有程序的引用，这里可以关闭文件描述符
+		defer unix.Close(progFd)
+		return attachDeviceFilter(progFd, devicesSubSystemCgroupPath)
+	}
+}
+
+func (ds *DevicesSubSystem) Apply(cgroupPath string, pid int) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+		return err
+	} else {
+		if err := os.WriteFile(
+			path.Join(devicesSubSystemCgroupPath, "cgroup.procs"),
+			[]byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+}
+
+func (ds *DevicesSubSystem) Remove(cgroupPath string) error {
+	if devicesSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(devicesSubSystemCgroupPath)
+	}
+}
+
+// devices 没有统计信息
+func (ds *DevicesSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package v2
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+Freeze 冻结或解冻 cgroup 中的所有进程
+cgroup v2 没有 freezer 控制器，由 cgroup 自身的 cgroup.freeze 文件控制
+写入以后冻结是异步完成的，需要轮询 cgroup.events 中的 frozen 字段直到状态生效
+*/
+func Freeze(cgroupPath string, frozen bool) error {
+	cgroupDir, err := GetCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	if err := os.WriteFile(path.Join(cgroupDir, "cgroup.freeze"), []byte(value), 0644); err != nil {
+		return fmt.Errorf("set cgroup freeze %s fail %v", value, err)
+	}
+
+	deadline := time.Now().Add(subsystem.FreezeTimeout)
+	for {
+		current, err := readCgroupEvent(cgroupDir, "frozen")
+		if err != nil {
+			return err
+		}
+		if current == value {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup frozen is still %s, expect %s", current, value)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 读取 cgroup.events 中的某个字段，文件格式为每行一个 key value
+func readCgroupEvent(cgroupDir, key string) (string, error) {
+	file, err := os.Open(path.Join(cgroupDir, "cgroup.events"))
+	if err != nil {
+		return "", fmt.Errorf("open cgroup events fail %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in cgroup events", key)
+}
@@ -0,0 +1,65 @@
+package v2
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+)
+
+// HugetlbSubSystem 限制大页内存的使用
+type HugetlbSubSystem struct{}
+
+func (hs *HugetlbSubSystem) Name() string {
+	return "hugetlb"
+}
+
+// 设置每种大页的使用上限，也就是修改 hugetlb.<大页大小>.max 文件
+func (hs *HugetlbSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	hugetlbSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if len(resConf.HugetlbLimits) == 0 {
+		return nil
+	}
+	// hugetlb 不在默认开启的控制器中，只有需要限制时才在 dockergsh 和它的上一级中开启
+	parentDir := path.Dir(hugetlbSubSystemCgroupPath)
+	for _, dir := range []string{path.Dir(parentDir), parentDir} {
+		if err := enableControllers(dir, []string{"hugetlb"}); err != nil {
+			return fmt.Errorf("enable cgroup hugetlb controller fail %v", err)
+		}
+	}
+	for _, limit := range resConf.HugetlbLimits {
+		file := fmt.Sprintf("hugetlb.%s.max", limit.PageSize)
+		if err := os.WriteFile(path.Join(hugetlbSubSystemCgroupPath, file), []byte(strconv.FormatUint(limit.Limit, 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup %s fail %v", file, err)
+		}
+	}
+	return nil
+}
+
+func (hs *HugetlbSubSystem) Apply(cgroupPath string, pid int) error {
+	if hugetlbSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+		return err
+	} else {
+		if err := os.WriteFile(path.Join(hugetlbSubSystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+}
+
+func (hs *HugetlbSubSystem) Remove(cgroupPath string) error {
+	if hugetlbSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(hugetlbSubSystemCgroupPath)
+	}
+}
+
+// 大页内存没有统计信息
+func (hs *HugetlbSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package v2
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// IoSubSystem cgroup v2 中的 io 控制器，对应 v1 的 blkio
+type IoSubSystem struct{}
+
+func (is *IoSubSystem) Name() string {
+	return "io"
+}
+
+// 设置块设备 IO 的权重和每个设备的读写限速
+func (is *IoSubSystem) Set(cgroupPath string, resConf *subsystem.ResourceConfig) error {
+	ioSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true)
+	if err != nil {
+		return err
+	}
+
+	if resConf.BlkioWeight != 0 {
+		// io.weight 的范围是 1 - 10000，使用 bfq 调度器时只有 io.bfq.weight，范围与 v1 一样是 1 - 1000
+		weightFile := path.Join(ioSubSystemCgroupPath, "io.bfq.weight")
+		value := strconv.FormatUint(uint64(resConf.BlkioWeight), 10)
+		if _, err := os.Stat(path.Join(ioSubSystemCgroupPath, "io.weight")); err == nil {
+			weightFile = path.Join(ioSubSystemCgroupPath, "io.weight")
+			value = fmt.Sprintf("default %d", subsystem.BlkioWeightToIOWeight(resConf.BlkioWeight))
+		}
+		if err := os.WriteFile(weightFile, []byte(value), 0644); err != nil {
+			return fmt.Errorf("set cgroup io weight fail %v", err)
+		}
+	}
+
+	// io.max 每行一个设备，例如 8:0 rbps=1048576，没有写的 key 保持不变
+	throttles := map[string][]*subsystem.ThrottleDevice{
+		"rbps":  resConf.ReadBpsDevice,
+		"wbps":  resConf.WriteBpsDevice,
+		"riops": resConf.ReadIOpsDevice,
+		"wiops": resConf.WriteIOpsDevice,
+	}
+	for key, devices := range throttles {
+		for _, device := range devices {
+			line := fmt.Sprintf("%d:%d %s=%d", device.Major, device.Minor, key, device.Rate)
+			if err := os.WriteFile(path.Join(ioSubSystemCgroupPath, "io.max"), []byte(line), 0644); err != nil {
+				return fmt.Errorf("set cgroup io.max %s fail %v", line, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (is *IoSubSystem) Apply(cgroupPath string, pid int) error {
+	if ioSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+		return err
+	} else {
+		if err := os.WriteFile(path.Join(ioSubSystemCgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+}
+
+func (is *IoSubSystem) Remove(cgroupPath string) error {
+	if ioSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
+		return err
+	} else {
+		return subsystem.RemoveCgroupDir(ioSubSystemCgroupPath)
+	}
+}
+
+/*
+读取 io.stat，统计所有设备的读写字节数，每行一个设备，格式为：
+8:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0
+*/
+func (is *IoSubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	ioSubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path.Join(ioSubSystemCgroupPath, "io.stat"))
+	if err != nil {
+		return fmt.Errorf("open cgroup io stats fail %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 每行的第一个字段是设备号，空行没有字段
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				stats.BlkioRead += value
+			case "wbytes":
+				stats.BlkioWrite += value
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
 )
@@ -20,15 +19,52 @@ func (ms *MemorySubSystem) Set(cgroupPath string, resConf *subsystem.ResourceCon
 	if memorySubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
 		return err
 	} else {
-		// v2 的cpu配置在文件 cpu.max, 格式为 $MAX $PERIOD，表示在一段 PERIOD 时间内，占用了多少
-		if resConf.MemoryLimit != "" {
+		// v2 的内存限制在文件 memory.max 中
+		if resConf.Memory != 0 {
 			if err := ioutil.WriteFile(
 				path.Join(memorySubSystemCgroupPath, "memory.max"),
-				[]byte(resConf.MemoryLimit),
+				[]byte(strconv.FormatInt(resConf.Memory, 10)),
 				0644); err != nil {
 				return fmt.Errorf("set cgroup memory fail %v", err)
 			}
 		}
+		// OOM 时杀死 cgroup 中的所有进程，避免只杀死其中一个进程后容器处于不完整的状态
+		if resConf.OomKillGroup {
+			if err := ioutil.WriteFile(
+				path.Join(memorySubSystemCgroupPath, "memory.oom.group"),
+				[]byte("1"),
+				0644); err != nil {
+				return fmt.Errorf("set cgroup memory oom group fail %v", err)
+			}
+		}
+		// v2 的 memory.swap.max 只限制 swap，不包括内存，因此要减去内存限制
+		if resConf.MemorySwap != 0 {
+			swapMax := "max"
+			if resConf.MemorySwap > 0 {
+				if resConf.Memory == 0 {
+					return fmt.Errorf("memory swap requires a memory limit")
+				}
+				if resConf.MemorySwap < resConf.Memory {
+					return fmt.Errorf("memory swap should be larger than memory limit")
+				}
+				swapMax = strconv.FormatInt(resConf.MemorySwap-resConf.Memory, 10)
+			}
+			if err := ioutil.WriteFile(
+				path.Join(memorySubSystemCgroupPath, "memory.swap.max"),
+				[]byte(swapMax),
+				0644); err != nil {
+				return fmt.Errorf("set cgroup memory swap fail %v", err)
+			}
+		}
+		// 软限制，内存低于 memory.low 时尽量不被回收
+		if resConf.MemoryReservation != 0 {
+			if err := ioutil.WriteFile(
+				path.Join(memorySubSystemCgroupPath, "memory.low"),
+				[]byte(strconv.FormatInt(resConf.MemoryReservation, 10)),
+				0644); err != nil {
+				return fmt.Errorf("set cgroup memory reservation fail %v", err)
+			}
+		}
 		return nil
 	}
 }
@@ -48,9 +84,34 @@ func (ms *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 }
 
 func (ms *MemorySubSystem) Remove(cgroupPath string) error {
-	if memorySubSystemCgroupPath, err := GetCgroupPath(cgroupPath, true); err != nil {
+	if memorySubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false); err != nil {
 		return err
 	} else {
-		return os.RemoveAll(memorySubSystemCgroupPath)
+		return subsystem.RemoveCgroupDir(memorySubSystemCgroupPath)
+	}
+}
+
+// 读取内存使用量、内存限制、page cache 和 OOM 次数
+func (ms *MemorySubSystem) Stats(cgroupPath string, stats *subsystem.Stats) error {
+	memorySubSystemCgroupPath, err := GetCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.MemoryUsage, err = subsystem.ReadUint(memorySubSystemCgroupPath, "memory.current"); err != nil {
+		return err
+	}
+	if stats.MemoryLimit, err = subsystem.ReadUint(memorySubSystemCgroupPath, "memory.max"); err != nil {
+		return err
+	}
+	memoryStat, err := subsystem.ReadKeyValues(memorySubSystemCgroupPath, "memory.stat")
+	if err != nil {
+		return err
+	}
+	stats.MemoryCache = memoryStat["file"]
+	memoryEvents, err := subsystem.ReadKeyValues(memorySubSystemCgroupPath, "memory.events")
+	if err != nil {
+		return err
 	}
+	stats.OomKills = memoryEvents["oom_kill"]
+	return nil
 }
@@ -1,75 +1,169 @@
 package v2
 
 import (
+	"errors"
 	"fmt"
-	log "github.com/sirupsen/logrus"
-	"io"
-	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
-)
+	"syscall"
 
-var (
-	CgroupV2RootPathPrefix string = "/sys/fs/cgroup/dockergsh"
-	Root                   string
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 )
 
+// CgroupRoot cgroup v2 统一 hierarchy 的挂载目录，测试时可以指向一个普通目录模拟 cgroupfs
+var CgroupRoot = "/sys/fs/cgroup"
+
+// ProcCgroupFile 当前进程所在的 cgroup，测试时可以指向一个普通文件
+var ProcCgroupFile = "/proc/self/cgroup"
+
+// 容器的 cgroup 需要使用的控制器，hugetlb 只有在需要限制时才开启
+var defaultControllers = []string{"cpu", "cpuset", "memory", "io", "pids"}
+
+// 开启控制器前存放 cgroup 中原有进程的叶子 cgroup
+const leafCgroup = "init"
+
+/*
+ParentPath 返回 dockergsh 目录的绝对路径，所有容器的 cgroup 都在这个目录下
+1. 默认在根 cgroup 下，也就是 CgroupRoot/dockergsh
+2. 普通用户只能修改 systemd 委派给自己的 user@<uid>.service，当前进程在自己的 user@<uid>.service 中时，dockergsh 目录放在这个 cgroup 下
+不直接使用当前进程所在的 cgroup，每次登录的 session 所在的 cgroup 都不同，停止容器时要能找到创建时的 cgroup
+*/
+func ParentPath() string {
+	return path.Join(delegatedCgroup(), "dockergsh")
+}
+
+// 从 /proc/self/cgroup 中查找当前用户的 user@<uid>.service，找不到时返回根 cgroup
+func delegatedCgroup() string {
+	content, err := os.ReadFile(ProcCgroupFile)
+	if err != nil {
+		return CgroupRoot
+	}
+	service := fmt.Sprintf("user@%d.service", os.Geteuid())
+	for _, line := range strings.Split(string(content), "\n") {
+		// cgroup v2 的记录格式为 0::<path>
+		cgroupPath, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			continue
+		}
+		parts := strings.Split(cgroupPath, "/")
+		for i, part := range parts {
+			if part != service {
+				continue
+			}
+			// 没有 cgroup namespace 的容器中，路径是宿主机上的路径，在挂载的 cgroupfs 中不存在
+			dir := path.Join(CgroupRoot, path.Join(parts[:i+1]...))
+			if _, err := os.Stat(dir); err == nil {
+				return dir
+			}
+			return CgroupRoot
+		}
+	}
+	return CgroupRoot
+}
+
 // 获取 cgroup 的绝对路径
 // 注意 cgroup v2 版本，已经把所有的 hierarchy 都统一到 根下，因此只有一个 hierarchy。
-// 因此 所有的 subsystem 都有统一的路径，与 v1 不同
+// 因此 所有的 subsystem 都有统一的路径，与 v1 不同，容器的 cgroup 统一放在 dockergsh 目录下
 func GetCgroupPath(cgroupPath string, autoCreate bool) (string, error) {
-	var cgroupRoot string
+	parentDir := ParentPath()
+	cgroupDir := path.Join(parentDir, cgroupPath)
 
-	if cgroupPath != "" {
-		Root = FindCgroupMountpoint()
-		cgroupRoot = CgroupV2RootPathPrefix
-	} else {
-		cgroupRoot = Root
-		log.Infof("Cgroup Root: %s", cgroupPath)
+	// os.Stat返回描述文件 f 的 FileInfo 类型值。如果出错，错误底层类型是 *PathError
+	_, err := os.Stat(cgroupDir)
+	if err == nil {
+		return cgroupDir, nil
+	}
+	if !autoCreate || !os.IsNotExist(err) {
+		return "", fmt.Errorf("cgroup path error %v", err)
 	}
 
-	// os.Stat返回描述文件 f 的 FileInfo 类型值。如果出错，错误底层类型是 *PathError
-	_, err := os.Stat(path.Join(cgroupRoot, cgroupPath))
+	// 子 cgroup 只能使用父 cgroup 的 cgroup.subtree_control 中开启的控制器，
+	// 因此 dockergsh 的上一级（根 cgroup 或者 user@<uid>.service）和 dockergsh 都要开启，容器的 cgroup 中才有对应的配置文件
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return "", fmt.Errorf("error create cgroup %v", err)
+	}
+	for _, dir := range []string{path.Dir(parentDir), parentDir} {
+		if err := enableControllers(dir, defaultControllers); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Mkdir(cgroupDir, 0755); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("error create cgroup %v", err)
+	}
+	return cgroupDir, nil
+}
 
-	// 如果目录不存在就创建
-	if err == nil || (autoCreate && os.IsNotExist(err)) {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(path.Join(cgroupRoot, cgroupPath), 0755); err != nil {
-				return "", fmt.Errorf("error create cgroup %v", err)
-			}
-			if err := ioutil.WriteFile(
-				path.Join(cgroupRoot, "cgroup.subtree_control"),
-				[]byte("+cpu +cpuset +memory +io +pids"),
-				0644); err != nil {
-				return "", fmt.Errorf("set cgroup subtree_control fail %v", err)
+/*
+在 cgroup 的 cgroup.subtree_control 中开启子 cgroup 需要的控制器
+- 只开启 cgroup.controllers 中列出的控制器，已经开启的不再重复写入
+- 除了根 cgroup，有进程的 cgroup 不能开启控制器（no internal processes），内核返回 EBUSY，
+  例如 dockergsh 运行在容器中时，cgroup namespace 的根就是 dockergsh 自己所在的 cgroup，
+  这时先把其中的进程移动到叶子 cgroup init 中，再开启控制器
+*/
+func enableControllers(cgroupDir string, controllers []string) error {
+	// 模拟的 cgroupfs 中没有 cgroup.controllers，认为所有控制器都可用
+	if content, err := os.ReadFile(path.Join(cgroupDir, "cgroup.controllers")); err == nil {
+		controllers = intersect(controllers, strings.Fields(string(content)))
+	}
+	if content, err := os.ReadFile(path.Join(cgroupDir, "cgroup.subtree_control")); err == nil {
+		enabled := strings.Fields(string(content))
+		var missing []string
+		for _, controller := range controllers {
+			if len(intersect([]string{controller}, enabled)) == 0 {
+				missing = append(missing, controller)
 			}
 		}
-		return path.Join(cgroupRoot, cgroupPath), nil
-	} else {
-		return "", fmt.Errorf("cgroup path error %v", err)
+		controllers = missing
+	}
+	if len(controllers) == 0 {
+		return nil
 	}
-}
 
-// FindCgroupMountPoint("memory"),这里返回具体某个 cgroup 挂载的根路径
-func FindCgroupMountpoint() string {
-	// 获取 /proc/self/cgroup 当前用户的 cgroup 相对路径
-	file, err := os.Open("/proc/self/cgroup")
+	subtreeControl := []byte("+" + strings.Join(controllers, " +"))
+	err := os.WriteFile(path.Join(cgroupDir, "cgroup.subtree_control"), subtreeControl, 0644)
+	if errors.Is(err, syscall.EBUSY) {
+		if err = moveProcsToLeaf(cgroupDir); err != nil {
+			return err
+		}
+		err = os.WriteFile(path.Join(cgroupDir, "cgroup.subtree_control"), subtreeControl, 0644)
+	}
 	if err != nil {
-		return ""
+		return fmt.Errorf("set cgroup %s subtree_control fail %v", cgroupDir, err)
 	}
+	return nil
+}
 
-	defer file.Close()
-
-	text, err := io.ReadAll(file)
+// 把 cgroup 中的所有进程（包括 dockergsh 自己）移动到叶子 cgroup init 中
+func moveProcsToLeaf(cgroupDir string) error {
+	leafDir := path.Join(cgroupDir, leafCgroup)
+	if err := os.Mkdir(leafDir, 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("error create cgroup %v", err)
+	}
+	pids, err := subsystem.ReadCgroupProcs(cgroupDir)
 	if err != nil {
-		return ""
+		return err
 	}
+	for _, pid := range pids {
+		// 进程可能已经退出
+		if err := os.WriteFile(path.Join(leafDir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("move process %d to cgroup %s fail %v", pid, leafDir, err)
+		}
+	}
+	return nil
+}
 
-	// 获取到 /user.slice/user-0.slice/session-11.scope ，将最后的 session 路径去掉
-	split_fields := strings.Split(string(text), ":")
-	pathArr := strings.Split(split_fields[len(split_fields)-1], "/")
-	userCgroupPath := strings.Join(pathArr[0:len(pathArr)-1], "/")
-
-	return CgroupV2RootPathPrefix + userCgroupPath
+// 返回 a 中同时在 b 中的元素，保持 a 的顺序
+func intersect(a, b []string) []string {
+	var result []string
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				result = append(result, x)
+				break
+			}
+		}
+	}
+	return result
 }
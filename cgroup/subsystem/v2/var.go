@@ -7,5 +7,9 @@ var (
 		&CpuSubSystem{},
 		&CpuSetSubSystem{},
 		&MemorySubSystem{},
+		&DevicesSubSystem{},
+		&PidsSubSystem{},
+		&IoSubSystem{},
+		&HugetlbSubSystem{},
 	}
 )
\ No newline at end of file
@@ -0,0 +1,44 @@
+package cmdExec
+
+import (
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/image"
+	"github.com/Nevermore12321/dockergsh/network"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+cleanupContainer 释放容器占用的资源，容器退出（monitor 进程、-it 模式）、stop 和 rm 时都会调用，重复调用是安全的：
+1. 删除容器的 cgroup，已经删除的跳过
+2. 删除网络端点并释放 ip，释放后清空 IpAddress，避免再次释放已经分配给其他容器的 ip
+3. 卸载 volume 和 merge 层，已经卸载的跳过
+remove 为 true 时，还会删除容器的可写层和记录信息，否则由调用方保存 info 的修改
+*/
+func cleanupContainer(info *container.ContainerInfo, remove bool) {
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+	if err := cgroupManager.Destroy(); err != nil {
+		log.Errorf("Destroy container %s cgroup error %v", info.Id, err)
+	}
+
+	if info.Network != "" && info.IpAddress != "" {
+		if err := network.Init(); err != nil {
+			log.Errorf("network init failed: %v", err)
+		}
+		if err := network.DisconnectNetwork(info); err != nil {
+			log.Errorf("Disconnect container %s from network %s error %v", info.Id, info.Network, err)
+		} else {
+			info.IpAddress = ""
+		}
+	}
+
+	mergeURL := info.RootUrl + "/merge"
+	_ = container.DeleteVolume(info.Volume, mergeURL)
+	_ = image.DeleteMountPoint(mergeURL)
+
+	if remove {
+		deleteContainerInfo(info.Id)
+		_ = image.DeleteWriteLayer(info.RootUrl)
+	}
+}
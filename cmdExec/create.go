@@ -0,0 +1,144 @@
+package cmdExec
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/network"
+	"github.com/Nevermore12321/dockergsh/volume"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContainerOptions run 和 create 的参数解析出的容器配置
+type ContainerOptions struct {
+	Command     []string
+	Image       string
+	Name        string
+	Volume      string // -v 参数，命名 volume 和匿名 volume 在创建容器时解析
+	Env         []string
+	Network     string
+	Devices     []*container.Device
+	Resources   *subsystem.ResourceConfig
+	ShmSize     int64
+	Init        bool
+	StopSignal  string
+	CgroupNs    string
+	Healthcheck *container.HealthConfig
+	Restart     string
+	Labels      map[string]string
+	AutoRemove  bool
+	Hooks       *container.Hooks // --hook 指定的 hook，hooks 目录中的 hook 在创建容器时加入
+}
+
+// CreateContainer 创建容器但不启动，输出容器 id，之后通过 dockergsh start 启动
+func CreateContainer(opts *ContainerOptions) error {
+	if err := verifyResourceConfig(opts.Resources); err != nil {
+		return err
+	}
+	if err := validateContainerName(opts.Name); err != nil {
+		return err
+	}
+	containerInfo, err := createContainer(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(containerInfo.Id)
+	return nil
+}
+
+/*
+创建容器，容器处于 created 状态，还没有运行任何进程：
+1. 解析 -v 参数，创建命名 volume 或者匿名 volume，准备容器的 rootfs，挂载 overlay 和 volume
+2. 记录容器信息，创建容器名的软链接
+3. 创建容器的 cgroup 并设置资源限制
+4. 从网络中为容器分配 ip
+*/
+func createContainer(opts *ContainerOptions) (*container.ContainerInfo, error) {
+	// 没有指定容器名时，生成一个随机的容器名
+	containerName := opts.Name
+	if containerName == "" {
+		name, err := generateContainerName()
+		if err != nil {
+			return nil, err
+		}
+		containerName = name
+	}
+
+	// hooks 目录中的 hook 在 --hook 指定的 hook 之前执行，之后修改 hooks 目录不影响已经创建的容器
+	containerHooks, err := container.LoadHooksDir(container.HooksDir)
+	if err != nil {
+		return nil, err
+	}
+	containerHooks.Merge(opts.Hooks)
+	if containerHooks.Empty() {
+		containerHooks = nil
+	}
+
+	// 命名 volume 和匿名 volume 解析为宿主机上的数据目录，匿名 volume 在这里创建，因此只在创建容器的进程中解析
+	volume, err := volume.ResolveSpec(opts.Volume)
+	if err != nil {
+		return nil, err
+	}
+
+	// containerInit 包含容器初始化时需要记录的一些信息
+	containerInit := container.NewContainerInit(opts.Image, volume)
+	containerInfo := &container.ContainerInfo{
+		Name:        containerName,
+		Id:          containerInit.Id,
+		Image:       opts.Image,
+		Command:     strings.Join(opts.Command, " "),
+		Args:        opts.Command,
+		Env:         opts.Env,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		Status:      container.CREATED,
+		RootUrl:     containerInit.RootUrl,
+		Volume:      volume,
+		Devices:     opts.Devices,
+		DeviceRules: opts.Resources.DeviceRules,
+		Resources:   opts.Resources,
+		Init:        opts.Init,
+		ShmSize:     opts.ShmSize,
+		CgroupNs:    opts.CgroupNs,
+		StopSignal:  opts.StopSignal,
+		Healthcheck: opts.Healthcheck,
+		Restart:     opts.Restart,
+		Labels:      opts.Labels,
+		AutoRemove:  opts.AutoRemove,
+		Hooks:       containerHooks,
+	}
+	// 将 Container 详情写入到 文件 config.json 中
+	if err := recordContainerInfo(containerInfo); err != nil {
+		log.Errorf("Record container info error %v", err)
+		// 容器记录没有写入，只需要释放 rootfs 和匿名 volume
+		container.DeleteWorkSpace(true, volume, containerInit.MergeUrl, containerInit.RootUrl)
+		removeAnonymousVolume(volume)
+		return nil, err
+	}
+	emitStateEvent(containerInfo, "create", "")
+
+	// use dockergsh as cgroup name
+	if err := cgroup.NewCgroupManager(containerInit.IdBase).Set(opts.Resources); err != nil {
+		log.Errorf("set cgroup resource failed: %v", err)
+	}
+
+	// 网络端点需要容器的 network namespace，start 时才创建，这里先分配 ip
+	if opts.Network != "" {
+		if err := network.Init(); err != nil {
+			log.Errorf("network init failed: %v", err)
+		}
+		if err := network.AllocateIp(opts.Network, containerInfo); err != nil {
+			log.Errorf("Allocate ip from network %s error %v", opts.Network, err)
+			autoRemoveContainer(containerInfo)
+			return nil, err
+		}
+		if err := UpdateContainerInfo(containerInfo); err != nil {
+			autoRemoveContainer(containerInfo)
+			return nil, err
+		}
+	}
+	return containerInfo, nil
+}
@@ -0,0 +1,137 @@
+package cmdExec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/internal/events"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
+)
+
+/*
+StreamEvents 输出 since 和 until 之间的生命周期事件：
+1. 没有指定 since 时从当前时间开始，只输出新的事件，不回放已有的事件
+2. 没有指定 until 时，输出已有的事件后继续输出新的事件，直到用户中断
+3. filterArgs 支持 type、event、container、network、volume 和 label
+4. format 为 json 时，每个事件输出一行 json
+*/
+func StreamEvents(since, until string, filterArgs filters.Args, format string) error {
+	if format != "" && format != "json" {
+		return fmt.Errorf("unsupported format %s, only json is supported", format)
+	}
+	now := time.Now()
+	sinceTime, untilTime := now, time.Time{}
+	var err error
+	if since != "" {
+		if sinceTime, err = parseEventTime(since, now); err != nil {
+			return err
+		}
+	}
+	if until != "" {
+		if untilTime, err = parseEventTime(until, now); err != nil {
+			return err
+		}
+	}
+
+	return events.Stream(sinceTime, untilTime, until == "", nil, func(event *events.Event) error {
+		if !matchEvent(filterArgs, event) {
+			return nil
+		}
+		return printEvent(event, format)
+	})
+}
+
+/*
+解析 --since 和 --until，支持以下格式：
+1. RFC3339，例如 2006-01-02T15:04:05Z07:00
+2. 与容器创建时间相同的 2006-01-02 15:04:05，使用本地时区
+3. unix 时间戳，可以带小数部分，例如 1136214245.5
+4. 相对于当前时间的时长，例如 10m、1h30m，表示 10 分钟前、1 个半小时前
+*/
+func parseEventTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		whole, fraction := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(fraction*1e9)), nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, it should be RFC3339, a unix timestamp or a duration like 10m", value)
+}
+
+// 判断事件是否满足所有过滤条件，同一个 key 指定多次时满足其中一个即可
+func matchEvent(filterArgs filters.Args, event *events.Event) bool {
+	if !filterArgs.Match("type", event.Type) || !filterArgs.Match("event", event.Action) {
+		return false
+	}
+	if values := filterArgs.Get("container"); len(values) > 0 && !matchEventContainer(values, event) {
+		return false
+	}
+	if values := filterArgs.Get("network"); len(values) > 0 && (event.Type != events.NetworkEventType || !filterArgs.Match("network", event.Id)) {
+		return false
+	}
+	if values := filterArgs.Get("volume"); len(values) > 0 && (event.Type != events.VolumeEventType || !filterArgs.Match("volume", event.Id)) {
+		return false
+	}
+	return filterArgs.MatchLabels(event.Attributes)
+}
+
+// 容器可能已经被删除，按照事件中记录的容器 id、id 前缀和容器名匹配
+func matchEventContainer(values []string, event *events.Event) bool {
+	// 网络和 volume 事件中，容器 id 记录在 attributes 中
+	containerId := event.Attributes["container"]
+	if event.Type == events.ContainerEventType {
+		containerId = event.Id
+	}
+	if containerId == "" {
+		return false
+	}
+	for _, value := range values {
+		if strings.HasPrefix(containerId, value) {
+			return true
+		}
+		if event.Type == events.ContainerEventType && event.Attributes["name"] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 输出一个事件，默认格式与 docker 一致：时间 类型 事件名 id (key=value, ...)
+func printEvent(event *events.Event, format string) error {
+	if format == "json" {
+		content, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	line := fmt.Sprintf("%s %s %s %s", time.Unix(0, event.Time).Format(time.RFC3339Nano), event.Type, event.Action, event.Id)
+	if len(event.Attributes) > 0 {
+		keys := make([]string, 0, len(event.Attributes))
+		for key := range event.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		attributes := make([]string, 0, len(keys))
+		for _, key := range keys {
+			attributes = append(attributes, fmt.Sprintf("%s=%s", key, event.Attributes[key]))
+		}
+		line += fmt.Sprintf(" (%s)", strings.Join(attributes, ", "))
+	}
+	fmt.Println(line)
+	return nil
+}
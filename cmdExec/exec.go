@@ -1,60 +1,272 @@
 package cmdExec
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
 	_ "github.com/Nevermore12321/dockergsh/nsenter"
+	"github.com/Nevermore12321/dockergsh/utils"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	ENV_EXEC_PID = "dockergsh_pid"
-	ENV_EXEC_CMD = "dockergsh_cmd"
+	ENV_EXEC_PID   = "dockergsh_pid"
+	ENV_EXEC_PIDFD = "dockergsh_pidfd"
 )
 
-func ExecInContainer(containerArg string, commandArr []string) error {
+/*
+ExecInContainer 在运行中的容器里执行命令，返回命令的退出码
+这里是 实现 docker exec 的关键，再次通过执行 /proc/self/exe exec 启动 exec 进程：
+1. 通过环境变量 ENV_EXEC_PID 让 nsenter 的 C 代码在 go runtime 启动之前执行，C 代码先阻塞等待父进程
+2. 父进程把 exec 进程加入容器的 cgroup，然后通过管道发送一个字节，再发送 json 格式的 ExecConfig
+3. C 代码进入容器的所有 namespace，chroot 到容器的根目录，再 fork 出子进程，子进程由 container.RunExecProcess 执行用户命令
+每次 exec 都会在容器目录下记录 ExecInfo，-d 模式与 run -d 一样由后台的 monitor 进程等待命令退出并记录退出码
+*/
+func ExecInContainer(containerArg string, commandArr, envSlice []string, workDir, user string, detach bool) (int, error) {
+	// -d 模式下当前进程等 monitor 报告 exec 的启动结果后就返回
+	monitor := isMonitorProcess()
+	if detach && !monitor {
+		return 0, spawnMonitor()
+	}
+
+	execInfo, cmd, err := startExec(containerArg, commandArr, envSlice, workDir, user, detach)
+	if monitor {
+		var execId, logPath string
+		if err == nil {
+			execId = execInfo.Id
+			logPath = filepath.Join(execInfo.rootURL, container.MonitorLogFile)
+		}
+		notifyMonitorReady(execId, logPath, err)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	// Ctrl-C 由容器中的命令处理，当前进程等待命令退出后返回它的退出码
+	signal.Ignore(syscall.SIGINT, syscall.SIGQUIT)
+	defer signal.Reset(syscall.SIGINT, syscall.SIGQUIT)
+	exitCode := 0
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return -1, err
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	execInfo.Running = false
+	execInfo.ExitCode = exitCode
+	execInfo.FinishTime = time.Now().Format("2006-01-02 15:04:05")
+	if err := writeExecInfo(execInfo.rootURL, &execInfo.ExecInfo); err != nil {
+		logrus.Errorf("Record exec %s exit error %v", execInfo.Id, err)
+	}
+	return exitCode, nil
+}
+
+// 正在运行的 exec，rootURL 为所属容器的根目录
+type execSession struct {
+	container.ExecInfo
+	rootURL string
+}
+
+// 启动 exec 进程，进入容器后记录 exec 信息
+func startExec(containerArg string, commandArr, envSlice []string, workDir, user string, detach bool) (*execSession, *exec.Cmd, error) {
 	// 根据命令行传递的容器名或者容器id 获取要 exec 容器的 pid
-	pid, err := GetContainerPidByArg(containerArg)
+	info, err := GetContainerInfoByArg(containerArg)
 	if err != nil {
-		logrus.Errorf("Get Container %s pid err error %v", containerArg, err)
-		return err
+		logrus.Errorf("Get Container %s Info err error %v", containerArg, err)
+		return nil, nil, err
+	}
+	// 先检查状态，没有运行的容器不创建 execs 目录和 exec 日志
+	if err := requireState(info, "exec in", container.RUNNING); err != nil {
+		return nil, nil, err
+	}
+	session := &execSession{
+		ExecInfo: container.ExecInfo{
+			Id:          utils.NewId(),
+			ContainerId: info.Id,
+			Command:     commandArr,
+			User:        user,
+			WorkDir:     workDir,
+			Detach:      detach,
+			Running:     true,
+			CreateTime:  time.Now().Format("2006-01-02 15:04:05"),
+			BootId:      info.BootId,
+		},
+		rootURL: info.RootUrl,
+	}
+	if err := os.MkdirAll(filepath.Join(info.RootUrl, container.ExecsDir), 0755); err != nil {
+		return nil, nil, fmt.Errorf("mkdir execs dir error %v", err)
 	}
 
-	// 将命令 commandArr 以空格分割，然后放入环境变量 ENV_EXEC_CMD 中
-	cmdStr := strings.Join(commandArr, " ")
-	logrus.Infof("container pid %s", pid)
-	logrus.Infof("command %s", cmdStr)
+	stdin, stdout, stderr := io.Reader(os.Stdin), io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if detach {
+		// -d 模式下没有终端，输出写到 exec 自己的日志中
+		logFile, err := os.OpenFile(container.ExecLogPath(info.RootUrl, session.Id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open exec log error %v", err)
+		}
+		defer logFile.Close()
+		stdin, stdout, stderr = nil, logFile, logFile
+	}
 
-	// 这里是 实现 docker exec 的关键
-	// 再次通过执行 /proc/self/exe 文件，来再次执行一次 dockergsh
-	// 传入的参数为 exec，也就是再次执行了 dockergsh exec 命令
-	// 但这次执行会带入 环境变量 ENV_EXEC_PID 和 ENV_EXEC_CMD
-	cmd := exec.Command("/proc/self/exe", "exec")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	execConfig := &container.ExecConfig{
+		Args: commandArr,
+		Env:  envSlice,
+		Cwd:  workDir,
+		User: user,
+	}
+	cmd, pid, err := spawnExec(info, execConfig, stdin, stdout, stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.Pid = pid
+	if session.StartTime, err = container.ProcessStartTime(pid); err != nil {
+		logrus.Warnf("Get exec %s process start time error %v", session.Id, err)
+	}
+	if err := writeExecInfo(info.RootUrl, &session.ExecInfo); err != nil {
+		logrus.Errorf("Record exec %s error %v", session.Id, err)
+	}
+	emitContainerEvent(info, "exec_start", map[string]string{"execID": session.Id, "execCommand": strings.Join(commandArr, " ")})
+	return session, cmd, nil
+}
 
-	// 传入环境变量，用来控制让 C 代码开始执行
-	_ = os.Setenv(ENV_EXEC_CMD, cmdStr)
-	_ = os.Setenv(ENV_EXEC_PID, pid)
+// 启动 exec 进程并进入容器，返回 exec 进程和用户命令在宿主机上的 pid，execConfig.Env 会与容器的环境变量合并
+func spawnExec(info *container.ContainerInfo, execConfig *container.ExecConfig, stdin io.Reader, stdout, stderr io.Writer) (*exec.Cmd, int, error) {
+	if err := requireState(info, "exec in", container.RUNNING); err != nil {
+		return nil, 0, err
+	}
+	// 校验 pid 对应的确实是容器的主进程，避免进入无关进程的 namespace
+	process, err := container.OpenProcess(info)
+	if err != nil {
+		logrus.Errorf("Open container %s process error %v", info.Id, err)
+		return nil, 0, err
+	}
+	defer process.Close()
+	pid := info.Pid
 
-	// 关键点，每次在 exec 到容器中时，要和容器启动时的环境变量一致
-	// 这里调用了 cgo 方法，直接调用linux setns 系统调用，因此继承的是宿主机的环境变量，这一步就是将容器内进程的环境变量加入到 cgo 进程中
+	// 关键点，每次在 exec 到容器中时，要和容器启动时的环境变量一致，-e 指定的环境变量覆盖容器的环境变量
 	containerEnvs, err := GetEnvsByPid(pid)
 	if err != nil {
 		logrus.Errorf("Get Envs error %v", err)
-		return err
+		return nil, 0, err
 	}
-	cmd.Env = append(os.Environ(), containerEnvs...)
+	execConfig.Env = mergeEnvs(containerEnvs, execConfig.Env)
+
+	readPipe, writePipe, err := utils.NewPipe()
+	if err != nil {
+		return nil, 0, fmt.Errorf("new pipe error %v", err)
+	}
+	defer writePipe.Close()
+	pidReadPipe, pidWritePipe, err := utils.NewPipe()
+	if err != nil {
+		readPipe.Close()
+		return nil, 0, fmt.Errorf("new pipe error %v", err)
+	}
+	defer pidReadPipe.Close()
+
+	cmd := exec.Command("/proc/self/exe", "exec")
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// 传入环境变量，用来控制让 C 代码开始执行，用户命令的环境变量通过 ExecConfig 传递
+	cmd.Env = []string{ENV_EXEC_PID + "=" + pid}
+	// readPipe 在子进程中为 fd 3，pidWritePipe 为 fd 4
+	cmd.ExtraFiles = []*os.File{readPipe, pidWritePipe}
+
+	// 内核支持 pidfd 时，把 pidfd 作为 fd 5 传给子进程，C 代码通过 pidfd 进入容器的 namespace
+	if process.Fd() >= 0 {
+		// dup 一份，os.File 关闭时不会影响 process 持有的 pidfd
+		fd, err := syscall.Dup(process.Fd())
+		if err != nil {
+			readPipe.Close()
+			pidWritePipe.Close()
+			return nil, 0, fmt.Errorf("dup pidfd error %v", err)
+		}
+		pidfdFile := os.NewFile(uintptr(fd), "pidfd")
+		defer pidfdFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, pidfdFile)
+		cmd.Env = append(cmd.Env, ENV_EXEC_PIDFD+"=5")
+	}
+
+	err = cmd.Start()
+	readPipe.Close()
+	pidWritePipe.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("start exec process error %v", err)
+	}
+
+	// 加入容器的 cgroup 后再通知 C 代码继续，exec 的命令受容器的资源限制
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+	if err := cgroupManager.Apply(cmd.Process.Pid); err != nil {
+		killExecProcess(cmd)
+		return nil, 0, fmt.Errorf("apply exec process to container cgroup error %v", err)
+	}
+	if err := sendExecConfig(writePipe, execConfig); err != nil {
+		killExecProcess(cmd)
+		return nil, 0, err
+	}
+
+	// C 代码 fork 之后报告用户命令的 pid，进入容器失败时管道直接关闭
+	pidBytes, err := io.ReadAll(pidReadPipe)
+	if err != nil || len(pidBytes) == 0 {
+		_ = cmd.Wait()
+		return nil, 0, fmt.Errorf("exec process failed to enter container %s", info.Id)
+	}
+	execPid, err := strconv.Atoi(string(pidBytes))
+	if err != nil {
+		killExecProcess(cmd)
+		return nil, 0, fmt.Errorf("invalid exec pid %q", pidBytes)
+	}
+	return cmd, execPid, nil
+}
+
+func killExecProcess(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+}
+
+// 先发送一个字节让 C 代码继续执行，再发送 ExecConfig，关闭管道后子进程读取结束
+func sendExecConfig(writePipe *os.File, execConfig *container.ExecConfig) error {
+	msg, err := json.Marshal(execConfig)
+	if err != nil {
+		return fmt.Errorf("marshal exec config error %v", err)
+	}
+	if _, err := writePipe.Write(append([]byte{0}, msg...)); err != nil {
+		return fmt.Errorf("write exec config error %v", err)
+	}
+	return writePipe.Close()
+}
 
-	if err := cmd.Run(); err != nil {
-		logrus.Errorf("Exec container %s error %v", containerArg, err)
-		return err
+// 合并环境变量，后面的同名环境变量覆盖前面的
+func mergeEnvs(base, overrides []string) []string {
+	index := make(map[string]int)
+	var envs []string
+	for _, kv := range append(base, overrides...) {
+		if kv == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[key]; ok {
+			envs[i] = kv
+			continue
+		}
+		index[key] = len(envs)
+		envs = append(envs, kv)
 	}
-	return nil
+	return envs
 }
 
 func GetContainerPidByArg(containerArg string) (string, error) {
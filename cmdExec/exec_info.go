@@ -0,0 +1,156 @@
+package cmdExec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	log "github.com/sirupsen/logrus"
+)
+
+// exec id 由 utils.NewId 生成，查找前先检查格式，避免 glob 的通配符和路径分隔符
+var execIdRegex = regexp.MustCompile(`^[0-9a-z]{16}$`)
+
+// 记录 exec 信息，与 config.json 一样先写临时文件再 rename
+func writeExecInfo(rootURL string, execInfo *container.ExecInfo) error {
+	infoBytes, err := json.Marshal(execInfo)
+	if err != nil {
+		return err
+	}
+	infoPath := container.ExecInfoPath(rootURL, execInfo.Id)
+	tmpPath := infoPath + ".tmp"
+	if err := os.WriteFile(tmpPath, infoBytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, infoPath)
+}
+
+func readExecInfo(infoPath string) (*container.ExecInfo, error) {
+	content, err := os.ReadFile(infoPath)
+	if err != nil {
+		return nil, err
+	}
+	var execInfo container.ExecInfo
+	if err := json.Unmarshal(content, &execInfo); err != nil {
+		return nil, err
+	}
+	// 记录 exec 的进程异常退出时来不及记录退出码，进程已经不存在的按照已退出显示
+	if execInfo.Running {
+		process, err := container.OpenExecProcess(&execInfo)
+		var staleErr *container.StaleProcessError
+		if errors.As(err, &staleErr) {
+			execInfo.Running = false
+			execInfo.ExitCode = -1
+		} else if err == nil {
+			process.Close()
+		}
+	}
+	return &execInfo, nil
+}
+
+// 读取容器的所有 exec 记录，按照创建时间排序
+func listExecInfos(rootURL string) ([]*container.ExecInfo, error) {
+	infoPaths, err := filepath.Glob(filepath.Join(rootURL, container.ExecsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var execInfos []*container.ExecInfo
+	for _, infoPath := range infoPaths {
+		execInfo, err := readExecInfo(infoPath)
+		if err != nil {
+			log.Errorf("Read exec info %s error %v", infoPath, err)
+			continue
+		}
+		execInfos = append(execInfos, execInfo)
+	}
+	sort.SliceStable(execInfos, func(i, j int) bool {
+		return execInfos[i].CreateTime < execInfos[j].CreateTime
+	})
+	return execInfos, nil
+}
+
+// ListExecs 输出容器的所有 exec
+func ListExecs(containerArg string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	execInfos, err := listExecInfos(info.RootUrl)
+	if err != nil {
+		return err
+	}
+
+	// 格式化输出
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "EXEC ID\tPID\tSTATUS\tCOMMAND\tCREATED\n")
+	for _, execInfo := range execInfos {
+		status := "running"
+		if !execInfo.Running {
+			status = fmt.Sprintf("exited (%d)", execInfo.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			execInfo.Id,
+			execInfo.Pid,
+			status,
+			strings.Join(execInfo.Command, " "),
+			execInfo.CreateTime)
+	}
+	return w.Flush()
+}
+
+// InspectExec 以 json 格式输出 exec 的详细信息，exec id 在所有容器中查找
+func InspectExec(execId string) error {
+	if !execIdRegex.MatchString(execId) {
+		return fmt.Errorf("invalid exec id %q", execId)
+	}
+	infoPaths, err := filepath.Glob(container.ExecInfoPath(filepath.Join(container.DefaultFsURL, "*"), execId))
+	if err != nil {
+		return err
+	}
+	if len(infoPaths) == 0 {
+		return fmt.Errorf("no such exec: %s", execId)
+	}
+	execInfo, err := readExecInfo(infoPaths[0])
+	if err != nil {
+		return err
+	}
+
+	infoBytes, err := json.MarshalIndent(execInfo, "", "    ")
+	if err != nil {
+		log.Errorf("Json marshal %s error %v", execInfo.Id, err)
+		return err
+	}
+	fmt.Println(string(infoBytes))
+	return nil
+}
+
+// 向容器中所有正在运行的 exec 发送信号，容器停止时调用，避免 exec 的命令残留在容器的 namespace 中
+func signalExecs(info *container.ContainerInfo, sig syscall.Signal) {
+	execInfos, err := listExecInfos(info.RootUrl)
+	if err != nil {
+		log.Errorf("List container %s execs error %v", info.Id, err)
+		return
+	}
+	for _, execInfo := range execInfos {
+		if !execInfo.Running {
+			continue
+		}
+		process, err := container.OpenExecProcess(execInfo)
+		if err != nil {
+			continue
+		}
+		if err := process.Signal(sig); err != nil && err != syscall.ESRCH {
+			log.Errorf("Signal exec %s error %v", execInfo.Id, err)
+		}
+		process.Close()
+	}
+}
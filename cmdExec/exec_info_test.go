@@ -0,0 +1,24 @@
+package cmdExec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/utils"
+)
+
+// 不是 exec id 格式的输入不会作为 glob 的模式
+func TestInspectExecInvalidId(t *testing.T) {
+	tests := []string{"", "*", "?", "[a-z]*", "../abc", "abc/def", "1TE1WXZ0OID14DHP", "1te1wxz0oid14dh", "1te1wxz0oid14dhpq"}
+
+	for _, execId := range tests {
+		err := InspectExec(execId)
+		if err == nil || !strings.Contains(err.Error(), "invalid exec id") {
+			t.Errorf("InspectExec(%q) error = %v, want invalid exec id", execId, err)
+		}
+	}
+
+	if id := utils.NewId(); !execIdRegex.MatchString(id) {
+		t.Errorf("exec id %q generated by NewId does not match %s", id, execIdRegex)
+	}
+}
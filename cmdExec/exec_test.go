@@ -0,0 +1,56 @@
+package cmdExec
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 后面的同名环境变量覆盖前面的，位置保持第一次出现的位置
+func TestMergeEnvs(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      []string
+		overrides []string
+		want      []string
+	}{
+		{
+			name:      "override",
+			base:      []string{"PATH=/bin", "HOME=/root", "LANG=C"},
+			overrides: []string{"HOME=/home/app", "DEBUG=1"},
+			want:      []string{"PATH=/bin", "HOME=/home/app", "LANG=C", "DEBUG=1"},
+		},
+		{
+			name:      "last override wins",
+			base:      []string{"A=1"},
+			overrides: []string{"A=2", "A=3"},
+			want:      []string{"A=3"},
+		},
+		{
+			name: "duplicated base",
+			base: []string{"A=1", "B=2", "A=4"},
+			want: []string{"A=4", "B=2"},
+		},
+		{
+			name:      "empty value and no value",
+			base:      []string{"A=1", "B=2"},
+			overrides: []string{"A=", "B"},
+			want:      []string{"A=", "B"},
+		},
+		{
+			name:      "skip empty",
+			base:      []string{"", "A=1"},
+			overrides: []string{""},
+			want:      []string{"A=1"},
+		},
+		{
+			name: "nil",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := mergeEnvs(tt.base, tt.overrides); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeEnvs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,13 @@
 package cmdExec
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/container"
-	"github.com/Nevermore12321/dockergsh/utils"
+	"github.com/Nevermore12321/dockergsh/store"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
-	"path/filepath"
 )
 
 func LogContainer(containerArg string) error {
@@ -43,47 +43,18 @@ func LogContainer(containerArg string) error {
 }
 
 // GetContainerInfoByArg 用户在使用时，可以传入容器名称，也可以传入容器的id
-// GetContainerInfoByArg 通过判断是容器名还是 容器id，返回查到的 容器信息  ContainerInfo
+// GetContainerInfoByArg 依次按照完整 id、容器名和唯一的 id 前缀查找，返回查到的 容器信息  ContainerInfo
 func GetContainerInfoByArg(containerArg string) (*container.ContainerInfo, error) {
-	var containerInfo *container.ContainerInfo
-
-	// 先在 named_containers 目录下找有没有容器，判断该容器有没有命名
-	// 表示有名字的容器
-	namedContainersUrl := fmt.Sprintf(container.DefaultInfoLocation, container.NamedContainersDir)
-	files, err := os.ReadDir(namedContainersUrl)
-	if err != nil {
-		logrus.Errorf("Read container base folder %s error %v", namedContainersUrl, err)
-		return nil, err
-	}
-	// idFlag 表示 containerArg 表示的是否是 id
-	for _, file := range files {
-		// 如果是 containerName
-		if file.Name() == containerArg {
-			configURL := filepath.Join(namedContainersUrl, file.Name(), container.ConfigName)
-			// 读取配置文件 config.json
-			containerInfo, err = GetContainerInfo(configURL)
-			if err != nil {
-				if err != os.ErrInvalid {
-					logrus.Errorf("Get container info by name error %v", err)
-					return nil, err
-				}
-			}
-			break
+	containerId, err := store.Resolve(containerArg)
+	if err == nil {
+		var containerInfo *container.ContainerInfo
+		if containerInfo, err = store.Get(containerId); err == nil {
+			return containerInfo, nil
 		}
 	}
-	if containerInfo == nil {
-		hashId := utils.EncodeSha256([]byte(containerArg))
-		containerURL := fmt.Sprintf(container.DefaultInfoLocation, hashId)
-		configURL := filepath.Join(containerURL, container.ContainerConfigPath, container.ConfigName)
-		containerInfo, err = GetContainerInfo(configURL)
-		if err != nil {
-			if err != os.ErrInvalid {
-				logrus.Errorf("Get container info by id error %v", err)
-				return nil, err
-			}
-		}
+	if errors.Is(err, store.ErrNotExist) {
+		return nil, fmt.Errorf("no such container: %s", containerArg)
 	}
-
-	return containerInfo, nil
-
+	logrus.Errorf("Get container info by %s error %v", containerArg, err)
+	return nil, err
 }
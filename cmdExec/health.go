@@ -0,0 +1,199 @@
+package cmdExec
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// 健康检查输出最多保留的字节数
+	healthOutputLimit = 4096
+	// 因为不健康停止容器时，等待容器退出的时间，超时后发送 SIGKILL
+	unhealthyStopTimeout = 10
+)
+
+/*
+healthMonitor 在 monitor 进程中定期通过 exec 在容器中执行健康检查命令：
+1. 容器启动后状态为 starting，检查成功变为 healthy，连续失败 Retries 次变为 unhealthy
+2. StartPeriod 内的失败不计入连续失败次数
+3. 重启策略为 on-unhealthy 时，容器变为 unhealthy 后停止容器，由 monitor 进程重新启动
+*/
+type healthMonitor struct {
+	containerId string
+	config      *container.HealthConfig
+	restart     bool
+	startedAt   time.Time
+
+	stopCh    chan struct{}
+	done      chan struct{}
+	unhealthy bool // 是否因为不健康停止了容器，run 退出后才能读取
+}
+
+// 开始健康检查，容器没有配置健康检查时返回 nil
+func startHealthMonitor(info *container.ContainerInfo) *healthMonitor {
+	if info.Healthcheck == nil || len(info.Healthcheck.Test) == 0 {
+		return nil
+	}
+	monitor := &healthMonitor{
+		containerId: info.Id,
+		config:      info.Healthcheck,
+		restart:     info.Restart == container.RestartOnUnhealthy,
+		startedAt:   time.Now(),
+		stopCh:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go monitor.run()
+	return monitor
+}
+
+// 停止健康检查，返回容器是否因为不健康被停止，nil 也可以调用
+func (m *healthMonitor) Stop() bool {
+	if m == nil {
+		return false
+	}
+	close(m.stopCh)
+	<-m.done
+	return m.unhealthy
+}
+
+func (m *healthMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := GetContainerInfoByArg(m.containerId)
+		if err != nil {
+			log.Errorf("Get container %s info error %v", m.containerId, err)
+			continue
+		}
+		if info.Status != container.RUNNING {
+			continue
+		}
+		result := runHealthProbe(info, m.config)
+		// 检查期间容器信息可能被其他命令修改，加锁读取最新的记录后再记录结果
+		var status, previous string
+		if info, err = store.Update(m.containerId, func(info *container.ContainerInfo) error {
+			if info.Health != nil {
+				previous = info.Health.Status
+			}
+			status = m.recordResult(info, result)
+			return nil
+		}); err != nil {
+			log.Errorf("Update container %s health error %v", m.containerId, err)
+			continue
+		}
+		// 健康状态变化时记录事件
+		if status != previous {
+			emitContainerEvent(info, "health_status", map[string]string{"health_status": status})
+		}
+
+		if status == container.HealthUnhealthy && m.restart {
+			log.Warnf("Container %s is unhealthy, stopping it for restart", info.Id)
+			m.unhealthy = true
+			process, err := container.OpenProcess(info)
+			if err != nil {
+				log.Errorf("Open container %s process error %v", info.Id, err)
+				continue
+			}
+			if err := stopProcess(info, process, unhealthyStopTimeout, ""); err != nil {
+				log.Errorf("Stop unhealthy container %s error %v", info.Id, err)
+			}
+			process.Close()
+			return
+		}
+	}
+}
+
+// 记录一次检查的结果，返回新的健康状态
+func (m *healthMonitor) recordResult(info *container.ContainerInfo, result *container.HealthResult) string {
+	if info.Health == nil {
+		info.Health = &container.Health{Status: container.HealthStarting}
+	}
+	health := info.Health
+	health.Log = append(health.Log, result)
+	if len(health.Log) > container.HealthLogSize {
+		health.Log = health.Log[len(health.Log)-container.HealthLogSize:]
+	}
+
+	if result.ExitCode == 0 {
+		health.FailingStreak = 0
+		health.Status = container.HealthHealthy
+		return health.Status
+	}
+	// 启动期间还没有成功过，失败不计数
+	if health.Status == container.HealthStarting && time.Since(m.startedAt) < m.config.StartPeriod {
+		return health.Status
+	}
+	health.FailingStreak++
+	if health.FailingStreak >= m.config.Retries {
+		health.Status = container.HealthUnhealthy
+	}
+	return health.Status
+}
+
+// 在容器中执行一次健康检查命令，超时后杀死检查命令
+func runHealthProbe(info *container.ContainerInfo, config *container.HealthConfig) *container.HealthResult {
+	result := &container.HealthResult{Start: time.Now().Format(time.RFC3339Nano)}
+	output := &limitedBuffer{limit: healthOutputLimit}
+
+	cmd, pid, err := spawnExec(info, &container.ExecConfig{Args: config.Test}, nil, output, output)
+	if err != nil {
+		result.ExitCode = -1
+		result.Output = err.Error()
+		result.End = time.Now().Format(time.RFC3339Nano)
+		return result
+	}
+	// 检查命令在后台启动的进程可能一直占用输出管道，命令退出后不再等待
+	cmd.WaitDelay = time.Second
+
+	timer := time.AfterFunc(config.Timeout, func() {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	})
+	err = cmd.Wait()
+	timedOut := !timer.Stop()
+
+	var exitErr *exec.ExitError
+	switch {
+	case timedOut:
+		result.ExitCode = -1
+		output.WriteString("Health check exceeded timeout (" + config.Timeout.String() + ")")
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil && !errors.Is(err, exec.ErrWaitDelay):
+		result.ExitCode = -1
+		output.WriteString(err.Error())
+	}
+	result.Output = output.String()
+	result.End = time.Now().Format(time.RFC3339Nano)
+	return result
+}
+
+// 只保留前 limit 个字节的 buffer，超出的部分丢弃
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.Len(); remain > 0 {
+		if len(p) > remain {
+			b.Buffer.Write(p[:remain])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,96 @@
+package cmdExec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// hook 没有指定 timeout 时的超时时间，避免卡住的 hook 让容器一直无法启动
+	defaultHookTimeout = 30 * time.Second
+	// 错误信息中保留的 hook 输出的长度
+	hookOutputLimit = 4096
+)
+
+/*
+依次执行容器某个阶段的 hook，有一个 hook 失败就停止执行并返回错误：
+1. 通过 stdin 把 OCI 格式的容器状态传给 hook，status 为 created、running 或 stopped
+2. 每个 hook 在超时后被杀死，hook 的输出只在失败时出现在错误信息中
+prestart、createRuntime 失败时容器启动失败，poststart、poststop 失败时只输出警告
+*/
+func runHooks(info *container.ContainerInfo, stage, status string, pid int) error {
+	hooks := info.Hooks.Stage(stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+	state, err := json.Marshal(&container.OciState{
+		OciVersion:  container.OciVersion,
+		Id:          info.Id,
+		Status:      status,
+		Pid:         pid,
+		Bundle:      info.RootUrl,
+		Rootfs:      filepath.Join(info.RootUrl, "merge"),
+		Annotations: info.Labels,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range hooks {
+		log.Infof("Run %s hook %s of container %s", stage, hooks[i].Path, info.Id)
+		if err := runHook(&hooks[i], state); err != nil {
+			return fmt.Errorf("%s hook %s of container %s error %v", stage, hooks[i].Path, info.Id, err)
+		}
+	}
+	return nil
+}
+
+// 执行一个 hook，与 OCI 规范一致，hook 的环境变量只有 hook.Env
+func runHook(hook *container.Hook, state []byte) error {
+	timeout := defaultHookTimeout
+	if hook.Timeout != nil {
+		timeout = time.Duration(*hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+	// Env 为 nil 时会继承当前进程的环境变量
+	cmd.Env = append([]string{}, hook.Env...)
+	cmd.Stdin = bytes.NewReader(state)
+	output := &limitedBuffer{limit: hookOutputLimit}
+	cmd.Stdout, cmd.Stderr = output, output
+	// 超时后杀死 hook 的整个进程组，包括 hook 启动的子进程
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// hook 在后台启动的进程可能一直占用输出管道，hook 退出后不再等待
+	cmd.WaitDelay = time.Second
+
+	// hook 在超时的同时正常退出时，以 hook 的结果为准
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	if message := strings.TrimSpace(output.String()); message != "" {
+		return fmt.Errorf("%v: %s", err, message)
+	}
+	return err
+}
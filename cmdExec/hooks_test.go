@@ -0,0 +1,127 @@
+package cmdExec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+)
+
+// 在临时目录中写一个 shell 脚本作为 hook
+func writeHookScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	hookPath := filepath.Join(dir, name)
+	if err := os.WriteFile(hookPath, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return hookPath
+}
+
+// 进程不存在或者已经是僵尸进程
+func processGone(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	hookPath := writeHookScript(t, dir, "record", `cat > "$1"; echo >> "$1"; echo "$2 $HOOK_ENV $HOME" >> "$1"`)
+	info := &container.ContainerInfo{
+		Id:      "abc123",
+		RootUrl: "/var/lib/dockergsh/abc",
+		Labels:  map[string]string{"app": "web"},
+		Hooks: &container.Hooks{
+			Poststart: []container.Hook{{Path: hookPath, Args: []string{"record", output, "arg"}, Env: []string{"HOOK_ENV=yes"}}},
+		},
+	}
+
+	if err := runHooks(info, container.HookPoststart, "running", 42); err != nil {
+		t.Fatalf("runHooks error %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateLine, argsLine, _ := strings.Cut(strings.TrimSpace(string(content)), "\n")
+	var state container.OciState
+	if err := json.Unmarshal([]byte(stateLine), &state); err != nil {
+		t.Fatalf("hook stdin %q error %v", stateLine, err)
+	}
+	if state.Id != "abc123" || state.Status != "running" || state.Pid != 42 || state.Rootfs != "/var/lib/dockergsh/abc/merge" || state.Annotations["app"] != "web" {
+		t.Errorf("hook state = %+v", state)
+	}
+	// 环境变量只有 hook.Env，不继承当前进程的 HOME
+	if argsLine != "arg yes" {
+		t.Errorf("hook args and env = %q, want %q", argsLine, "arg yes")
+	}
+
+	// 没有这个阶段的 hook 时什么都不做
+	if err := runHooks(info, container.HookPoststop, "stopped", 0); err != nil {
+		t.Errorf("runHooks without hooks error %v", err)
+	}
+}
+
+func TestRunHookError(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "second-ran")
+	failing := writeHookScript(t, dir, "fail", "echo 'bad config' >&2; exit 3")
+	second := writeHookScript(t, dir, "second", "touch "+marker)
+	info := &container.ContainerInfo{
+		Id: "abc123",
+		Hooks: &container.Hooks{
+			Prestart: []container.Hook{{Path: failing}, {Path: second}},
+		},
+	}
+
+	err := runHooks(info, container.HookPrestart, "created", 1)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: bad config") || !strings.Contains(err.Error(), failing) {
+		t.Errorf("runHooks error = %v, want the exit status and output of %s", err, failing)
+	}
+	// 一个 hook 失败后不再执行后面的 hook
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("second hook ran after the first failed")
+	}
+}
+
+// 超时后 hook 和它启动的子进程都被杀死
+func TestRunHookTimeout(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	hookPath := writeHookScript(t, dir, "hang", `sleep 30 & echo $! > `+pidFile+`; wait`)
+	timeout := 1
+
+	start := time.Now()
+	err := runHook(&container.Hook{Path: hookPath, Timeout: &timeout}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Fatalf("runHook error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runHook returned after %v", elapsed)
+	}
+
+	content, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d of the hook is still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,27 @@
+package cmdExec
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// InspectContainer 以 json 格式输出容器的详细信息，包括 cgroup 设备白名单等
+func InspectContainer(containerArg string) error {
+	containerInfo, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	if containerInfo == nil {
+		return fmt.Errorf("no such container: %s", containerArg)
+	}
+
+	infoBytes, err := json.MarshalIndent(containerInfo, "", "    ")
+	if err != nil {
+		log.Errorf("Json marshal %s error %v", containerInfo.Id, err)
+		return err
+	}
+	fmt.Println(string(infoBytes))
+	return nil
+}
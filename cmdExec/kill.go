@@ -0,0 +1,40 @@
+package cmdExec
+
+import (
+	"strconv"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/signal"
+	log "github.com/sirupsen/logrus"
+)
+
+// KillContainer 向容器的主进程发送信号，不等待容器退出
+func KillContainer(containerArg string, signalName string) error {
+	sig, err := signal.ParseSignal(signalName)
+	if err != nil {
+		return err
+	}
+
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	// 被冻结的进程无法处理信号，暂停的容器需要先 unpause
+	if err := requireState(info, "kill", container.RUNNING); err != nil {
+		return err
+	}
+
+	process, err := container.OpenProcess(info)
+	if err != nil {
+		log.Errorf("Open container %s process error %v", info.Id, err)
+		return err
+	}
+	defer process.Close()
+	if err = process.Signal(sig); err != nil {
+		log.Errorf("Send signal %v to container %s error %v", sig, info.Id, err)
+		return err
+	}
+	emitContainerEvent(info, "kill", map[string]string{"signal": strconv.Itoa(int(sig))})
+	return nil
+}
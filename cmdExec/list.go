@@ -1,43 +1,20 @@
 package cmdExec
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
+	"github.com/Nevermore12321/dockergsh/store"
 	log "github.com/sirupsen/logrus"
 	"os"
-	"path/filepath"
 	"text/tabwriter"
 )
 
-func ListContainers() {
-	// /var/lib/dockergsh/
-	rootURL := fmt.Sprintf(container.DefaultInfoLocation, "")
-	rootURL = rootURL[:len(rootURL)-1]
-	files, err := os.ReadDir(rootURL)
+// ListContainers 输出所有满足 filterArgs 的容器
+func ListContainers(filterArgs filters.Args) {
+	containers, err := listContainerInfos()
 	if err != nil {
-		log.Errorf("Read dir %s error %v", rootURL, err)
-	}
-
-	// 每个容器的 containerinfo
-	var containers []*container.ContainerInfo
-
-	// 遍历每一个容器
-	for _, file := range files {
-		if file.Name() == "network" || file.Name() == "images" || file.Name() == "containers" || file.Name() == "named_containers" {
-			continue
-		}
-
-		configURL := filepath.Join(rootURL, file.Name(), "container", container.ConfigName)
-		// 读取配置文件 config.json
-		info, err := GetContainerInfo(configURL)
-		if err != nil {
-			if err != os.ErrInvalid {
-				log.Errorf("Get container info error %v", err)
-			}
-			continue
-		}
-		containers = append(containers, info)
+		return
 	}
 
 	// 格式化输出
@@ -49,11 +26,19 @@ func ListContainers() {
 	}
 
 	for _, item := range containers {
+		if !filterArgs.MatchLabels(item.Labels) {
+			continue
+		}
+		// 运行中的容器配置了健康检查时，与 docker 一样在状态后面显示健康状态
+		status := string(item.Status)
+		if item.Status == container.RUNNING && item.Health != nil {
+			status = fmt.Sprintf("%s (%s)", item.Status, item.Health.Status)
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
 			item.Id,
 			item.Name,
 			item.Pid,
-			item.Status,
+			status,
 			item.Command,
 			item.CreateTime)
 	}
@@ -64,23 +49,12 @@ func ListContainers() {
 	}
 }
 
-/*
-读取对应 container 的配置文件 config.json ，并且解析为 ContainerInfo 结构体返回
-*/
-func GetContainerInfo(configURL string) (*container.ContainerInfo, error) {
-	// 读取配置文件
-	content, err := os.ReadFile(configURL)
+// 读取所有容器的 containerinfo
+func listContainerInfos() ([]*container.ContainerInfo, error) {
+	containers, err := store.List()
 	if err != nil {
-		log.Errorf("Read file %s error %v", configURL, err)
+		log.Errorf("List containers error %v", err)
 		return nil, err
 	}
-
-	var containerInfo container.ContainerInfo
-	// 将配置文件解析为结构体
-	if err := json.Unmarshal(content, &containerInfo); err != nil {
-		log.Errorf("Json unmarshal error %v", err)
-		return nil, err
-	}
-
-	return &containerInfo, nil
+	return containers, nil
 }
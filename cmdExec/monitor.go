@@ -0,0 +1,176 @@
+package cmdExec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+后台容器（非 -it）和 exec -d 的 monitor 进程，以容器为例：
+1. dockergsh run 重新执行一遍自己，并设置环境变量 dockergsh_monitor，新进程就是 monitor 进程
+2. monitor 进程创建容器，容器进程是 monitor 的子进程，创建完成后通过管道（fd 3）把结果报告给 dockergsh run
+3. dockergsh run 收到报告后打印容器 id 退出，monitor 进程继续在后台等待容器退出，并记录容器的退出码
+*/
+const ENV_MONITOR = "dockergsh_monitor"
+
+// monitor 进程向 dockergsh run 报告的容器创建结果
+type monitorReport struct {
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// 当前进程是否是 monitor 进程
+func isMonitorProcess() bool {
+	return os.Getenv(ENV_MONITOR) != ""
+}
+
+// 以相同的参数启动 monitor 进程，并等待 monitor 报告容器的创建结果
+func spawnMonitor() error {
+	readPipe, writePipe, err := utils.NewPipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error %v", err)
+	}
+	defer readPipe.Close()
+
+	cmd := exec.Command("/proc/self/exe", os.Args[1:]...)
+	cmd.Env = append(os.Environ(), ENV_MONITOR+"=1")
+	cmd.ExtraFiles = []*os.File{writePipe}
+	// 创建新的会话，脱离当前终端，dockergsh run 退出后 monitor 进程不受影响
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		writePipe.Close()
+		return fmt.Errorf("start monitor process error %v", err)
+	}
+	// 当前进程只读管道，关闭写端，monitor 异常退出时读端才能读到 EOF
+	writePipe.Close()
+	defer cmd.Process.Release()
+
+	var report monitorReport
+	if err := json.NewDecoder(readPipe).Decode(&report); err != nil {
+		return fmt.Errorf("monitor process exited before the container started: %v", err)
+	}
+	if report.Error != "" {
+		return errors.New(report.Error)
+	}
+	fmt.Println(report.Id)
+	return nil
+}
+
+// monitor 进程向 dockergsh run/exec 报告容器或者 exec 的创建结果，之后 monitor 的日志写到 logPath
+func notifyMonitorReady(id, logPath string, startErr error) {
+	pipe := os.NewFile(uintptr(3), "pipe")
+	defer pipe.Close()
+
+	var report monitorReport
+	if startErr != nil {
+		report.Error = startErr.Error()
+	} else {
+		report.Id = id
+	}
+	if err := json.NewEncoder(pipe).Encode(&report); err != nil {
+		log.Errorf("Report %s to parent command error %v", report.Id, err)
+	}
+
+	if startErr != nil {
+		return
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Errorf("Open monitor log %s error %v", logPath, err)
+		return
+	}
+	log.SetOutput(logFile)
+}
+
+// 容器主进程退出后，释放容器的资源，并记录容器的退出码和退出时间
+func recordContainerExit(containerId string, state *os.ProcessState) error {
+	exitCode := state.ExitCode()
+	// 被信号杀死的进程，与 shell 保持一致，退出码为 128 + 信号值
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		exitCode = 128 + int(status.Signal())
+	}
+	log.Infof("Container %s exited with code %d", containerId, exitCode)
+
+	// 加锁后读取、释放资源并写回，stop 等命令同时修改容器记录时不会互相覆盖
+	// 事件在写入容器记录之后再记录
+	var from container.State
+	var oomKilled bool
+	info, err := store.Update(containerId, func(info *container.ContainerInfo) error {
+		// 容器已经被 start 重新启动过，退出的是之前的进程，不能覆盖新进程的记录
+		if info.Pid != "" && info.Pid != strconv.Itoa(state.Pid()) {
+			log.Warnf("Container %s is running with pid %s, ignore the exit of pid %d", containerId, info.Pid, state.Pid())
+			return errStaleExit
+		}
+		// cgroup 删除之前检查容器中是否有进程被 OOM killer 杀死
+		if stats, err := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id))).Stats(); err == nil && stats.OomKills > 0 {
+			oomKilled = true
+		}
+		// 容器退出后立即释放 cgroup、网络和挂载点，与记录退出码一起写入容器信息
+		cleanupContainer(info, false)
+
+		info.ExitCode = exitCode
+		info.FinishTime = time.Now().Format("2006-01-02 15:04:05")
+		// stop 命令已经把状态改成 stopped 的话，保留 stopped
+		if !info.Status.Alive() {
+			return nil
+		}
+		info.Pid = ""
+		from = info.Status
+		return transition(info, "die", container.EXIT)
+	})
+	if err != nil {
+		if errors.Is(err, errStaleExit) {
+			return nil
+		}
+		log.Errorf("Record container %s exit error %v", containerId, err)
+		return err
+	}
+	if oomKilled {
+		emitContainerEvent(info, "oom", nil)
+	}
+	if from != "" {
+		emitStateEvent(info, "die", from)
+	}
+
+	// 容器的资源已经释放，执行 poststop hook
+	if err := runHooks(info, container.HookPoststop, "stopped", 0); err != nil {
+		log.Warnf("%v", err)
+	}
+	return nil
+}
+
+// 退出的是容器之前的进程，不修改容器记录
+var errStaleExit = errors.New("stale container process exited")
+
+// --rm 的容器退出后被 monitor 进程删除
+var errContainerRemoved = errors.New("container has been removed")
+
+// 等待 monitor 进程记录容器的退出码，超时后返回当前的容器信息，容器已经被删除时返回 errContainerRemoved
+func waitExitRecorded(containerId string, timeout time.Duration) (*container.ContainerInfo, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := store.Get(containerId)
+		if err != nil {
+			if errors.Is(err, store.ErrNotExist) {
+				return nil, errContainerRemoved
+			}
+			return nil, err
+		}
+		if info.FinishTime != "" || time.Now().After(deadline) {
+			return info, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
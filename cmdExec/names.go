@@ -0,0 +1,79 @@
+package cmdExec
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Nevermore12321/dockergsh/pkg/namesgenerator"
+	"github.com/Nevermore12321/dockergsh/store"
+	log "github.com/sirupsen/logrus"
+)
+
+// 与 docker 一致，容器名只能包含字母、数字和 _.-
+var containerNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// exec 的子命令名（包括 cli 自带的 help），作为容器名时无法区分子命令和容器名
+var reservedContainerNames = map[string]bool{"ls": true, "inspect": true, "help": true}
+
+// 生成默认容器名时最多重试的次数
+const maxNameRetry = 10
+
+// 检查容器名是否合法并且没有被使用，在创建任何资源之前调用，name 为空时跳过
+func validateContainerName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if err := checkContainerName(name); err != nil {
+		return err
+	}
+	inUse, err := store.NameInUse(name)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("the container name %q is already in use", name)
+	}
+	return nil
+}
+
+// 检查容器名的格式，并且不能与 exec 的子命令同名
+func checkContainerName(name string) error {
+	if !containerNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	if reservedContainerNames[name] {
+		return fmt.Errorf("the container name %q is reserved", name)
+	}
+	return nil
+}
+
+// 没有指定 --name 时，使用 namesgenerator 生成一个没有被使用的容器名
+func generateContainerName() (string, error) {
+	for retry := 0; retry < maxNameRetry; retry++ {
+		name := namesgenerator.GetRandomName(retry)
+		inUse, err := store.NameInUse(name)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("generate container name failed after %d retries", maxNameRetry)
+}
+
+// RenameContainer 修改容器名，容器名的索引和容器记录在 store 的索引锁下一起修改，两个并发的 rename 只有一个能成功
+func RenameContainer(containerArg, newName string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	if newName == info.Name {
+		return fmt.Errorf("renaming a container with the same name as its current name")
+	}
+	if err := checkContainerName(newName); err != nil {
+		return err
+	}
+	return store.Rename(info.Id, newName)
+}
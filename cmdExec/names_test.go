@@ -0,0 +1,40 @@
+package cmdExec
+
+import (
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+)
+
+func TestValidateContainerName(t *testing.T) {
+	useTempStore(t)
+	if err := store.Create(&container.ContainerInfo{Id: "abc123", Name: "web", Status: container.EXIT}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"", false},
+		{"db", false},
+		{"my_app.1", false},
+		{"web", true},
+		{"-web", true},
+		{"a", true},
+		{"ls", true},
+		{"inspect", true},
+		{"help", true},
+	}
+	for _, test := range tests {
+		err := validateContainerName(test.name)
+		if (err != nil) != test.wantErr {
+			t.Errorf("validateContainerName(%q) error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+
+	// 重命名时同样不能使用 exec 的子命令名
+	if err := RenameContainer("web", "ls"); err == nil {
+		t.Errorf("RenameContainer(web, ls) should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package cmdExec
+
+import (
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// PauseContainer 通过 cgroup freezer 冻结容器中的所有进程
+func PauseContainer(containerArg string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+
+	// 冻结和修改状态在容器记录的锁中完成，与 stop 等命令互斥
+	info, err = store.Update(info.Id, func(info *container.ContainerInfo) error {
+		if err := checkTransition(info, "pause", container.PAUSED); err != nil {
+			return err
+		}
+
+		// 确认容器进程还是启动时的进程，避免冻结一个已经失效的 cgroup
+		process, err := container.OpenProcess(info)
+		if err != nil {
+			log.Errorf("Open container %s process error %v", info.Id, err)
+			return err
+		}
+		process.Close()
+
+		if err = freezeContainer(info, true); err != nil {
+			log.Errorf("Pause container %s error %v", info.Id, err)
+			// 冻结失败或者超时时，cgroup 可能停在 FREEZING 状态，部分进程已经被冻结，容器状态仍然是 running，需要解冻
+			if thawErr := freezeContainer(info, false); thawErr != nil {
+				log.Errorf("Thaw container %s error %v", info.Id, thawErr)
+			}
+			return err
+		}
+		return transition(info, "pause", container.PAUSED)
+	})
+	if err != nil {
+		return err
+	}
+	// 只有运行中的容器可以暂停
+	emitStateEvent(info, "pause", container.RUNNING)
+	return nil
+}
+
+// UnpauseContainer 解冻容器中的所有进程
+func UnpauseContainer(containerArg string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+
+	info, err = store.Update(info.Id, func(info *container.ContainerInfo) error {
+		// stopped、exited 等状态也可以转换到 running，unpause 只允许暂停的容器
+		if err := requireState(info, "unpause", container.PAUSED); err != nil {
+			return err
+		}
+		if err := freezeContainer(info, false); err != nil {
+			log.Errorf("Unpause container %s error %v", info.Id, err)
+			return err
+		}
+		return transition(info, "unpause", container.RUNNING)
+	})
+	if err != nil {
+		return err
+	}
+	emitStateEvent(info, "unpause", container.PAUSED)
+	return nil
+}
+
+// 冻结或解冻容器的 cgroup
+func freezeContainer(info *container.ContainerInfo, frozen bool) error {
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+	return cgroupManager.Freeze(frozen)
+}
@@ -0,0 +1,288 @@
+package cmdExec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/network"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// 记录上一次一致性检查时宿主机的 boot id
+	bootIdFile = "boot_id"
+	// 正在创建的容器还没有写入 config.json，最近修改过的容器目录不当作残留
+	orphanGracePeriod = time.Minute
+)
+
+// /var/lib/dockergsh 下不是容器的目录和文件
+var reservedEntries = map[string]bool{
+	"network":                    true,
+	"images":                     true,
+	"containers":                 true,
+	"volumes":                    true,
+	container.NamedContainersDir: true,
+	container.HooksDirName:       true,
+	bootIdFile:                   true,
+}
+
+// SystemPrune 检查并修复异常退出后残留的容器目录、软链接、cgroup、veth 设备和 ip，dryRun 时只输出不修复
+func SystemPrune(dryRun bool) error {
+	problems, err := reconcile(dryRun)
+	for _, problem := range problems {
+		if dryRun {
+			fmt.Printf("Would fix: %s\n", problem)
+		} else {
+			fmt.Printf("Fixed: %s\n", problem)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+	return nil
+}
+
+/*
+ReconcileAfterReboot 每个命令执行前检查是否需要做一次一致性检查，以下情况需要检查：
+1. 宿主机重启后（boot id 变化或者没有记录 boot id），容器进程、cgroup、veth 和挂载点都已经不存在了，但是容器记录仍然是 running，ip 也没有释放
+2. 没有重启，但是有进程已经不存在的 running 容器，或者 create 中途退出留下的容器目录
+检查在索引的排他锁下进行，多个命令同时发现问题时只有一个会修复，修复期间其他命令不能创建和删除容器
+*/
+func ReconcileAfterReboot() {
+	bootId, err := container.BootId()
+	if err != nil {
+		log.Warnf("Get boot id error %v", err)
+		return
+	}
+	if !needsReconcile(bootId) {
+		return
+	}
+	err = store.WithIndexLock(func() error {
+		// 等锁期间其他命令可能已经修复过了
+		if !needsReconcile(bootId) {
+			return nil
+		}
+		problems, err := reconcile(false)
+		for _, problem := range problems {
+			log.Infof("Reconcile: %s", problem)
+		}
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(container.DefaultFsURL, bootIdFile), []byte(bootId), 0644)
+	})
+	if err != nil {
+		log.Errorf("Reconcile error %v", err)
+	}
+}
+
+// 宿主机是否重启过，或者是否有进程已经不存在的容器和残留的容器目录，只检查不修复
+func needsReconcile(bootId string) bool {
+	lastBootId, err := os.ReadFile(filepath.Join(container.DefaultFsURL, bootIdFile))
+	if err != nil || strings.TrimSpace(string(lastBootId)) != bootId {
+		return true
+	}
+
+	rootURL := filepath.Clean(container.DefaultFsURL)
+	entries, err := os.ReadDir(rootURL)
+	if err != nil {
+		return false
+	}
+	ids, err := store.Ids()
+	if err != nil {
+		return false
+	}
+	indexedDirs := make(map[string]bool)
+	for id := range ids {
+		indexedDirs[utils.EncodeSha256([]byte(id))] = true
+	}
+	if orphans, _ := pruneOrphanDirs(rootURL, entries, indexedDirs, true); len(orphans) > 0 {
+		return true
+	}
+	for id := range ids {
+		info, err := store.Get(id)
+		if err != nil {
+			continue
+		}
+		if problem, _ := reconcileContainer(info, true); problem != "" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+对比磁盘上的容器记录和内核中的对象，返回发现的问题，dryRun 为 false 时同时修复：
+1. 不在容器索引中的容器目录
+2. 记录为运行中但进程已经不存在的容器，以及已经退出但资源没有释放的容器
+3. 指向不存在的容器的索引项
+4. 不属于运行中容器的 cgroup
+5. 不属于运行中容器的 veth 设备和 ip
+*/
+func reconcile(dryRun bool) ([]string, error) {
+	var problems []string
+	rootURL := filepath.Clean(container.DefaultFsURL)
+	entries, err := os.ReadDir(rootURL)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ids, err := store.Ids()
+	if err != nil {
+		return nil, err
+	}
+	indexedDirs := make(map[string]bool)
+	for id := range ids {
+		indexedDirs[utils.EncodeSha256([]byte(id))] = true
+	}
+
+	if problems, err = pruneOrphanDirs(rootURL, entries, indexedDirs, dryRun); err != nil {
+		return problems, err
+	}
+
+	var running []*container.ContainerInfo
+	runningCgroups := make(map[string]bool)
+	for id := range ids {
+		info, err := store.Get(id)
+		if err != nil {
+			// 容器目录已经被删除，索引项失效
+			if errors.Is(err, store.ErrNotExist) {
+				problems = append(problems, fmt.Sprintf("container %s in the index has no config.json", id))
+				if !dryRun {
+					if err := store.RemoveIndexEntry(id); err != nil {
+						return problems, err
+					}
+				}
+			}
+			continue
+		}
+		problem, alive := reconcileContainer(info, dryRun)
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+		if alive {
+			running = append(running, info)
+			runningCgroups[utils.EncodeSha256([]byte(info.Id))] = true
+		}
+	}
+
+	cgroupPaths, err := cgroup.ListCgroups()
+	if err != nil {
+		return problems, err
+	}
+	for _, cgroupPath := range cgroupPaths {
+		if runningCgroups[cgroupPath] {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("cgroup %s has no running container", cgroupPath))
+		if !dryRun {
+			if err := cgroup.NewCgroupManager(cgroupPath).Destroy(); err != nil {
+				return problems, err
+			}
+		}
+	}
+
+	networkProblems, err := network.Prune(running, dryRun)
+	return append(problems, networkProblems...), err
+}
+
+// 检查容器记录的状态与进程是否一致，返回发现的问题，以及容器的资源是否需要保留
+func reconcileContainer(info *container.ContainerInfo, dryRun bool) (string, bool) {
+	// created 状态的容器还没有进程，但是已经持有 cgroup 和 ip，当作运行中的容器保留
+	if info.Status == container.CREATED {
+		return "", true
+	}
+	var problem string
+	if info.Status.Alive() {
+		process, err := container.OpenProcess(info)
+		if err == nil {
+			process.Close()
+			return "", true
+		}
+		// 其他错误无法确定进程的状态，当作仍在运行，不做处理
+		var staleErr *container.StaleProcessError
+		if !errors.As(err, &staleErr) {
+			log.Warnf("Open container %s process error %v", info.Id, err)
+			return "", true
+		}
+		problem = fmt.Sprintf("container %s is %s but %v", info.Id, info.Status, err)
+	} else if info.IpAddress != "" || utils.IsMounted(filepath.Join(info.RootUrl, "merge")) {
+		problem = fmt.Sprintf("container %s is %s but its resources are not released", info.Id, info.Status)
+	} else {
+		return "", false
+	}
+
+	if !dryRun {
+		cleanupContainer(info, false)
+		ipAddress := info.IpAddress
+		var from container.State
+		current, err := store.Update(info.Id, func(current *container.ContainerInfo) error {
+			current.IpAddress = ipAddress
+			if !current.Status.Alive() {
+				return nil
+			}
+			current.Pid = ""
+			current.FinishTime = time.Now().Format("2006-01-02 15:04:05")
+			from = current.Status
+			return transition(current, "die", container.EXIT)
+		})
+		if err != nil {
+			log.Errorf("Update container %s info error %v", info.Id, err)
+		} else if from != "" {
+			emitStateEvent(current, "die", from)
+			// 容器进程不在了，monitor 进程没有执行 poststop hook
+			if err := runHooks(current, container.HookPoststop, "stopped", 0); err != nil {
+				log.Warnf("%v", err)
+			}
+		}
+	}
+	return problem, false
+}
+
+// 不在索引中的容器目录是 create 中途退出留下的，最近修改过的可能正在创建
+// 只有名字是容器 id 的 sha256 的目录才是容器目录，其他目录不是 dockergsh 创建的，不做处理
+func pruneOrphanDirs(rootURL string, entries []os.DirEntry, indexedDirs map[string]bool, dryRun bool) ([]string, error) {
+	var problems []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !utils.IsSha256(entry.Name()) || reservedEntries[entry.Name()] || indexedDirs[entry.Name()] {
+			continue
+		}
+		if fileInfo, err := entry.Info(); err != nil || time.Since(fileInfo.ModTime()) < orphanGracePeriod {
+			continue
+		}
+		containerDir := filepath.Join(rootURL, entry.Name())
+		problems = append(problems, fmt.Sprintf("container dir %s is not in the container index", containerDir))
+		if !dryRun {
+			if err := removeOrphanDir(containerDir); err != nil {
+				return problems, err
+			}
+		}
+	}
+	return problems, nil
+}
+
+// 删除残留的容器目录，merge 层中可能还挂载着 volume，使用 MNT_DETACH 一起卸载
+func removeOrphanDir(containerDir string) error {
+	mergeURL := filepath.Join(containerDir, "merge")
+	if utils.IsMounted(mergeURL) {
+		if err := syscall.Unmount(mergeURL, syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("unmount %s error %v", mergeURL, err)
+		}
+	}
+	return os.RemoveAll(containerDir)
+}
@@ -0,0 +1,69 @@
+package cmdExec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/utils"
+)
+
+func TestPruneOrphanDirs(t *testing.T) {
+	rootURL := t.TempDir()
+	indexed := utils.EncodeSha256([]byte("running"))
+	orphan := utils.EncodeSha256([]byte("orphan"))
+	recent := utils.EncodeSha256([]byte("recent"))
+	// 除了 recent 以外的目录都超过了 orphanGracePeriod
+	old := time.Now().Add(-2 * orphanGracePeriod)
+	// 不是 sha256 的目录不是容器目录，即使不在索引中也要保留
+	notContainer := []string{"backup", orphan[:63], strings.ToUpper(orphan), orphan[:63] + "g"}
+	names := append([]string{container.HooksDirName, "volumes", "network", indexed, orphan, recent}, notContainer...)
+	for _, name := range names {
+		dir := filepath.Join(rootURL, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if name != recent {
+			if err := os.Chtimes(dir, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(rootURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := pruneOrphanDirs(rootURL, entries, map[string]bool{indexed: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 {
+		t.Fatalf("problems = %v, want only the orphan container dir", problems)
+	}
+
+	tests := []struct {
+		name   string
+		exists bool
+	}{
+		{container.HooksDirName, true},
+		{"volumes", true},
+		{"network", true},
+		{indexed, true},
+		{recent, true},
+		{orphan, false},
+		{notContainer[0], true},
+		{notContainer[1], true},
+		{notContainer[2], true},
+		{notContainer[3], true},
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(rootURL, tt.name))
+		if exists := err == nil; exists != tt.exists {
+			t.Errorf("%s exists = %v, want %v", tt.name, exists, tt.exists)
+		}
+	}
+}
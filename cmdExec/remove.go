@@ -1,29 +1,72 @@
 package cmdExec
 
 import (
+	"errors"
 	"fmt"
+
 	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
 	log "github.com/sirupsen/logrus"
 )
 
-func RemoveContainer(containerArg string) error {
+// RemoveContainer 删除容器，force 为 true 时先使用 SIGKILL 停止运行中的容器
+func RemoveContainer(containerArg string, force bool) error {
 	// 获取容器信息
 	info, err := GetContainerInfoByArg(containerArg)
 	if err != nil {
 		log.Errorf("Get container %s info error %v", containerArg, err)
 		return err
 	}
-	// 如果容器正在运行中，不能删除
-	if info.Status != container.STOP {
-		log.Errorf("Couldn't remove running container")
-		return fmt.Errorf("couldn't remove running container")
+	// 如果容器正在运行中或者被暂停，不指定 -f 时不能删除
+	if info.Status.Alive() {
+		if !force {
+			err := &container.StateError{Id: info.Id, Op: "remove", State: info.Status}
+			log.Errorf("Remove container %s error %v", info.Id, err)
+			return fmt.Errorf("%v, stop the container before removing or use -f", err)
+		}
+		if err := StopContainer(info.Id, int(killTimeout.Seconds()), "SIGKILL"); err != nil {
+			log.Errorf("Stop container %s error %v", info.Id, err)
+			return err
+		}
+		// --rm 的容器停止后由 monitor 进程删除
+		if info.AutoRemove {
+			return nil
+		}
+		if info, err = store.Get(info.Id); err != nil {
+			if errors.Is(err, store.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
 	}
+	return removeContainer(info)
+}
 
-	// 如果容器已经停止，那么删除容器信息
-	deleteContainerInfo(info.Id, info.Name)
+/*
+删除容器：
+1. 加锁把容器状态改为 removing，运行中的容器不能删除
+2. 释放容器的资源，删除可写层和容器记录，monitor 进程被杀死时容器退出后没有释放资源，这里再释放一次，已经释放的会跳过
+3. 状态为 removing 的容器是上一次删除时中断的，直接继续删除
+*/
+func removeContainer(info *container.ContainerInfo) error {
+	if info.Status != container.REMOVING {
+		var from container.State
+		current, err := store.Update(info.Id, func(current *container.ContainerInfo) error {
+			from = current.Status
+			return transition(current, "remove", container.REMOVING)
+		})
+		if err != nil {
+			log.Errorf("Remove container %s error %v", info.Id, err)
+			return err
+		}
+		info = current
+		emitStateEvent(info, "remove", from)
+	}
 
-	// docker rm 的时候应该已经没有挂载
-	mergeURL := info.RootUrl + "/merge"
-	container.DeleteWorkSpace(true, "", mergeURL, info.RootUrl)
+	cleanupContainer(info, true)
+	if err := transition(info, "remove", container.REMOVED); err != nil {
+		return err
+	}
+	emitStateEvent(info, "remove", container.REMOVING)
 	return nil
 }
@@ -0,0 +1,112 @@
+package cmdExec
+
+import (
+	"fmt"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/pkg/sysinfo"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	log "github.com/sirupsen/logrus"
+)
+
+// 与 docker 保持一致，内存限制不能小于 6MB
+const minimumMemory = 6 * 1024 * 1024
+
+/*
+检查资源限制的配置：
+1. 取值不合法时返回错误，例如 swap 小于内存限制、blkio 权重超出范围
+2. 与 docker 保持一致，宿主机内核不支持的限制只打印警告，并从配置中去掉
+*/
+func verifyResourceConfig(resConf *subsystem.ResourceConfig) error {
+	sysInfo := sysinfo.New(true)
+
+	if resConf.Memory != 0 {
+		if resConf.Memory < minimumMemory {
+			return fmt.Errorf("minimum memory limit allowed is %s", units.BytesSize(minimumMemory))
+		}
+		if !sysInfo.MemoryLimit {
+			log.Warnf("Your kernel does not support memory limit capabilities. Limitation discarded.")
+			resConf.Memory = 0
+			resConf.MemorySwap = 0
+		}
+	}
+
+	if resConf.MemorySwap > 0 {
+		if resConf.Memory == 0 {
+			return fmt.Errorf("you should always set the memory limit when using memory swap")
+		}
+		if resConf.MemorySwap < resConf.Memory {
+			return fmt.Errorf("minimum memory swap limit should be larger than memory limit, see usage")
+		}
+	}
+	if resConf.MemorySwap != 0 && !sysInfo.SwapLimit {
+		log.Warnf("Your kernel does not support swap limit capabilities. Limitation discarded.")
+		resConf.MemorySwap = 0
+	}
+
+	if resConf.MemoryReservation != 0 {
+		if resConf.Memory > 0 && resConf.MemoryReservation > resConf.Memory {
+			return fmt.Errorf("minimum memory limit should be larger than memory reservation limit, see usage")
+		}
+		if !sysInfo.MemoryReservation {
+			log.Warnf("Your kernel does not support memory reservation capabilities. Limitation discarded.")
+			resConf.MemoryReservation = 0
+		}
+	}
+
+	// 范围与内核 v1 的限制保持一致
+	if resConf.CpuShares != 0 && (resConf.CpuShares < 2 || resConf.CpuShares > 262144) {
+		return fmt.Errorf("range of cpu shares is from 2 to 262144")
+	}
+	if resConf.CpuPeriod != 0 && (resConf.CpuPeriod < 1000 || resConf.CpuPeriod > 1000000) {
+		return fmt.Errorf("cpu cfs period can not be less than 1ms (i.e. 1000) or larger than 1s (i.e. 1000000)")
+	}
+	if resConf.CpuQuota != 0 && resConf.CpuQuota != -1 && resConf.CpuQuota < 1000 {
+		return fmt.Errorf("cpu cfs quota can not be less than 1ms (i.e. 1000)")
+	}
+
+	if resConf.OomKillDisable {
+		if !sysInfo.OomKillDisable {
+			log.Warnf("Your kernel does not support oom kill disable. Oom kill disable discarded.")
+			resConf.OomKillDisable = false
+		} else if resConf.Memory == 0 {
+			log.Warnf("Disabling the OOM killer on containers without setting a memory limit may be dangerous.")
+		}
+	}
+
+	if resConf.OomKillGroup && !sysInfo.OomKillGroup {
+		log.Warnf("Your kernel does not support oom kill group. Oom kill group discarded.")
+		resConf.OomKillGroup = false
+	}
+
+	if resConf.PidsLimit != 0 && !sysInfo.PidsLimit {
+		log.Warnf("Your kernel does not support pids limit capabilities. Pids limit discarded.")
+		resConf.PidsLimit = 0
+	}
+
+	if resConf.BlkioWeight != 0 {
+		if resConf.BlkioWeight < 10 || resConf.BlkioWeight > 1000 {
+			return fmt.Errorf("range of blkio weight is from 10 to 1000")
+		}
+		if !sysInfo.BlkioWeight {
+			log.Warnf("Your kernel does not support blkio weight. Weight discarded.")
+			resConf.BlkioWeight = 0
+		}
+	}
+
+	throttled := len(resConf.ReadBpsDevice) > 0 || len(resConf.WriteBpsDevice) > 0 ||
+		len(resConf.ReadIOpsDevice) > 0 || len(resConf.WriteIOpsDevice) > 0
+	if throttled && !sysInfo.BlkioThrottle {
+		log.Warnf("Your kernel does not support blkio throttle. Throttle discarded.")
+		resConf.ReadBpsDevice = nil
+		resConf.WriteBpsDevice = nil
+		resConf.ReadIOpsDevice = nil
+		resConf.WriteIOpsDevice = nil
+	}
+
+	if len(resConf.HugetlbLimits) > 0 && !sysInfo.HugetlbLimit {
+		log.Warnf("Your kernel does not support hugetlb limit. Hugetlb limit discarded.")
+		resConf.HugetlbLimits = nil
+	}
+	return nil
+}
@@ -2,129 +2,184 @@ package cmdExec
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/Nevermore12321/dockergsh/cgroup"
-	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	"github.com/Nevermore12321/dockergsh/network"
-	"github.com/Nevermore12321/dockergsh/utils"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/volume"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"os/exec"
-	"strconv"
-	"strings"
-	"time"
+	"path/filepath"
 
 	"github.com/Nevermore12321/dockergsh/container"
 )
 
-func Run(tty bool, commandArray []string, resConf *subsystem.ResourceConfig, imageName, containerName, volume string, envSlice []string, networkName string) {
-	// containerInit 包含容器初始化时需要记录的一些信息
-	// 添加镜像 挂载 等参数
-	containerInit, parentCmd, writePipe := container.NewParentProcess(tty, imageName, volume, envSlice)
-	if parentCmd == nil { // 如果没有创建出 进程命令
-		log.Errorf("New parent process error")
-		return
+func Run(tty bool, opts *ContainerOptions) error {
+	// 资源限制在创建 monitor 之前检查，错误和警告直接输出给用户
+	if err := verifyResourceConfig(opts.Resources); err != nil {
+		return err
 	}
-	/*
-		这里的 Start 方法是真正开始前面创建好的command的调用:
-		1. 首先会 clone 出来一个 namespace 隔离的进程
-		2. 然后在子进程中，调用 /proc/self/exe，也就是调用自己，发送 init 参数，调用我们写的 init 方法，去初始化容器的一些资源
-		3. 注意，子进程执行 /proc/self/exe ，也就是说要让子进程成为 container 中的 init 程序，需要注意 init 程序不能退出
-	*/
-	if err := parentCmd.Start(); err != nil {
-		log.Errorf("new parent process error: %v", err)
+	// 容器名在创建任何资源之前检查，重名时直接报错
+	if err := validateContainerName(opts.Name); err != nil {
+		return err
 	}
 
-	// record container info
-	// 将 Container 详情写入到 文件 config.json 中
-	containerName, err := recordContainerInfo(containerInit, parentCmd.Process.Pid, containerName, commandArray, volume)
-	if err != nil {
-		log.Errorf("Record container info error %v", err)
-		return
+	// 非 -it 模式下，由后台的 monitor 进程创建并看护容器，当前进程等 monitor 报告容器的创建结果后就返回
+	monitor := isMonitorProcess()
+	if !tty && !monitor {
+		return spawnMonitor()
 	}
+	// 环境变量会被容器进程继承，monitor 标记不需要带到容器里
+	_ = os.Unsetenv(ENV_MONITOR)
 
-	fmt.Println(containerInit)
-
-	// 开启cgroup
-	// 检查版本
-	_, err = exec.Command("grep", "cgroup2", "/proc/filesystems").CombinedOutput()
-	if err != nil { // cgroup v1
-		// use dockergsh as cgroup name
-		cgroupManager := cgroup.NewCgroupManager(containerInit.IdBase)
-		//  如果以 -it 启动容器，那么退出时，直接删除 cgroup
-		if tty {
-			defer cgroupManager.DestroyV1()
-		}
-		// 设置资源限制
-		if err := cgroupManager.SetV1(resConf); err != nil {
-			log.Errorf("set cgroup resource failed: %v", err)
+	// run 就是 create + start
+	containerInfo, err := createContainer(opts)
+	var parentCmd *exec.Cmd
+	if err == nil {
+		if parentCmd, err = startContainerProcess(containerInfo, tty); err != nil && (tty || opts.AutoRemove) {
+			// 启动失败的容器同样需要删除
+			autoRemoveContainer(containerInfo)
 		}
-		// 将容器进程 pid 加入到 cgroup 中
-		if err = cgroupManager.ApplyV1(parentCmd.Process.Pid); err != nil {
-			log.Errorf("add process to cgroup failed: %v", err)
+	}
+	if monitor {
+		var containerId, logPath string
+		if err == nil {
+			containerId = containerInfo.Id
+			logPath = filepath.Join(containerInfo.RootUrl, container.MonitorLogFile)
 		}
-	} else { // cgroup v2
-		// use dockergsh as cgroup name
-		cgroupManager := cgroup.NewCgroupManager(containerInit.IdBase)
-		//  如果以 -it 启动容器，那么退出时，直接删除 cgroup
+		notifyMonitorReady(containerId, logPath, err)
+	}
+	if err != nil {
+		return err
+	}
+	return superviseContainer(containerInfo, parentCmd, tty)
+}
+
+/*
+等待容器主进程退出：
+1. -it 模式的容器退出后直接删除
+2. 重启策略为 on-unhealthy 时，因为不健康被停止的容器重新启动
+3. 其他情况记录容器的退出码，--rm 的容器随后被删除
+*/
+func superviseContainer(containerInfo *container.ContainerInfo, parentCmd *exec.Cmd, tty bool) error {
+	for {
+		health := startHealthMonitor(containerInfo)
+		// parent.Wait() 主要是用于父进程等待子进程结束
+		waitErr := parentCmd.Wait()
+		unhealthy := health.Stop()
+
+		// 如果是 -it 伪终端模式，容器退出后，需要释放容器资源
 		if tty {
-			defer cgroupManager.DestroyV2()
+			if waitErr != nil {
+				log.Errorf("Wait for child err: %v", waitErr)
+			}
+			// -it 模式的容器退出后直接删除，删除前先记录退出，容器状态才能转换到 removing
+			if err := recordContainerExit(containerInfo.Id, parentCmd.ProcessState); err != nil {
+				return err
+			}
+			if info, err := store.Get(containerInfo.Id); err == nil {
+				autoRemoveContainer(info)
+			}
+			return nil
 		}
-		// 设置资源限制
-		if err := cgroupManager.SetV2(resConf); err != nil {
-			log.Errorf("set cgroup resource failed: %v", err)
+
+		// 重启策略为 on-unhealthy 时，因为不健康被停止的容器在同一个容器目录中重新启动
+		if unhealthy {
+			restartedInfo, restartedCmd, err := restartContainer(containerInfo.Id)
+			if err == nil {
+				containerInfo, parentCmd = restartedInfo, restartedCmd
+				continue
+			}
+			log.Errorf("Restart container %s error %v", containerInfo.Id, err)
 		}
-		// 将容器进程 pid 加入到 cgroup 中
-		if err = cgroupManager.ApplyV2(parentCmd.Process.Pid); err != nil {
-			log.Errorf("add process to cgroup failed: %v", err)
+
+		// monitor 进程负责记录容器的退出码
+		err := recordContainerExit(containerInfo.Id, parentCmd.ProcessState)
+		if containerInfo.AutoRemove {
+			// 重新读取容器信息，退出时已经释放的 ip 不能再释放一次
+			if info, getErr := store.Get(containerInfo.Id); getErr == nil {
+				autoRemoveContainer(info)
+			}
 		}
+		return err
 	}
+}
 
-	// 配置容器网络
-	if networkName != "" {
-		err := network.Init()
-		if err != nil {
-			log.Errorf("network init failed: %v", err)
+/*
+在原来的容器目录中重新启动容器的 init 进程，rootfs、volume 和 cgroup 保持不变：
+1. 状态改为 restarting，启动成功后改回 running，启动失败的容器由 monitor 记录为 exited
+2. 原来的 network namespace 已经随容器进程销毁，释放原来的 ip 后重新连接网络
+3. 重新记录 pid、进程启动时间和 cgroup，健康状态重置为 starting
+*/
+func restartContainer(containerId string) (*container.ContainerInfo, *exec.Cmd, error) {
+	// 加锁把状态改为 restarting，重启之前容器已经被 stop 的话不再重启
+	var from container.State
+	info, err := store.Update(containerId, func(info *container.ContainerInfo) error {
+		from = info.Status
+		return transition(info, "restart", container.RESTARTING)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	emitStateEvent(info, "restart", from)
+	if info.Network != "" && info.IpAddress != "" {
+		if err := network.Init(); err != nil {
+			return nil, nil, err
 		}
-		// todo 端口映射
-		containerInfo := container.ContainerInfo{
-			Id:   containerInit.Id,
-			Pid:  strconv.Itoa(parentCmd.Process.Pid),
-			Name: containerName,
-			//PortMapping: portmapping,
+		if err := network.DisconnectNetwork(info); err != nil {
+			return nil, nil, err
 		}
+		info.IpAddress = ""
+	}
 
-		if err = network.ConnectNetwork(networkName, &containerInfo); err != nil {
-			log.Errorf("Error Connect Network %v", err)
-			return
-		}
+	info.Restarts++
+	parentCmd, err := startContainerProcess(info, false)
+	if err != nil {
+		return nil, nil, err
 	}
+	log.Infof("Container %s restarted, pid %d", info.Id, parentCmd.Process.Pid)
+	return info, parentCmd, nil
+}
 
-	// 父进程向容器中发送 所有的命令选项
-	sendInitCommand(commandArray, writePipe)
+// 删除容器，释放容器的所有资源，包括容器记录、upper 层、ip 和匿名 volume
+func autoRemoveContainer(info *container.ContainerInfo) {
+	if err := removeContainer(info); err != nil {
+		return
+	}
+	removeAnonymousVolume(info.Volume)
+}
 
-	// 如果是 -it 伪终端模式，那么需要监听，如果退出，需要释放容器资源
-	if tty {
-		// parent.Wait() 主要是用于父进程等待子进程结束
-		if err := parentCmd.Wait(); err != nil {
-			log.Errorf("Wait for child err: %v", err)
+// 删除容器的 -v 参数使用的匿名 volume
+func removeAnonymousVolume(volumeSpec string) {
+	if anonymous := volume.AnonymousOf(volumeSpec); anonymous != nil {
+		if err := volume.Remove(anonymous.Name); err != nil {
+			log.Errorf("Remove anonymous volume %s error %v", anonymous.Name, err)
 		}
+	}
+}
 
-		// 容器删除后，删除容器的记录信息
-		deleteContainerInfo(containerInit.Id, containerName)
-
-		// todo 停止容器时，删除挂载路径
-		container.DeleteWorkSpace(true, volume, containerInit.MergeUrl, containerInit.RootUrl)
+func newInitConfig(commandArray []string, devices []*container.Device, shmSize int64, useInit bool, cgroupNs string) *container.InitConfig {
+	return &container.InitConfig{
+		Args:    commandArray,
+		Devices: devices,
+		ShmSize: shmSize,
+		Init:    useInit,
+		// 与 docker 一致，没有指定时 cgroup v2 默认使用独立的 cgroup namespace，v1 默认使用宿主机的
+		CgroupNs: cgroupNs == "private" || (cgroupNs == "" && cgroup.IsUnified()),
+		CgroupV2: cgroup.IsUnified(),
 	}
 }
 
 // 向管道中发送消息
-// 也就是父进程通过管道向子进程（容器）中发送命令行选项
-func sendInitCommand(comArray []string, writePipe *os.File) {
-	// 把所有选项通过空格分割
-	commandOpt := strings.Join(comArray, " ")
-	log.Infof("Send command to init container: %s", commandOpt)
-	_, err := writePipe.WriteString(commandOpt)
+// 也就是父进程通过管道向子进程（容器）中发送 json 格式的 InitConfig，这样参数中的空格不会丢失
+func sendInitCommand(initConfig *container.InitConfig, writePipe *os.File) {
+	configBytes, err := json.Marshal(initConfig)
+	if err != nil {
+		log.Warnf("Marshal init config failed: %s", err)
+		return
+	}
+	log.Infof("Send init config to container: %s", string(configBytes))
+	_, err = writePipe.Write(configBytes)
 	if err != nil {
 		log.Warnf("Send command Opt to container init failed: %s", err)
 	}
@@ -136,100 +191,23 @@ func sendInitCommand(comArray []string, writePipe *os.File) {
 
 /*
 记录容器的信息
-将 container 的详细信息写入到 /var/lib/dockergsh/[containerID]/container/config.json
+将 container 的详细信息写入到 /var/lib/dockergsh/[containerID]/container/config.json，并把容器名加入全局索引
 */
-func recordContainerInfo(containerInit *container.ContainerInit, containerPid int, containerName string, commandArray []string, volume string) (string, error) {
-	//  创建时间
-	createTime := time.Now().Format("2006-01-02 15:04:05")
-	//  容器的启动命令
-	command := strings.Join(commandArray, " ")
-	log.Infof("Container command is %s:", command)
-	var idFlag bool
-	// 如果 docker 启动的时候没有指定名称，那么就是用 id
-	if containerName == "" {
-		containerName = containerInit.Id
-		idFlag = true
-	}
-
-	// 初始化 ConntainerInfo 实例
-	containerInfo := &container.ContainerInfo{
-		Name:       containerName,
-		Pid:        strconv.Itoa(containerPid),
-		Id:         containerInit.Id,
-		Command:    command,
-		CreateTime: createTime,
-		Status:     container.RUNNING,
-		RootUrl:    containerInit.RootUrl,
-		Volume:     volume,
-	}
-
-	// 将 ContainerInfo 结构体实例 转成 json 字符串
-	jsonBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		log.Errorf("Record container info error %v", err)
-		return "", err
-	}
-	jsonStr := string(jsonBytes)
-
-	//  如果 目录没有创建，则创建
-	configFileURL := containerInfo.RootUrl + "/" + container.ContainerConfigPath + "/"
-	if err := os.MkdirAll(configFileURL, 0622); err != nil {
-		log.Errorf("Mkdir error %s error %v", configFileURL, err)
-		return "", err
-	}
-
-	// 如果 idFlag 为 false，也就是 docker 启动时设置了容器的名称，那么就添加一个软链接 /var/lib/dockergsh/named_containers/[containerName] 到 /var/lib/dockergsh/[containerID]
-	// 便于观察
-	if !idFlag {
-		containersUrl := fmt.Sprintf(container.DefaultInfoLocation, container.NamedContainersDir)
-		if exist, err := utils.PathExists(containersUrl); err != nil {
-			log.Errorf("Soft link floder %s create err: %v", containersUrl, err)
-			return "", err
-		} else if !exist {
-			if err := os.MkdirAll(containersUrl, 0777); err != nil {
-				log.Errorf("Create Soft Link Foldeer Failed:  %s . %v", containersUrl, err)
-				return "", err
-			}
-		}
-		linkURL := containersUrl + containerName
-		if err := os.Symlink(configFileURL, linkURL); err != nil {
-			log.Errorf("Soft link error %s error %v", configFileURL, err)
-			return "", err
-		}
+func recordContainerInfo(containerInfo *container.ContainerInfo) error {
+	log.Infof("Container command is %s:", containerInfo.Command)
+	if err := store.Create(containerInfo); err != nil {
+		log.Errorf("Record container %s info error %v", containerInfo.Id, err)
+		return err
 	}
-
-	// 创建 config.json 文件
-	configFilePath := configFileURL + container.ConfigName
-	file, err := os.Create(configFilePath)
-	defer file.Close()
-	if err != nil {
-		log.Errorf("Create file %s error %v", configFilePath, err)
-		return "", err
-	}
-
-	// 将 container 详情写入 config.json 文件
-	if _, err := file.WriteString(jsonStr); err != nil {
-		log.Errorf("File write string error %v", err)
-		return "", err
-	}
-
-	return containerName, nil
+	return nil
 }
 
 /*
 删除容器的信息
 */
-func deleteContainerInfo(containerId, containerName string) {
-	// 删除 /var/lib/dockergsh/[containerId]/container 目录
-	rootDir := fmt.Sprintf(container.DefaultInfoLocation, containerId)
-	configFileURL := rootDir + "container"
-	if err := os.RemoveAll(configFileURL); err != nil {
-		log.Errorf("Remove dir %s error %v", configFileURL, err)
-	}
-
-	// 删除 软链接
-	linkUrl := fmt.Sprintf(container.DefaultInfoLocation[:len(container.DefaultInfoLocation)], "named_containers/"+containerName)
-	if err := os.RemoveAll(linkUrl); err != nil {
-		log.Errorf("Remove dir %s error %v", linkUrl, err)
+func deleteContainerInfo(containerId string) {
+	// 删除 /var/lib/dockergsh/[containerId]/container 目录，并从索引中删除
+	if err := store.Delete(containerId); err != nil {
+		log.Errorf("Delete container %s info error %v", containerId, err)
 	}
 }
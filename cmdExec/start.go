@@ -0,0 +1,178 @@
+package cmdExec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/network"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+StartContainer 启动 created 状态或者已经退出的容器
+与 run -d 一样，由后台的 monitor 进程启动并看护容器，当前进程等 monitor 报告启动结果后就返回
+*/
+func StartContainer(containerArg string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	// 暂停的容器通过 unpause 恢复运行，不能 start
+	if err := requireState(info, "start", container.CREATED, container.STOP, container.EXIT); err != nil {
+		return err
+	}
+
+	monitor := isMonitorProcess()
+	if !monitor {
+		return spawnMonitor()
+	}
+	_ = os.Unsetenv(ENV_MONITOR)
+
+	parentCmd, err := startContainerProcess(info, false)
+	if err != nil && info.AutoRemove {
+		autoRemoveContainer(info)
+	}
+	var logPath string
+	if err == nil {
+		logPath = filepath.Join(info.RootUrl, container.MonitorLogFile)
+	}
+	notifyMonitorReady(info.Id, logPath, err)
+	if err != nil {
+		return err
+	}
+	return superviseContainer(info, parentCmd, false)
+}
+
+/*
+启动容器的 init 进程，create 之后第一次启动、start 已经退出的容器和重启时都会调用：
+1. 重新挂载 rootfs，容器退出后 merge 层已经卸载
+2. 创建容器 init 进程，记录 pid、进程启动时间和宿主机的 boot id
+3. 设置 cgroup 资源限制，并将 init 进程加入 cgroup
+4. 连接网络，create 时分配的 ip 还没有释放的话继续使用，之后执行 prestart 和 createRuntime hook
+5. 通知容器 init 进程开始执行用户命令，之后执行 poststart hook
+*/
+func startContainerProcess(info *container.ContainerInfo, tty bool) (*exec.Cmd, error) {
+	idBase := utils.EncodeSha256([]byte(info.Id))
+	if err := container.MountWorkSpace(info.Image, info.Volume, info.RootUrl); err != nil {
+		log.Errorf("Mount container %s rootfs error %v", info.Id, err)
+		return nil, err
+	}
+	emitVolumeMount(info)
+
+	parentCmd, writePipe := container.NewInitProcess(tty, idBase, info.Env)
+	if parentCmd == nil { // 如果没有创建出 进程命令
+		log.Errorf("New parent process error")
+		return nil, fmt.Errorf("new parent process error")
+	}
+	/*
+		这里的 Start 方法是真正开始前面创建好的command的调用:
+		1. 首先会 clone 出来一个 namespace 隔离的进程
+		2. 然后在子进程中，调用 /proc/self/exe，也就是调用自己，发送 init 参数，调用我们写的 init 方法，去初始化容器的一些资源
+		3. 注意，子进程执行 /proc/self/exe ，也就是说要让子进程成为 container 中的 init 程序，需要注意 init 程序不能退出
+	*/
+	if err := parentCmd.Start(); err != nil {
+		log.Errorf("new parent process error: %v", err)
+		return nil, fmt.Errorf("new parent process error: %v", err)
+	}
+	if err := setupContainerProcess(info, idBase, parentCmd.Process.Pid); err != nil {
+		// init 进程还在等待管道中的配置，直接杀死
+		_ = parentCmd.Process.Kill()
+		_ = parentCmd.Wait()
+		return nil, err
+	}
+
+	// 父进程向容器中发送 所有的命令选项，以及需要创建的设备
+	sendInitCommand(newInitConfig(info.Args, info.Devices, info.ShmSize, info.Init, info.CgroupNs), writePipe)
+	if err := runHooks(info, container.HookPoststart, "running", parentCmd.Process.Pid); err != nil {
+		log.Warnf("%v", err)
+	}
+	return parentCmd, nil
+}
+
+// 记录 init 进程的信息，配置 cgroup 和网络，并把容器状态改为 running
+/*
+容器的 cgroup 配置，设备白名单记录在 info.DeviceRules 中，不在 info.Resources 里
+容器退出时 cgroup 已经被删除，每次启动都要重新写入白名单，否则容器可以访问宿主机的所有设备
+*/
+func containerResources(info *container.ContainerInfo) *subsystem.ResourceConfig {
+	resConf := &subsystem.ResourceConfig{}
+	if info.Resources != nil {
+		*resConf = *info.Resources
+	}
+	resConf.DeviceRules = info.DeviceRules
+	return resConf
+}
+
+func setupContainerProcess(info *container.ContainerInfo, idBase string, pid int) error {
+	var err error
+	// 记录进程的启动时间和宿主机的 boot id，之后操作容器进程前用来校验 pid 对应的还是同一个进程
+	info.Pid = strconv.Itoa(pid)
+	if info.StartTime, err = container.ProcessStartTime(pid); err != nil {
+		log.Errorf("Get container process start time error %v", err)
+		return err
+	}
+	if info.BootId, err = container.BootId(); err != nil {
+		log.Errorf("Get boot id error %v", err)
+		return err
+	}
+
+	// 开启cgroup，设置资源限制，dockergsh update 修改过的限制也记录在 info.Resources 中
+	cgroupManager := cgroup.NewCgroupManager(idBase)
+	if err := cgroupManager.Set(containerResources(info)); err != nil {
+		log.Errorf("set cgroup resource failed: %v", err)
+	}
+	// 将容器进程 pid 加入到 cgroup 中
+	if err := cgroupManager.Apply(pid); err != nil {
+		log.Errorf("add process to cgroup failed: %v", err)
+	}
+	// 记录容器主进程所在的 cgroup，之后用来校验进程身份
+	if info.Cgroup, err = container.ProcessCgroup(pid); err != nil {
+		log.Errorf("Get container cgroup error %v", err)
+		return err
+	}
+
+	// 配置容器网络
+	if info.Network != "" {
+		if err := network.Init(); err != nil {
+			log.Errorf("network init failed: %v", err)
+		}
+		// todo 端口映射
+		if err := network.ConnectNetwork(info.Network, info); err != nil {
+			log.Errorf("Error Connect Network %v", err)
+			return err
+		}
+	}
+
+	// prestart 和 createRuntime hook 在用户命令执行之前执行，此时容器 init 进程还在等待管道中的配置，hook 失败时容器启动失败
+	for _, stage := range []string{container.HookPrestart, container.HookCreateRuntime} {
+		if err := runHooks(info, stage, "created", pid); err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+	}
+
+	// 重启时容器的状态为 restarting
+	from := info.Status
+	if err := transition(info, "start", container.RUNNING); err != nil {
+		return err
+	}
+	info.ExitCode = 0
+	info.FinishTime = ""
+	if info.Healthcheck != nil {
+		info.Health = &container.Health{Status: container.HealthStarting}
+	}
+	// 记录容器的 pid、cgroup 和分配到的网络信息
+	if err := UpdateContainerInfo(info); err != nil {
+		return err
+	}
+	emitStateEvent(info, "start", from)
+	return nil
+}
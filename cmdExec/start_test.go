@@ -0,0 +1,93 @@
+package cmdExec
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/utils"
+)
+
+// 在临时目录中模拟 v1 的 cgroupfs，内核在新建的 cpuset cgroup 中创建的空文件需要提前创建
+func fakeCgroupV1(t *testing.T, cgroupPath string) string {
+	root := t.TempDir()
+	for _, name := range []string{"cpu", "cpuacct", "cpuset", "memory", "devices", "freezer", "pids", "blkio", "hugetlb"} {
+		if err := os.MkdirAll(path.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cpusetDirs := map[string][2]string{
+		path.Join(root, "cpuset"):                          {"0-3", "0"},
+		path.Join(root, "cpuset", "dockergsh"):             {"", ""},
+		path.Join(root, "cpuset", "dockergsh", cgroupPath): {"", ""},
+	}
+	for dir, content := range cpusetDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "cpuset.cpus"), []byte(content[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(dir, "cpuset.mems"), []byte(content[1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// 容器退出后 cgroup 被删除，从容器记录重新创建 cgroup 时设备白名单不能丢失
+func TestDeviceRulesAfterRestart(t *testing.T) {
+	useTempStore(t)
+	rules := container.DeviceRules([]*container.Device{{Type: "c", Major: 10, Minor: 229, Permissions: "rwm"}})
+	if err := store.Create(&container.ContainerInfo{
+		Id:          "abc123",
+		Name:        "web",
+		Status:      container.EXIT,
+		DeviceRules: rules,
+		Resources:   &subsystem.ResourceConfig{PidsLimit: 100},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := store.Get("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resConf := containerResources(info)
+	if !reflect.DeepEqual(resConf.DeviceRules, rules) {
+		t.Fatalf("device rules = %v, want %v", resConf.DeviceRules, rules)
+	}
+	// 白名单不写回 info.Resources
+	if info.Resources.DeviceRules != nil {
+		t.Errorf("info.Resources.DeviceRules = %v, want nil", info.Resources.DeviceRules)
+	}
+
+	idBase := utils.EncodeSha256([]byte(info.Id))
+	root := fakeCgroupV1(t, idBase)
+	cgroup.SetRoot(root, cgroup.Legacy)
+	if err := cgroup.NewCgroupManager(idBase).Set(resConf); err != nil {
+		t.Fatalf("Set error %v", err)
+	}
+	// 每条规则覆盖写入一次，文件中留下的是最后一条 allow 和 deny 规则
+	expected := map[string]string{
+		path.Join("devices", "dockergsh", idBase, "devices.allow"): "c 10:229 rwm",
+		path.Join("devices", "dockergsh", idBase, "devices.deny"):  "a",
+		path.Join("pids", "dockergsh", idBase, "pids.max"):         "100",
+	}
+	for file, want := range expected {
+		content, err := os.ReadFile(path.Join(root, file))
+		if err != nil {
+			t.Errorf("read %s error %v", file, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(content)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmdExec
+
+import (
+	"strconv"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/internal/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// 检查容器当前的状态是否可以执行 op，states 为允许执行 op 的状态
+func requireState(info *container.ContainerInfo, op string, states ...container.State) error {
+	if !info.Status.Is(states...) {
+		return &container.StateError{Id: info.Id, Op: op, State: info.Status}
+	}
+	return nil
+}
+
+// 检查容器能否通过 op 转换到 to 状态
+func checkTransition(info *container.ContainerInfo, op string, to container.State) error {
+	if !info.Status.CanTransition(to) {
+		return &container.StateError{Id: info.Id, Op: op, State: info.Status}
+	}
+	return nil
+}
+
+/*
+修改容器的状态，所有命令修改容器状态都通过这个函数：
+1. 按照状态机检查状态转换是否合法，不合法时返回 StateError
+2. 修改 info 中的状态，由调用方写入容器记录，通常在 store.Update 中调用
+写入成功之后调用方再调用 emitStateEvent 记录事件，写入失败时状态没有变化，不能记录事件
+*/
+func transition(info *container.ContainerInfo, op string, to container.State) error {
+	if err := checkTransition(info, op, to); err != nil {
+		return err
+	}
+	info.Status = to
+	return nil
+}
+
+/*
+记录容器状态变化的事件，事件名为操作名，例如 create、start、die
+删除容器时转换到 removing 不记录事件，删除完成后记录 destroy
+*/
+func emitStateEvent(info *container.ContainerInfo, op string, from container.State) {
+	log.WithFields(log.Fields{
+		"container": info.Id,
+		"name":      info.Name,
+		"from":      from,
+		"to":        info.Status,
+	}).Infof("Container %s: %s", info.Id, op)
+
+	action := op
+	attributes := containerAttributes(info)
+	switch info.Status {
+	case container.REMOVING:
+		return
+	case container.REMOVED:
+		action = "destroy"
+	case container.EXIT:
+		attributes["exitCode"] = strconv.Itoa(info.ExitCode)
+	}
+	events.Log(events.ContainerEventType, action, info.Id, attributes)
+}
+
+// 容器事件中的容器名、镜像和 label
+func containerAttributes(info *container.ContainerInfo) map[string]string {
+	attributes := map[string]string{
+		"name":  info.Name,
+		"image": info.Image,
+	}
+	for key, value := range info.Labels {
+		// label 不能覆盖容器名等属性
+		if _, exists := attributes[key]; !exists {
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
+
+// 记录不改变容器状态的事件，例如 kill、exec_start、oom
+func emitContainerEvent(info *container.ContainerInfo, action string, extra map[string]string) {
+	attributes := containerAttributes(info)
+	for key, value := range extra {
+		attributes[key] = value
+	}
+	events.Log(events.ContainerEventType, action, info.Id, attributes)
+}
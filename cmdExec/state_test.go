@@ -0,0 +1,107 @@
+package cmdExec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/store"
+)
+
+func useTempStore(t *testing.T) {
+	oldRoot := store.Root
+	store.Root = t.TempDir()
+	t.Cleanup(func() { store.Root = oldRoot })
+}
+
+func TestRequireState(t *testing.T) {
+	info := &container.ContainerInfo{Id: "abc123", Status: container.STOP}
+	err := requireState(info, "exec in", container.RUNNING)
+	var stateErr *container.StateError
+	if !errors.As(err, &stateErr) {
+		t.Fatalf("requireState error = %v, want StateError", err)
+	}
+	if want := "cannot exec in container abc123: container is stopped"; err.Error() != want {
+		t.Errorf("requireState error = %q, want %q", err, want)
+	}
+
+	info.Status = container.RUNNING
+	if err := requireState(info, "exec in", container.RUNNING); err != nil {
+		t.Errorf("requireState error = %v, want nil", err)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		op      string
+		from    container.State
+		to      container.State
+		wantErr string
+	}{
+		{"start", container.CREATED, container.RUNNING, ""},
+		{"pause", container.CREATED, container.PAUSED, "cannot pause container abc123: container is created"},
+		{"pause", container.EXIT, container.PAUSED, "cannot pause container abc123: container is exited"},
+		{"start", container.REMOVING, container.RUNNING, "cannot start container abc123: container is removing"},
+	}
+
+	for _, tt := range tests {
+		info := &container.ContainerInfo{Id: "abc123", Status: tt.from}
+		err := transition(info, tt.op, tt.to)
+		if tt.wantErr == "" {
+			if err != nil || info.Status != tt.to {
+				t.Errorf("%s -> %s: error %v, status %s", tt.from, tt.to, err, info.Status)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s -> %s: error %v, want %q", tt.from, tt.to, err, tt.wantErr)
+		}
+		// 不合法的转换不修改状态
+		if info.Status != tt.from {
+			t.Errorf("%s -> %s: status changed to %s", tt.from, tt.to, info.Status)
+		}
+	}
+}
+
+// 运行中的容器不指定 -f 时不能删除，容器记录保持不变
+func TestRemoveRunningContainer(t *testing.T) {
+	useTempStore(t)
+	if err := store.Create(&container.ContainerInfo{Id: "abc123", Name: "web", Status: container.RUNNING}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RemoveContainer("web", false)
+	want := "cannot remove container abc123: container is running, stop the container before removing or use -f"
+	if err == nil || err.Error() != want {
+		t.Fatalf("RemoveContainer error = %v, want %q", err, want)
+	}
+	info, err := store.Get("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != container.RUNNING {
+		t.Errorf("status = %s, want running", info.Status)
+	}
+}
+
+// 没有运行的容器不能 exec，也不会留下 execs 目录
+func TestExecInStoppedContainer(t *testing.T) {
+	useTempStore(t)
+	rootURL := t.TempDir()
+	if err := store.Create(&container.ContainerInfo{Id: "abc123", Name: "web", Status: container.STOP, RootUrl: rootURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, detach := range []bool{false, true} {
+		_, _, err := startExec("web", []string{"sh"}, nil, "", "", detach)
+		want := "cannot exec in container abc123: container is stopped"
+		if err == nil || err.Error() != want {
+			t.Errorf("startExec(detach=%v) error = %v, want %q", detach, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(rootURL, container.ExecsDir)); !os.IsNotExist(err) {
+		t.Errorf("execs dir is created for a stopped container")
+	}
+}
@@ -0,0 +1,254 @@
+package cmdExec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// 两次采样之间的间隔
+const statsInterval = time.Second
+
+// ContainerStats 容器某一时刻的资源使用情况
+type ContainerStats struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	*subsystem.Stats
+	CpuPercent    float64 `json:"cpu_percent"`    // 两次采样之间的 cpu 使用率，100% 表示占满一个核
+	MemoryPercent float64 `json:"memory_percent"` // 内存使用量占内存限制的比例，不限制时为宿主机内存
+	NetworkRx     uint64  `json:"network_rx"`     // 容器所有网卡（除 lo 外）累计接收的字节数
+	NetworkTx     uint64  `json:"network_tx"`     // 容器所有网卡（除 lo 外）累计发送的字节数
+
+	read time.Time // 采样时间
+}
+
+/*
+StatsContainers 输出容器的资源使用情况
+1. 没有指定容器时，输出所有运行中的容器，stream 模式下每次采样前重新获取，新启动的容器也会输出
+2. cpu 使用率需要两次采样计算，第一次采样后等待 statsInterval 再输出
+3. noStream 为 true 时只输出一次，否则每隔 statsInterval 刷新一次
+4. format 为 json 时，每次采样输出一行 json 数组
+*/
+func StatsContainers(containerArgs []string, noStream bool, format string) error {
+	if format != "" && format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format %s, only table and json are supported", format)
+	}
+
+	var infos []*container.ContainerInfo
+	var err error
+	if len(containerArgs) == 0 {
+		if infos, err = runningContainerInfos(); err != nil {
+			return err
+		}
+	} else {
+		for _, containerArg := range containerArgs {
+			info, err := GetContainerInfoByArg(containerArg)
+			if err != nil {
+				log.Errorf("Get container %s info error %v", containerArg, err)
+				return err
+			}
+			if err := requireState(info, "stats", container.RUNNING, container.PAUSED); err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	previous := collectStats(infos)
+	for {
+		time.Sleep(statsInterval)
+		if len(containerArgs) == 0 {
+			if infos, err = runningContainerInfos(); err != nil {
+				return err
+			}
+		}
+		current := collectStats(infos)
+		for id, stats := range current {
+			if prev, ok := previous[id]; ok {
+				stats.CpuPercent = cpuPercent(prev, stats)
+			}
+		}
+
+		var statsList []*ContainerStats
+		for _, info := range infos {
+			if stats, ok := current[info.Id]; ok {
+				statsList = append(statsList, stats)
+			}
+		}
+		if err := printStats(statsList, format, !noStream); err != nil {
+			return err
+		}
+
+		if noStream {
+			return nil
+		}
+		previous = current
+	}
+}
+
+// 所有运行中和暂停的容器
+func runningContainerInfos() ([]*container.ContainerInfo, error) {
+	all, err := listContainerInfos()
+	if err != nil {
+		return nil, err
+	}
+	var infos []*container.ContainerInfo
+	for _, info := range all {
+		if info.Status == container.RUNNING || info.Status == container.PAUSED {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+/*
+采集所有容器的统计信息，采集失败的容器（例如已经退出）会被跳过
+logrus 输出到标准输出，采集失败的警告输出到标准错误，不会混进 json 格式的输出中
+*/
+func collectStats(infos []*container.ContainerInfo) map[string]*ContainerStats {
+	statsMap := make(map[string]*ContainerStats, len(infos))
+	for _, info := range infos {
+		stats, err := getContainerStats(info)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: get container %s stats error %v\n", info.Id, err)
+			continue
+		}
+		statsMap[info.Id] = stats
+	}
+	return statsMap
+}
+
+// 从容器的 cgroup 和 network namespace 中读取容器的统计信息
+func getContainerStats(info *container.ContainerInfo) (*ContainerStats, error) {
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+	cgroupStats, err := cgroupManager.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ContainerStats{
+		Id:    info.Id,
+		Name:  info.Name,
+		Stats: cgroupStats,
+		read:  time.Now(),
+	}
+
+	memoryLimit := cgroupStats.MemoryLimit
+	if memoryLimit == 0 {
+		memoryLimit = hostMemoryTotal()
+	}
+	if memoryLimit > 0 {
+		stats.MemoryPercent = float64(cgroupStats.MemoryUsage) / float64(memoryLimit) * 100
+	}
+
+	// /proc/<pid>/net/dev 显示的是进程所在 network namespace 中的网卡
+	process, err := container.OpenProcess(info)
+	if err != nil {
+		return nil, err
+	}
+	defer process.Close()
+	if stats.NetworkRx, stats.NetworkTx, err = readNetDev(process.Pid); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// 两次采样之间 cpu 使用时间的增量除以经过的时间
+func cpuPercent(prev, current *ContainerStats) float64 {
+	wall := current.read.Sub(prev.read).Nanoseconds()
+	if wall <= 0 || current.CpuUsage < prev.CpuUsage {
+		return 0
+	}
+	return float64(current.CpuUsage-prev.CpuUsage) / float64(wall) * 100
+}
+
+// 读取 /proc/<pid>/net/dev，统计除 lo 外所有网卡的收发字节数
+// 每个网卡一行，冒号后第 1 个字段是接收的字节数，第 9 个字段是发送的字节数
+func readNetDev(pid int) (uint64, uint64, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var rx, tx uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.SplitN(scanner.Text(), ":", 2)
+		if len(line) != 2 || strings.TrimSpace(line[0]) == "lo" {
+			continue
+		}
+		fields := strings.Fields(line[1])
+		if len(fields) < 9 {
+			continue
+		}
+		received, _ := strconv.ParseUint(fields[0], 10, 64)
+		transmitted, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += received
+		tx += transmitted
+	}
+	return rx, tx, scanner.Err()
+}
+
+// 宿主机的总内存，单位字节
+func hostMemoryTotal() uint64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			total, _ := strconv.ParseUint(fields[1], 10, 64)
+			return total * 1024
+		}
+	}
+	return 0
+}
+
+// 按照 table 或 json 格式输出，stream 模式下 table 格式每次输出前清屏
+func printStats(statsList []*ContainerStats, format string, stream bool) error {
+	if format == "json" {
+		if statsList == nil {
+			statsList = []*ContainerStats{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(statsList)
+	}
+
+	if stream {
+		fmt.Print("\033[2J\033[H")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "CONTAINER ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, stats := range statsList {
+		memoryLimit := "unlimited"
+		if stats.MemoryLimit > 0 {
+			memoryLimit = units.BytesSize(float64(stats.MemoryLimit))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			stats.Id,
+			stats.Name,
+			stats.CpuPercent,
+			units.BytesSize(float64(stats.MemoryUsage)), memoryLimit,
+			stats.MemoryPercent,
+			units.BytesSize(float64(stats.NetworkRx)), units.BytesSize(float64(stats.NetworkTx)),
+			units.BytesSize(float64(stats.BlkioRead)), units.BytesSize(float64(stats.BlkioWrite)),
+			stats.PidsCurrent)
+	}
+	return w.Flush()
+}
@@ -1,57 +1,174 @@
 package cmdExec
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/signal"
+	"github.com/Nevermore12321/dockergsh/store"
 	"github.com/sirupsen/logrus"
-	"os"
-	"path/filepath"
-	"strconv"
 	"syscall"
+	"time"
 )
 
-func StopContainer(containerArg string) error {
+const (
+	// 发送 SIGKILL 以后，等待进程退出的时间
+	killTimeout = 10 * time.Second
+	// 容器进程退出以后，等待 monitor 记录退出码的时间
+	exitRecordTimeout = 5 * time.Second
+)
+
+/*
+StopContainer 停止容器：
+1. 发送 stop 信号，默认使用容器启动时 --stop-signal 指定的信号，没有指定则为 SIGTERM
+2. 等待容器进程退出，超过 timeout 秒还没有退出，则发送 SIGKILL
+3. 容器进程真正退出以后，才修改容器状态，并释放 cgroup、网络端点和挂载点
+*/
+func StopContainer(containerArg string, timeout int, signalName string) error {
 	// 根据用户输入的 containerId 或者 containerName 获取 contianer Info
 	info, err := GetContainerInfoByArg(containerArg)
 	if err != nil {
 		logrus.Errorf("Get Container %s Info err error %v", containerArg, err)
 		return err
 	}
-	containerPid, err := strconv.Atoi(info.Pid)
-	if err != nil {
-		logrus.Errorf("Conver pid from string to int error %v", err)
+	if info.Status == container.STOP {
+		logrus.Infof("Container %s is already stopped", info.Id)
+		return nil
+	}
+	// created 状态的容器还没有启动，保留 create 时准备的资源
+	if info.Status == container.CREATED {
+		logrus.Infof("Container %s is not started", info.Id)
+		return nil
+	}
+	// exited 的容器只释放残留的资源并改为 stopped，删除中的容器不能停止
+	if err := requireState(info, "stop", container.RUNNING, container.PAUSED, container.RESTARTING, container.EXIT); err != nil {
 		return err
 	}
 
-	// 系统调用 kill，发送 SigTerm 信号给进程，杀掉容器的主进程
-	if err = syscall.Kill(containerPid, syscall.SIGTERM); err != nil {
-		logrus.Errorf("Stop container %s error %v", info.Id, err)
-		return err
+	// 被冻结的进程无法处理信号，先解冻再停止
+	if info.Status == container.PAUSED {
+		if err = freezeContainer(info, false); err != nil {
+			logrus.Errorf("Unpause container %s error %v", info.Id, err)
+			return err
+		}
 	}
 
-	// 修改容器状态，将 Running 改为 Stopped，pid 可以设置为空
-	info.Pid = ""
-	info.Status = container.STOP
-	if err = UpdateContainerInfo(info); err != nil {
+	if info.Status.Alive() {
+		process, err := container.OpenProcess(info)
+		if err != nil {
+			var staleErr *container.StaleProcessError
+			if !errors.As(err, &staleErr) {
+				logrus.Errorf("Open container %s process error %v", info.Id, err)
+				return err
+			}
+			// 容器进程已经不存在了，不能再发送信号，直接释放资源
+			logrus.Warnf("Container %s: %v, skip sending signal", info.Id, err)
+		} else {
+			err = stopProcess(info, process, timeout, signalName)
+			process.Close()
+			if err != nil {
+				return err
+			}
+			// 重新读取容器信息，monitor 进程可能已经记录了容器的退出码
+			if info, err = waitExitRecorded(info.Id, exitRecordTimeout); err != nil {
+				if errors.Is(err, errContainerRemoved) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+
+	// 释放容器占用的资源，monitor 进程已经释放过的会跳过
+	cleanupContainer(info, false)
+
+	// 修改容器状态为 Stopped，pid 可以设置为空，其他字段以 monitor 进程记录的为准
+	ipAddress := info.IpAddress
+	var from container.State
+	current, err := store.Update(info.Id, func(current *container.ContainerInfo) error {
+		current.Pid = ""
+		current.IpAddress = ipAddress
+		if current.Status == container.STOP {
+			return nil
+		}
+		from = current.Status
+		return transition(current, "stop", container.STOP)
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotExist) {
+			return nil
+		}
 		logrus.Errorf("Update container info  %s error, %v", info.Id, err)
 		return err
 	}
+	if from == "" {
+		return nil
+	}
+	emitStateEvent(current, "stop", from)
+	// 容器进程已经不存在或者 monitor 进程没有记录退出，poststop hook 由 stop 命令执行
+	if from.Alive() {
+		if err := runHooks(current, container.HookPoststop, "stopped", 0); err != nil {
+			logrus.Warnf("%v", err)
+		}
+	}
 	return nil
 
 }
 
-// UpdateContainerInfo 根据 info 中的 容器 id 找到对应的 container 信息，并且修改
-func UpdateContainerInfo(info *container.ContainerInfo) error {
-	// 将 containerInfo 序列化成 json 字符串
-	infoBytes, err := json.Marshal(info)
-	if err != nil {
+// 发送 stop 信号并等待容器进程退出，超时后发送 SIGKILL
+func stopProcess(info *container.ContainerInfo, process *container.Process, timeout int, signalName string) error {
+	if signalName == "" {
+		signalName = info.StopSignal
+	}
+	stopSignal := syscall.SIGTERM
+	if signalName != "" {
+		var err error
+		if stopSignal, err = signal.ParseSignal(signalName); err != nil {
+			return err
+		}
+	}
+
+	// 发送 stop 信号给容器的主进程
+	if err := process.Signal(stopSignal); err != nil && err != syscall.ESRCH {
+		logrus.Errorf("Stop container %s error %v", info.Id, err)
+		return err
+	}
+	// exec 的命令也在容器中，与主进程一起收到 stop 信号
+	signalExecs(info, stopSignal)
+	if waitProcessExit(process, time.Duration(timeout)*time.Second) {
+		return nil
+	}
 
-		logrus.Errorf("Json marshal %s error %v", info.Id, err)
+	logrus.Warnf("Container %s did not exit within %d seconds, killing it", info.Id, timeout)
+	if err := process.Signal(syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		logrus.Errorf("Kill container %s error %v", info.Id, err)
 		return err
 	}
-	configFilePath := filepath.Join(info.RootUrl, container.ContainerConfigPath, container.ConfigName)
-	if err = os.WriteFile(configFilePath, infoBytes, 0622); err != nil {
-		logrus.Errorf("Write file %s error, %v", configFilePath, err)
+	signalExecs(info, syscall.SIGKILL)
+	if !waitProcessExit(process, killTimeout) {
+		return fmt.Errorf("container %s did not exit after SIGKILL", info.Id)
+	}
+	return nil
+}
+
+// 等待进程退出，超时返回 false，timeout 小于 0 表示一直等待
+// 容器进程不是当前进程的子进程，不能使用 wait，只能轮询
+func waitProcessExit(process *container.Process, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !process.Exited() {
+		if timeout >= 0 && time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+// UpdateContainerInfo 根据 info 中的 容器 id 找到对应的 container 信息，并且整体覆盖
+// 只用于独占容器的场景，读取后修改部分字段的使用 store.Update，避免覆盖其他命令同时做的修改
+func UpdateContainerInfo(info *container.ContainerInfo) error {
+	if err := store.Save(info); err != nil {
+		logrus.Errorf("Update container info %s error, %v", info.Id, err)
 		return err
 	}
 	return nil
@@ -0,0 +1,178 @@
+package cmdExec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// /proc/<pid>/stat 中 cpu 时间的单位，linux 上 USER_HZ 固定为 100
+const clockTicks = 100
+
+// 容器内一个进程的信息
+type containerProcess struct {
+	User    string
+	Pid     int    // 宿主机上的 pid
+	NsPid   string // 容器 pid namespace 中的 pid
+	CpuTime string
+	Rss     string // 常驻内存，单位 KB
+	Command string
+}
+
+/*
+TopContainer 列出容器中的所有进程
+容器中的进程从容器 cgroup 的 cgroup.procs 中获取，不需要再从 ps 的输出中找容器的 init 进程
+psArgs 不为空时，执行 ps 并只保留属于容器的进程
+*/
+func TopContainer(containerArg string, psArgs []string) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+	if err := requireState(info, "top", container.RUNNING, container.PAUSED); err != nil {
+		return err
+	}
+
+	pids, err := getContainerPids(info)
+	if err != nil {
+		log.Errorf("Get container %s pids error %v", info.Id, err)
+		return err
+	}
+
+	if len(psArgs) > 0 {
+		return topWithPs(pids, psArgs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "USER\tPID\tNSPID\tTIME\tRSS\tCOMMAND\n")
+	for _, pid := range pids {
+		process, err := readContainerProcess(pid)
+		if err != nil {
+			// 读取过程中进程可能已经退出了
+			log.Debugf("Read process %d error %v", pid, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			process.User,
+			process.Pid,
+			process.NsPid,
+			process.CpuTime,
+			process.Rss,
+			process.Command)
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("Flush error %v", err)
+		return err
+	}
+	return nil
+}
+
+// 通过 CgroupManager 计算出的容器 cgroup 路径，获取容器中的所有进程
+func getContainerPids(info *container.ContainerInfo) ([]int, error) {
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+	return cgroupManager.GetPids()
+}
+
+// 从 /proc/<pid> 中读取进程的用户、namespace pid、cpu 时间、内存和命令
+func readContainerProcess(pid int) (*containerProcess, error) {
+	process := &containerProcess{Pid: pid, Rss: "0"}
+
+	status, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer status.Close()
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			process.User = fields[1]
+			if u, err := user.LookupId(fields[1]); err == nil {
+				process.User = u.Username
+			}
+		case "NSpid:":
+			// 从外到内每一层 pid namespace 中的 pid，最后一个就是容器中的 pid
+			process.NsPid = fields[len(fields)-1]
+		case "VmRSS:":
+			process.Rss = fields[1]
+		}
+	}
+
+	stat, err := container.ReadProcStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	// utime 和 stime 分别是第 14、15 个字段
+	utime, _ := strconv.ParseUint(stat[11], 10, 64)
+	stime, _ := strconv.ParseUint(stat[12], 10, 64)
+	seconds := (utime + stime) / clockTicks
+	process.CpuTime = fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+	process.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	if process.Command == "" {
+		comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		process.Command = "[" + strings.TrimSpace(string(comm)) + "]"
+	}
+	return process, nil
+}
+
+// 执行 ps 命令，只输出属于容器的进程
+func topWithPs(pids []int, psArgs []string) error {
+	output, err := exec.Command("ps", psArgs...).Output()
+	if err != nil {
+		return fmt.Errorf("error running ps: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	// 从表头中找到 PID 所在的列
+	pidIndex := -1
+	for i, name := range strings.Fields(lines[0]) {
+		if name == "PID" {
+			pidIndex = i
+			break
+		}
+	}
+	if pidIndex == -1 {
+		return fmt.Errorf("couldn't find PID field in ps output")
+	}
+
+	containerPids := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		containerPids[pid] = true
+	}
+
+	fmt.Println(lines[0])
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) <= pidIndex {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[pidIndex])
+		if err != nil {
+			return fmt.Errorf("unexpected pid '%s': %v", fields[pidIndex], err)
+		}
+		if containerPids[pid] {
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmdExec
+
+import (
+	"fmt"
+
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	"github.com/Nevermore12321/dockergsh/store"
+	"github.com/Nevermore12321/dockergsh/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+UpdateContainer 修改容器的资源限制
+1. 容器正在运行时，把新的限制写入容器已有的 cgroup，内核拒绝时返回错误，不修改记录的配置
+2. 新的配置合并到 ContainerInfo.Resources 中，容器再次启动时使用
+resConf 中为零值的字段表示不修改
+*/
+func UpdateContainer(containerArg string, resConf *subsystem.ResourceConfig) error {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return err
+	}
+
+	// 读取、写入 cgroup 和保存配置在容器记录的锁中完成，两个并发的 update 不会丢失修改
+	_, err = store.Update(info.Id, func(info *container.ContainerInfo) error {
+		if info.Resources == nil {
+			info.Resources = &subsystem.ResourceConfig{}
+		}
+		// 只修改 swap 时，使用容器当前的内存限制计算 v2 的 memory.swap.max
+		if resConf.MemorySwap > 0 && resConf.Memory == 0 {
+			resConf.Memory = info.Resources.Memory
+		}
+		if err := verifyResourceConfig(resConf); err != nil {
+			return err
+		}
+
+		if info.Status == container.RUNNING || info.Status == container.PAUSED {
+			// 确认容器进程还是启动时的进程，避免修改一个已经失效的 cgroup
+			process, err := container.OpenProcess(info)
+			if err != nil {
+				log.Errorf("Open container %s process error %v", info.Id, err)
+				return err
+			}
+			process.Close()
+
+			if err = applyContainerResources(info, resConf); err != nil {
+				log.Errorf("Update container %s resources error %v", info.Id, err)
+				return fmt.Errorf("update container %s resources error: %v", info.Id, err)
+			}
+		}
+
+		info.Resources.Merge(resConf)
+		return nil
+	})
+	return err
+}
+
+// 将资源限制写入运行中容器的 cgroup
+func applyContainerResources(info *container.ContainerInfo, resConf *subsystem.ResourceConfig) error {
+	cgroupManager := cgroup.NewCgroupManager(utils.EncodeSha256([]byte(info.Id)))
+
+	// cgroup v2 中 memory.max 低于当前使用量时内核会回收内存甚至触发 OOM，不会返回错误，这里提前检查
+	if resConf.Memory > 0 {
+		stats, err := cgroupManager.Stats()
+		if err != nil {
+			return err
+		}
+		if err := checkMemoryLimit(resConf.Memory, stats); err != nil {
+			return err
+		}
+	}
+
+	return cgroupManager.Set(resConf)
+}
+
+// 新的内存限制不能低于容器当前的内存使用量，page cache 可以被内核回收，不计算在内
+func checkMemoryLimit(memory int64, stats *subsystem.Stats) error {
+	var usage uint64
+	if stats.MemoryUsage > stats.MemoryCache {
+		usage = stats.MemoryUsage - stats.MemoryCache
+	}
+	if uint64(memory) < usage {
+		return fmt.Errorf("memory limit %s is lower than the current memory usage %s",
+			units.BytesSize(float64(memory)), units.BytesSize(float64(usage)))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package cmdExec
+
+import (
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+)
+
+func TestCheckMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		memory  int64
+		stats   *subsystem.Stats
+		wantErr bool
+	}{
+		{"above usage", 200 << 20, &subsystem.Stats{MemoryUsage: 100 << 20}, false},
+		{"below usage", 50 << 20, &subsystem.Stats{MemoryUsage: 100 << 20}, true},
+		// page cache 可以被回收，不计算在使用量中
+		{"below usage with page cache", 50 << 20, &subsystem.Stats{MemoryUsage: 100 << 20, MemoryCache: 80 << 20}, false},
+		{"below usage without page cache", 10 << 20, &subsystem.Stats{MemoryUsage: 100 << 20, MemoryCache: 80 << 20}, true},
+		{"cache larger than usage", 1 << 20, &subsystem.Stats{MemoryUsage: 10 << 20, MemoryCache: 20 << 20}, false},
+	}
+	for _, test := range tests {
+		err := checkMemoryLimit(test.memory, test.stats)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: checkMemoryLimit error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cmdExec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/internal/events"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
+	"github.com/Nevermore12321/dockergsh/volume"
+	log "github.com/sirupsen/logrus"
+)
+
+// CreateNamedVolume 创建命名 volume，并输出 volume 名
+func CreateNamedVolume(name string, labels map[string]string) error {
+	vol, err := volume.Create(name, labels)
+	if err != nil {
+		return err
+	}
+	fmt.Println(vol.Name)
+	return nil
+}
+
+// ListVolumes 输出所有满足 filterArgs 的命名 volume
+func ListVolumes(filterArgs filters.Args) error {
+	volumes, err := volume.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "NAME\tMOUNTPOINT\tCREATED\n")
+	for _, vol := range volumes {
+		if !filterArgs.MatchLabels(vol.Labels) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", vol.Name, vol.Mountpoint, vol.CreatedAt)
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("Flush error %v", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveVolume 删除命名 volume，还有容器使用时拒绝删除
+func RemoveVolume(name string) error {
+	vol, err := volume.Get(name)
+	if err != nil {
+		return err
+	}
+	containers, err := listContainerInfos()
+	if err != nil {
+		return err
+	}
+	for _, info := range containers {
+		if vol.InUse(info.Volume) {
+			return fmt.Errorf("volume %s is in use by container %s", name, info.Id)
+		}
+	}
+	return volume.Remove(name)
+}
+
+// 记录容器挂载 volume 的事件，挂载宿主机路径时以宿主机路径作为事件的 id
+func emitVolumeMount(info *container.ContainerInfo) {
+	if info.Volume == "" {
+		return
+	}
+	source, destination, _ := strings.Cut(info.Volume, ":")
+	id := source
+	if vol := volume.Of(info.Volume); vol != nil {
+		id = vol.Name
+	}
+	events.Log(events.VolumeEventType, "mount", id, map[string]string{"container": info.Id, "destination": destination})
+}
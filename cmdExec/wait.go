@@ -0,0 +1,43 @@
+package cmdExec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	log "github.com/sirupsen/logrus"
+)
+
+// WaitContainer 阻塞直到容器退出，返回容器的退出码
+func WaitContainer(containerArg string) (int, error) {
+	info, err := GetContainerInfoByArg(containerArg)
+	if err != nil {
+		log.Errorf("Get container %s info error %v", containerArg, err)
+		return -1, err
+	}
+
+	if info.Status.Alive() {
+		process, err := container.OpenProcess(info)
+		if err != nil {
+			var staleErr *container.StaleProcessError
+			if !errors.As(err, &staleErr) {
+				log.Errorf("Open container %s process error %v", info.Id, err)
+				return -1, err
+			}
+			log.Warnf("Container %s: %v", info.Id, err)
+		} else {
+			waitProcessExit(process, -1)
+			process.Close()
+		}
+
+		// 退出码由 monitor 进程记录
+		containerId := info.Id
+		if info, err = waitExitRecorded(containerId, exitRecordTimeout); err != nil {
+			if errors.Is(err, errContainerRemoved) {
+				return -1, fmt.Errorf("container %s exited and was removed", containerId)
+			}
+			return -1, err
+		}
+	}
+	return info.ExitCode, nil
+}
@@ -0,0 +1,244 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/signal"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	"github.com/urfave/cli/v2"
+)
+
+// run 和 create 共用的容器参数
+var containerFlags = append([]cli.Flag{
+	&cli.BoolFlag{
+		Name:  "oom-kill-disable",
+		Usage: "disable OOM killer (cgroup v1 only)",
+	},
+	&cli.BoolFlag{
+		Name:  "oom-kill-group",
+		Usage: "kill all processes in the container when the OOM killer is triggered (cgroup v2 only)",
+	},
+	&cli.StringSliceFlag{
+		Name:  "device-read-bps",
+		Usage: "limit read rate (bytes per second) from a device, e.g. /dev/sda:1mb",
+	},
+	&cli.StringSliceFlag{
+		Name:  "device-write-bps",
+		Usage: "limit write rate (bytes per second) to a device, e.g. /dev/sda:1mb",
+	},
+	&cli.StringSliceFlag{
+		Name:  "device-read-iops",
+		Usage: "limit read rate (IO per second) from a device, e.g. /dev/sda:1000",
+	},
+	&cli.StringSliceFlag{
+		Name:  "device-write-iops",
+		Usage: "limit write rate (IO per second) to a device, e.g. /dev/sda:1000",
+	},
+	&cli.StringSliceFlag{
+		Name:  "hugetlb-limit",
+		Usage: "huge page limit, e.g. 2MB:100m",
+	},
+	&cli.StringFlag{
+		Name:  "v",
+		Usage: "Volume",
+	},
+	&cli.StringFlag{
+		Name:  "name",
+		Usage: "container name",
+	},
+	&cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "set environments",
+	},
+	&cli.StringFlag{
+		Name:  "net",
+		Usage: "container network",
+	},
+	&cli.StringSliceFlag{
+		Name:  "device",
+		Usage: "add a host device to the container, e.g. /dev/fuse:/dev/fuse:rwm",
+	},
+	&cli.BoolFlag{
+		Name:  "init",
+		Usage: "run an init inside the container that forwards signals and reaps processes",
+	},
+	&cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "signal to stop the container",
+		Value: "SIGTERM",
+	},
+	&cli.StringFlag{
+		Name:  "cgroupns",
+		Usage: "cgroup namespace to use (host|private), default private on cgroup v2 and host on cgroup v1",
+	},
+	&cli.StringFlag{
+		Name:  "shm-size",
+		Usage: "size of /dev/shm, e.g. 64m, 1g",
+		Value: units.BytesSize(float64(container.DefaultShmSize)),
+	},
+	&cli.BoolFlag{
+		Name:  "rm",
+		Usage: "automatically remove the container and its anonymous volumes when it exits",
+	},
+	&cli.StringSliceFlag{
+		Name:  "hook",
+		Usage: "add an OCI hook (prestart|createRuntime|poststart|poststop), e.g. poststart=/usr/local/bin/audit --tag web",
+	},
+}, append(append(resourceFlags, healthFlags...), labelFlags...)...)
+
+// 解析 run 和 create 共用的参数，第一个位置参数为镜像名，之后为用户命令
+func parseContainerOptions(context *cli.Context) (*cmdExec.ContainerOptions, error) {
+	if context.NArg() < 1 {
+		return nil, fmt.Errorf("Missing container command")
+	}
+
+	// 要执行的 命令
+	var cmdArray []string
+	for i := 0; i < context.NArg(); i++ {
+		cmdArray = append(cmdArray, context.Args().Get(i))
+	}
+
+	// docker run --it [imageName]
+	// docker run -it -m 100m busybox stress --vm-bytes 200m --vm-keep -m 1
+
+	// 获取 image name
+	imageName := cmdArray[0]
+	cmdArray = cmdArray[1:]
+
+	// 获取 选项参数变量
+	// volume，命名 volume 和匿名 volume 在创建容器时解析为宿主机上的数据目录
+	volume := context.String("v")
+
+	// 获取 container network 参数变量
+	network := context.String("net")
+
+	// container name
+	containerName := context.String("name")
+
+	// 环境变量
+	envSlice := context.StringSlice("e")
+
+	// 需要透传给容器的宿主机设备
+	var devices []*container.Device
+	for _, spec := range context.StringSlice("device") {
+		device, err := container.ParseDevice(spec)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+
+	// cgroup 资源配置
+	resConf, err := parseResourceFlags(context)
+	if err != nil {
+		return nil, err
+	}
+	resConf.OomKillDisable = context.Bool("oom-kill-disable")
+	resConf.OomKillGroup = context.Bool("oom-kill-group")
+	resConf.DeviceRules = container.DeviceRules(devices)
+
+	// 块设备限速
+	throttles := []struct {
+		flag    string
+		isBps   bool
+		devices *[]*subsystem.ThrottleDevice
+	}{
+		{"device-read-bps", true, &resConf.ReadBpsDevice},
+		{"device-write-bps", true, &resConf.WriteBpsDevice},
+		{"device-read-iops", false, &resConf.ReadIOpsDevice},
+		{"device-write-iops", false, &resConf.WriteIOpsDevice},
+	}
+	for _, throttle := range throttles {
+		for _, spec := range context.StringSlice(throttle.flag) {
+			device, err := container.ParseThrottleDevice(spec, throttle.isBps)
+			if err != nil {
+				return nil, err
+			}
+			*throttle.devices = append(*throttle.devices, device)
+		}
+	}
+
+	// 大页内存限制
+	for _, spec := range context.StringSlice("hugetlb-limit") {
+		limit, err := subsystem.ParseHugetlbLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		resConf.HugetlbLimits = append(resConf.HugetlbLimits, limit)
+	}
+
+	// stop 信号在启动时就校验，避免 stop 的时候才发现信号不合法
+	stopSignal := context.String("stop-signal")
+	if _, err := signal.ParseSignal(stopSignal); err != nil {
+		return nil, err
+	}
+
+	// --shm-size 在创建容器时就转换成字节数，避免容器启动挂载 /dev/shm 时才发现大小不合法
+	shmSize, err := units.RAMInBytes(context.String("shm-size"))
+	if err != nil {
+		return nil, err
+	}
+	if shmSize <= 0 {
+		return nil, fmt.Errorf("invalid shm size %q, it should be greater than zero", context.String("shm-size"))
+	}
+
+	cgroupNs := context.String("cgroupns")
+	if cgroupNs != "" && cgroupNs != "host" && cgroupNs != "private" {
+		return nil, fmt.Errorf("invalid cgroupns %q, it should be host or private", cgroupNs)
+	}
+
+	// 健康检查和重启策略
+	healthConfig, err := parseHealthFlags(context)
+	if err != nil {
+		return nil, err
+	}
+	restartPolicy, err := parseRestartPolicy(context, healthConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := parseLabelFlags(context)
+	if err != nil {
+		return nil, err
+	}
+
+	// --rm 的容器退出后就被删除，不能再重启
+	autoRemove := context.Bool("rm")
+	if autoRemove && restartPolicy != container.RestartNo {
+		return nil, fmt.Errorf("--rm and --restart %s can not both provided", restartPolicy)
+	}
+
+	// --hook 指定的 hook，hooks 目录中的 hook 在创建容器时加入
+	hooks := &container.Hooks{}
+	for _, spec := range context.StringSlice("hook") {
+		stage, hook, err := container.ParseHook(spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := hooks.Add(stage, *hook); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cmdExec.ContainerOptions{
+		Command:     cmdArray,
+		Image:       imageName,
+		Name:        containerName,
+		Volume:      volume,
+		Env:         envSlice,
+		Network:     network,
+		Devices:     devices,
+		Resources:   resConf,
+		ShmSize:     shmSize,
+		Init:        context.Bool("init"),
+		StopSignal:  stopSignal,
+		CgroupNs:    cgroupNs,
+		Healthcheck: healthConfig,
+		Restart:     restartPolicy,
+		Labels:      labels,
+		AutoRemove:  autoRemove,
+		Hooks:       hooks,
+	}, nil
+}
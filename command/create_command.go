@@ -0,0 +1,23 @@
+package command
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+)
+
+// 创建容器但不启动，参数与 run 相同
+var CreateCommand = &cli.Command{
+	Name: "create",
+	Usage: `Create a new container without starting it
+			dockergsh create [image] [command]`,
+	Flags: containerFlags,
+	Action: func(context *cli.Context) error {
+		// 解析镜像名、用户命令和容器配置
+		opts, err := parseContainerOptions(context)
+		if err != nil {
+			return err
+		}
+		return cmdExec.CreateContainer(opts)
+	},
+}
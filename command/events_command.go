@@ -0,0 +1,43 @@
+package command
+
+import (
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var EventsCommand = &cli.Command{
+	Name:  "events",
+	Usage: "Get real time events of containers, networks and volumes",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "Show all events created since timestamp, e.g. 2006-01-02T15:04:05Z, 1136214245 or 10m, without it only new events are shown",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "Stream events until this timestamp, without it new events are followed",
+		},
+		&cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "Filter output based on conditions provided, e.g. --filter type=container --filter event=die",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Format the output, only json is supported",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		filterArgs, err := filters.Parse(context.StringSlice("filter"), "type", "event", "container", "network", "volume", "label")
+		if err != nil {
+			return err
+		}
+		err = cmdExec.StreamEvents(context.String("since"), context.String("until"), filterArgs, context.String("format"))
+		if err != nil {
+			log.Errorf("Stream events failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
@@ -1,39 +1,91 @@
 package command
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 
 	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/Nevermore12321/dockergsh/container"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
 var ExecCommand = &cli.Command{
-	Name:  "exec",
-	Usage: "Run a command in a running container",
+	Name:      "exec",
+	Usage:     "Run a command in a running container",
+	ArgsUsage: "CONTAINER COMMAND [ARG...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "d",
+			Usage: "Detached mode: run command in the background",
+		},
+		&cli.StringSliceFlag{
+			Name:  "e",
+			Usage: "Set environment variables",
+		},
+		&cli.StringFlag{
+			Name:  "w",
+			Usage: "Working directory inside the container",
+		},
+		&cli.StringFlag{
+			Name:  "u",
+			Usage: "Username or UID (format: <name|uid>[:<group|gid>])",
+		},
+	},
+	// ls、inspect 写在容器名之前时作为子命令，这两个容器名在创建和重命名时会被拒绝，不会产生冲突
+	Subcommands: []*cli.Command{
+		{
+			Name:      "ls",
+			Usage:     "List execs of a container",
+			ArgsUsage: "CONTAINER",
+			Action: func(context *cli.Context) error {
+				if context.NArg() < 1 {
+					return fmt.Errorf("missing container name")
+				}
+				return cmdExec.ListExecs(context.Args().Get(0))
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "Display detailed information on an exec",
+			ArgsUsage: "EXEC_ID",
+			Action: func(context *cli.Context) error {
+				if context.NArg() < 1 {
+					return fmt.Errorf("missing exec id")
+				}
+				return cmdExec.InspectExec(context.Args().Get(0))
+			},
+		},
+	},
 	Action: func(context *cli.Context) error {
 		// 控制是 docker exec 第一次执行，还是添加环境变量后第二次执行 /proc/self/exe exec
-		if os.Getenv("dockergsh_pid") != "" {
-			// 已经添加了环境变量，第二次执行，只需要执行 C 代码即可，该文件已经导入了 C 库，因此直接返回
-			log.Infof("pid callback pid %s", os.Getgid())
-			return nil
+		if os.Getenv(cmdExec.ENV_EXEC_PID) != "" {
+			// 第二次执行时，C 代码已经进入了容器，从管道读取配置并执行用户命令，成功时不会返回
+			err := container.RunExecProcess()
+			// 与 docker 一致，找不到命令时退出码为 127，无法执行时为 126
+			if errors.Is(err, exec.ErrNotFound) {
+				return cli.Exit(err.Error(), 127)
+			}
+			return cli.Exit(err.Error(), 126)
 		}
 		if context.NArg() < 2 {
 			return fmt.Errorf("missing container name or command")
 		}
 		containerArg := context.Args().Get(0)
-		var commandArr []string
-		for _, arg := range context.Args().Tail() {
-			commandArr = append(commandArr, arg)
-		}
+		commandArr := context.Args().Tail()
 
-		err := cmdExec.ExecInContainer(containerArg, commandArr)
+		exitCode, err := cmdExec.ExecInContainer(containerArg, commandArr, context.StringSlice("e"), context.String("w"), context.String("u"), context.Bool("d"))
 		if err != nil {
 			log.Errorf("Exec Container failed %v", err)
 			return err
 		}
+		// 与容器中命令的退出码保持一致
+		if exitCode != 0 {
+			return cli.Exit("", exitCode)
+		}
 		return nil
 	},
 }
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/urfave/cli/v2"
+)
+
+// run 的健康检查和重启策略参数，与 docker 保持一致
+var healthFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "health-cmd",
+		Usage: "command to run to check health, executed with sh -c inside the container",
+	},
+	&cli.DurationFlag{
+		Name:  "health-interval",
+		Usage: "time between running the check",
+		Value: 30 * time.Second,
+	},
+	&cli.DurationFlag{
+		Name:  "health-timeout",
+		Usage: "maximum time to allow one check to run",
+		Value: 30 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:  "health-retries",
+		Usage: "consecutive failures needed to report unhealthy",
+		Value: 3,
+	},
+	&cli.DurationFlag{
+		Name:  "health-start-period",
+		Usage: "start period for the container to initialize before counting retries towards unstable",
+	},
+	&cli.StringFlag{
+		Name:  "restart",
+		Usage: "restart policy to apply (no|on-unhealthy)",
+		Value: container.RestartNo,
+	},
+}
+
+// 解析健康检查参数，没有指定 --health-cmd 时返回 nil
+func parseHealthFlags(context *cli.Context) (*container.HealthConfig, error) {
+	healthCmd := context.String("health-cmd")
+	if healthCmd == "" {
+		return nil, nil
+	}
+	config := &container.HealthConfig{
+		Test:        []string{"sh", "-c", healthCmd},
+		Interval:    context.Duration("health-interval"),
+		Timeout:     context.Duration("health-timeout"),
+		StartPeriod: context.Duration("health-start-period"),
+		Retries:     context.Int("health-retries"),
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("--health-interval must be positive")
+	}
+	if config.Timeout <= 0 {
+		return nil, fmt.Errorf("--health-timeout must be positive")
+	}
+	if config.StartPeriod < 0 {
+		return nil, fmt.Errorf("--health-start-period can not be negative")
+	}
+	if config.Retries < 1 {
+		return nil, fmt.Errorf("--health-retries must be at least 1")
+	}
+	return config, nil
+}
+
+// 解析重启策略，on-unhealthy 依赖健康检查和后台的 monitor 进程
+func parseRestartPolicy(context *cli.Context, healthConfig *container.HealthConfig) (string, error) {
+	policy := context.String("restart")
+	switch policy {
+	case container.RestartNo:
+		return policy, nil
+	case container.RestartOnUnhealthy:
+		if healthConfig == nil {
+			return "", fmt.Errorf("--restart on-unhealthy requires --health-cmd")
+		}
+		if context.Bool("it") {
+			return "", fmt.Errorf("--restart on-unhealthy can not be used with -it")
+		}
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid restart policy %q, it should be no or on-unhealthy", policy)
+}
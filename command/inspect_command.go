@@ -0,0 +1,25 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var InspectCommand = &cli.Command{
+	Name:  "inspect",
+	Usage: "Display detailed information on a container",
+	Action: func(context *cli.Context) error {
+		// dockergsh inspect [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		if err := cmdExec.InspectContainer(containerArg); err != nil {
+			log.Errorf("Inspect container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,33 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var KillCommand = &cli.Command{
+	Name:  "kill",
+	Usage: "Kill one or more running containers",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "s",
+			Usage: "signal to send to the container",
+			Value: "KILL",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		// dockergsh kill -s [signal] [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		err := cmdExec.KillContainer(containerArg, context.String("s"))
+		if err != nil {
+			log.Errorf("Kill Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// run、network create 和 volume create 共用的标签参数
+var labelFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:  "label",
+		Usage: "set metadata, e.g. --label env=prod",
+	},
+	&cli.StringSliceFlag{
+		Name:  "label-file",
+		Usage: "read in a line delimited file of labels",
+	},
+}
+
+// filter 参数，ps 和 network list 共用
+var filterFlag = &cli.StringSliceFlag{
+	Name:  "filter",
+	Usage: "filter output based on conditions provided, e.g. --filter label=env=prod",
+}
+
+/*
+解析 --label 和 --label-file，--label 覆盖文件中的同名标签
+只指定 key 时值为空字符串，文件中的空行和 # 开头的行会被忽略
+*/
+func parseLabelFlags(context *cli.Context) (map[string]string, error) {
+	var specs []string
+	for _, labelFile := range context.StringSlice("label-file") {
+		lines, err := readLabelFile(labelFile)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, lines...)
+	}
+	specs = append(specs, context.StringSlice("label")...)
+
+	labels := make(map[string]string)
+	for _, spec := range specs {
+		key, value, _ := strings.Cut(spec, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid label %q, key can not be empty", spec)
+		}
+		labels[key] = value
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+func readLabelFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open label file %s error %v", path, err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
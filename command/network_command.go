@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/network"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
@@ -14,7 +15,7 @@ var NetworkCommand = &cli.Command{
 		{
 			Name:  "create",
 			Usage: "Create a network",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "driver",
 					Usage: "Driver to manage the Network (default \"bridge\")",
@@ -23,16 +24,20 @@ var NetworkCommand = &cli.Command{
 					Name:  "subnet",
 					Usage: "Subnet in CIDR format that represents a network segment",
 				},
-			},
+			}, labelFlags...),
 			Action: func(context *cli.Context) error {
 				if context.NArg() < 1 {
 					return fmt.Errorf("missing network name")
 				}
+				labels, err := parseLabelFlags(context)
+				if err != nil {
+					return err
+				}
 				if err := network.Init(); err != nil {
 					return err
 				}
 				// 创建网络
-				err := network.CreateNetwork(context.String("driver"), context.String("subnet"), context.Args().Get(0))
+				err = network.CreateNetwork(context.String("driver"), context.String("subnet"), context.Args().Get(0), labels)
 				if err != nil {
 					log.Errorf("commit container err: %v", err)
 					return err
@@ -43,11 +48,16 @@ var NetworkCommand = &cli.Command{
 		{
 			Name:  "list",
 			Usage: "List networks",
+			Flags: []cli.Flag{filterFlag},
 			Action: func(context *cli.Context) error {
+				filterArgs, err := filters.Parse(context.StringSlice("filter"), "label")
+				if err != nil {
+					return err
+				}
 				if err := network.Init(); err != nil {
 					return err
 				}
-				if err := network.ListNetwork(); err != nil {
+				if err := network.ListNetwork(filterArgs); err != nil {
 					return err
 				}
 				return nil
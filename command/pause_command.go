@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var PauseCommand = &cli.Command{
+	Name:  "pause",
+	Usage: "Pause all processes within one or more containers",
+	Action: func(context *cli.Context) error {
+		// dockergsh pause [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		err := cmdExec.PauseContainer(containerArg)
+		if err != nil {
+			log.Errorf("Pause Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
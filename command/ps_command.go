@@ -2,14 +2,20 @@ package command
 
 import (
 	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
 	"github.com/urfave/cli/v2"
 )
 
 var ListCommand = &cli.Command{
 	Name: "ps",
 	Usage: "list all the containers",
+	Flags: []cli.Flag{filterFlag},
 	Action: func(context *cli.Context) error {
-		cmdExec.ListContainers()
+		filterArgs, err := filters.Parse(context.StringSlice("filter"), "label")
+		if err != nil {
+			return err
+		}
+		cmdExec.ListContainers(filterArgs)
 		return nil
 	},
 }
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var RenameCommand = &cli.Command{
+	Name:  "rename",
+	Usage: "Rename a container",
+	Action: func(context *cli.Context) error {
+		// dockergsh rename [containerName or containerId] [newName]
+		if context.NArg() < 2 {
+			return fmt.Errorf("missing container name or new name")
+		}
+		containerArg := context.Args().Get(0)
+		newName := context.Args().Get(1)
+		if err := cmdExec.RenameContainer(containerArg, newName); err != nil {
+			log.Errorf("Rename Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
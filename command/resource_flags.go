@@ -0,0 +1,116 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	"github.com/urfave/cli/v2"
+)
+
+// run 和 update 共用的资源限制参数，与 docker 保持一致
+var resourceFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "memory",
+		Aliases: []string{"m"},
+		Usage:   "memory limit, e.g. 512m",
+	},
+	&cli.StringFlag{
+		Name:  "memory-swap",
+		Usage: "total memory plus swap limit, -1 for unlimited swap",
+	},
+	&cli.StringFlag{
+		Name:  "memory-reservation",
+		Usage: "memory soft limit",
+	},
+	&cli.StringFlag{
+		Name:  "cpus",
+		Usage: "number of cpus, e.g. 1.5",
+	},
+	&cli.Uint64Flag{
+		Name:  "cpu-shares",
+		Usage: "cpu shares (relative weight)",
+	},
+	&cli.Int64Flag{
+		Name:  "cpu-quota",
+		Usage: "limit cpu CFS (Completely Fair Scheduler) quota in microseconds",
+	},
+	&cli.Uint64Flag{
+		Name:  "cpu-period",
+		Usage: "limit cpu CFS (Completely Fair Scheduler) period in microseconds",
+	},
+	&cli.StringFlag{
+		Name:    "cpuset-cpus",
+		Aliases: []string{"cpuset"},
+		Usage:   "cpus in which to allow execution, e.g. 0-3 or 0,1",
+	},
+	&cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "max number of processes, -1 for unlimited",
+	},
+	&cli.UintFlag{
+		Name:  "blkio-weight",
+		Usage: "block IO relative weight, between 10 and 1000",
+	},
+}
+
+/*
+将资源限制参数统一转换为 ResourceConfig：
+- 内存大小可以带单位，例如 512m、1g，转换为字节数
+- --cpus 转换为默认周期内的 cpu quota，不能与 --cpu-quota/--cpu-period 同时使用
+- pids 限制 0 和负数表示不限制
+*/
+func parseResourceFlags(context *cli.Context) (*subsystem.ResourceConfig, error) {
+	resConf := &subsystem.ResourceConfig{
+		CpuShares:  context.Uint64("cpu-shares"),
+		CpuQuota:   context.Int64("cpu-quota"),
+		CpuPeriod:  context.Uint64("cpu-period"),
+		CpusetCpus: context.String("cpuset-cpus"),
+	}
+
+	var err error
+	if memory := context.String("memory"); memory != "" {
+		if resConf.Memory, err = units.RAMInBytes(memory); err != nil {
+			return nil, err
+		}
+	}
+	if memorySwap := context.String("memory-swap"); memorySwap == "-1" {
+		resConf.MemorySwap = -1
+	} else if memorySwap != "" {
+		if resConf.MemorySwap, err = units.RAMInBytes(memorySwap); err != nil {
+			return nil, err
+		}
+	}
+	if memoryReservation := context.String("memory-reservation"); memoryReservation != "" {
+		if resConf.MemoryReservation, err = units.RAMInBytes(memoryReservation); err != nil {
+			return nil, err
+		}
+	}
+
+	if cpus := context.String("cpus"); cpus != "" {
+		if context.IsSet("cpu-quota") || context.IsSet("cpu-period") {
+			return nil, fmt.Errorf("conflicting options: --cpus and --cpu-period/--cpu-quota")
+		}
+		value, err := strconv.ParseFloat(cpus, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid cpus %q, it should be a positive number", cpus)
+		}
+		resConf.CpuQuota, resConf.CpuPeriod = subsystem.CpusToQuota(value)
+	}
+
+	if context.IsSet("pids-limit") {
+		resConf.PidsLimit = context.Int64("pids-limit")
+		if resConf.PidsLimit <= 0 {
+			resConf.PidsLimit = -1
+		}
+	}
+	if context.IsSet("blkio-weight") {
+		weight := context.Uint("blkio-weight")
+		if weight < 10 || weight > 1000 {
+			return nil, fmt.Errorf("range of blkio weight is from 10 to 1000")
+		}
+		resConf.BlkioWeight = uint16(weight)
+	}
+	return resConf, nil
+}
@@ -10,12 +10,19 @@ import (
 var RemoveCommand = &cli.Command{
 	Name:  "rm",
 	Usage: "Remove one or more containers",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "force the removal of a running container (uses SIGKILL)",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if context.NArg() < 1 {
 			return fmt.Errorf("Missing container name")
 		}
 		containerArg := context.Args().Get(0)
-		err := cmdExec.RemoveContainer(containerArg)
+		err := cmdExec.RemoveContainer(containerArg, context.Bool("force"))
 		if err != nil {
 			log.Errorf("Remove Container failed %v", err)
 			return err
@@ -0,0 +1,25 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var StartCommand = &cli.Command{
+	Name:  "start",
+	Usage: "Start a created or stopped container",
+	Action: func(context *cli.Context) error {
+		// dockergsh start [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		if err := cmdExec.StartContainer(containerArg); err != nil {
+			log.Errorf("Start Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
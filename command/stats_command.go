@@ -0,0 +1,34 @@
+package command
+
+import (
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var StatsCommand = &cli.Command{
+	Name:  "stats",
+	Usage: "Display a live stream of container(s) resource usage statistics",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "Disable streaming stats and only pull the first result",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format, table or json",
+			Value: "table",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		// dockergsh stats [containerName or containerId...]
+		noStream := context.Bool("no-stream")
+		format := context.String("format")
+		err := cmdExec.StatsContainers(context.Args().Slice(), noStream, format)
+		if err != nil {
+			log.Errorf("Stats Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
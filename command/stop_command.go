@@ -10,13 +10,24 @@ import (
 var StopCommand = &cli.Command{
 	Name:  "stop",
 	Usage: "Stop one or more running containers",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "t",
+			Usage: "seconds to wait for stop before killing it",
+			Value: 10,
+		},
+		&cli.StringFlag{
+			Name:  "s",
+			Usage: "signal to send to the container, default is the --stop-signal of the container",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		// dockergsh stop [containerName or containerId]
 		if context.NArg() < 1 {
 			return fmt.Errorf("missing container name")
 		}
 		containerArg := context.Args().Get(0)
-		err := cmdExec.StopContainer(containerArg)
+		err := cmdExec.StopContainer(containerArg, context.Int("t"), context.String("s"))
 		if err != nil {
 			log.Errorf("Stop Container failed %v", err)
 			return err
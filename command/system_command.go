@@ -0,0 +1,26 @@
+package command
+
+import (
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/urfave/cli/v2"
+)
+
+var SystemCommand = &cli.Command{
+	Name:  "system",
+	Usage: "Manage dockergsh",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "prune",
+			Usage: "Remove state left behind by crashed containers and processes",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only report the inconsistencies, do not fix them",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				return cmdExec.SystemPrune(context.Bool("dry-run"))
+			},
+		},
+	},
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var TopCommand = &cli.Command{
+	Name:  "top",
+	Usage: "Display the running processes of a container",
+	Action: func(context *cli.Context) error {
+		// dockergsh top [containerName or containerId] [ps OPTIONS]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		err := cmdExec.TopContainer(containerArg, context.Args().Tail())
+		if err != nil {
+			log.Errorf("Top Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,38 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var UpdateCommand = &cli.Command{
+	Name:  "update",
+	Usage: "Update resource limits of a container",
+	Flags: resourceFlags,
+	Action: func(context *cli.Context) error {
+		// dockergsh update [OPTIONS] [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		if context.NumFlags() == 0 {
+			return fmt.Errorf("you must provide one or more flags when using this command")
+		}
+		containerArg := context.Args().Get(0)
+
+		// 参数在写入 cgroup 之前先全部解析，避免只修改了一部分限制
+		resConf, err := parseResourceFlags(context)
+		if err != nil {
+			return err
+		}
+
+		err = cmdExec.UpdateContainer(containerArg, resConf)
+		if err != nil {
+			log.Errorf("Update Container failed %v", err)
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
+	"github.com/urfave/cli/v2"
+)
+
+var VolumeCommand = &cli.Command{
+	Name:  "volume",
+	Usage: "Manage volumes",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "create",
+			Usage: "Create a volume",
+			Flags: labelFlags,
+			Action: func(context *cli.Context) error {
+				if context.NArg() < 1 {
+					return fmt.Errorf("missing volume name")
+				}
+				labels, err := parseLabelFlags(context)
+				if err != nil {
+					return err
+				}
+				return cmdExec.CreateNamedVolume(context.Args().Get(0), labels)
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "List volumes",
+			Flags: []cli.Flag{filterFlag},
+			Action: func(context *cli.Context) error {
+				filterArgs, err := filters.Parse(context.StringSlice("filter"), "label")
+				if err != nil {
+					return err
+				}
+				return cmdExec.ListVolumes(filterArgs)
+			},
+		},
+		{
+			Name:  "rm",
+			Usage: "Remove one or more volumes",
+			Action: func(context *cli.Context) error {
+				if context.NArg() < 1 {
+					return fmt.Errorf("missing volume name")
+				}
+				for _, name := range context.Args().Slice() {
+					if err := cmdExec.RemoveVolume(name); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,27 @@
+package command
+
+import (
+	"fmt"
+	"github.com/Nevermore12321/dockergsh/cmdExec"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var WaitCommand = &cli.Command{
+	Name:  "wait",
+	Usage: "Block until one or more containers stop, then print their exit codes",
+	Action: func(context *cli.Context) error {
+		// dockergsh wait [containerName or containerId]
+		if context.NArg() < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerArg := context.Args().Get(0)
+		exitCode, err := cmdExec.WaitContainer(containerArg)
+		if err != nil {
+			log.Errorf("Wait Container failed %v", err)
+			return err
+		}
+		fmt.Println(exitCode)
+		return nil
+	},
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupMountPoint = "/sys/fs/cgroup"
+
+/*
+进入新的 cgroup namespace，容器中看到的 cgroup 根就是容器自己的 cgroup
+cgroup namespace 的根是 unshare 时进程所在的 cgroup，因此不能在 clone 时创建，
+要等父进程把容器进程加入 cgroup 之后（也就是收到 InitConfig 之后）再 unshare
+*/
+func unshareCgroupNamespace() error {
+	if err := unix.Unshare(unix.CLONE_NEWCGROUP); err != nil {
+		return fmt.Errorf("unshare cgroup namespace error %v", err)
+	}
+	return nil
+}
+
+/*
+在 pivot_root 之后只读挂载 sysfs 和 cgroup 文件系统，容器只能看到自己的 cgroup，不能修改资源限制
+- cgroup v2：在 /sys/fs/cgroup 挂载 cgroup2
+- cgroup v1：在 /sys/fs/cgroup 挂载 tmpfs，再把 /proc/self/cgroup 中的每个 hierarchy 挂载到对应的子目录
+*/
+func mountCgroup(cgroupV2 bool) error {
+	flags := uintptr(syscall.MS_NOEXEC | syscall.MS_NODEV | syscall.MS_NOSUID | syscall.MS_RDONLY)
+	if err := os.MkdirAll("/sys", 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("sysfs", "/sys", "sysfs", flags, ""); err != nil {
+		return fmt.Errorf("mount sysfs error %v", err)
+	}
+
+	if cgroupV2 {
+		if err := syscall.Mount("cgroup2", cgroupMountPoint, "cgroup2", flags, ""); err != nil {
+			return fmt.Errorf("mount cgroup2 error %v", err)
+		}
+		return nil
+	}
+
+	if err := syscall.Mount("tmpfs", cgroupMountPoint, "tmpfs", flags&^syscall.MS_RDONLY, "mode=755"); err != nil {
+		return fmt.Errorf("mount cgroup tmpfs error %v", err)
+	}
+	hierarchies, err := readCgroupHierarchies()
+	if err != nil {
+		return err
+	}
+	for _, controllers := range hierarchies {
+		if err := mountCgroupHierarchy(controllers, flags); err != nil {
+			return err
+		}
+	}
+	// 子目录都挂载好以后再把 tmpfs 改为只读
+	if err := syscall.Mount("", cgroupMountPoint, "", flags|syscall.MS_REMOUNT, "mode=755"); err != nil {
+		return fmt.Errorf("remount cgroup tmpfs readonly error %v", err)
+	}
+	return nil
+}
+
+// 挂载一个 cgroup v1 hierarchy，多个控制器挂载在一起时（例如 cpu,cpuacct）为每个控制器创建软链接
+func mountCgroupHierarchy(controllers string, flags uintptr) error {
+	// hybrid 模式下的 cgroup v2 没有控制器，挂载到 unified 目录
+	if controllers == "" {
+		target := filepath.Join(cgroupMountPoint, "unified")
+		if err := os.Mkdir(target, 0755); err != nil {
+			return err
+		}
+		if err := syscall.Mount("cgroup2", target, "cgroup2", flags, ""); err != nil {
+			return fmt.Errorf("mount cgroup2 error %v", err)
+		}
+		return nil
+	}
+
+	// 命名的 hierarchy 没有控制器，例如 name=systemd
+	dir, data := controllers, controllers
+	if strings.HasPrefix(controllers, "name=") {
+		dir = strings.TrimPrefix(controllers, "name=")
+		data = "none," + controllers
+	}
+	target := filepath.Join(cgroupMountPoint, dir)
+	if err := os.Mkdir(target, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("cgroup", target, "cgroup", flags, data); err != nil {
+		return fmt.Errorf("mount cgroup %s error %v", controllers, err)
+	}
+	if subsystems := strings.Split(controllers, ","); len(subsystems) > 1 {
+		for _, subsystem := range subsystems {
+			if err := os.Symlink(dir, filepath.Join(cgroupMountPoint, subsystem)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 读取 /proc/self/cgroup 中的 hierarchy，每行格式为 hierarchy-ID:controller-list:cgroup-path
+func readCgroupHierarchies() ([]string, error) {
+	file, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var hierarchies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		hierarchies = append(hierarchies, fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	log.Infof("Cgroup hierarchies: %v", hierarchies)
+	return hierarchies, nil
+}
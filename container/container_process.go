@@ -2,6 +2,7 @@ package container
 
 import (
 	"fmt"
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
 	"github.com/Nevermore12321/dockergsh/image"
 	log "github.com/sirupsen/logrus"
 	"os"
@@ -17,10 +18,8 @@ var (
 	DefaultFsURL        string = "/var/lib/dockergsh/"
 	ContainerConfigPath string = "container"
 	ContainerLogFile    string = "container.log"
+	MonitorLogFile      string = "monitor.log"
 	NamedContainersDir  string = "named_containers"
-	RUNNING             string = "running"
-	STOP                string = "stopped"
-	EXIT                string = "exited"
 	ConfigName          string = "config.json"
 )
 
@@ -35,36 +34,114 @@ type ContainerInit struct {
 
 // ContainerInfo container 的详细信息
 type ContainerInfo struct {
-	Pid         string   `json:"pid"`          // 容器的init进程在宿主机上的 PID
-	Id          string   `json:"id"`           // 容器Id
-	Name        string   `json:"name"`         // 容器名
-	Command     string   `json:"command"`      // 容器内init运行命令
-	CreateTime  string   `json:"create_time"`  // 创建时间
-	Status      string   `json:"status"`       // 容器的状态
-	Volume      string   `json:"volume"`       // 容器的数据卷
-	PortMapping []string `json:"port_mapping"` // 端口映射
-	RootUrl     string   `json:"root_url"`     // 容器的根目录
+	SchemaVersion int                       `json:"schema_version"` // 记录的版本，读取旧版本的记录时由 store 迁移
+	Pid           string                    `json:"pid"`            // 容器的init进程在宿主机上的 PID
+	Id            string                    `json:"id"`             // 容器Id
+	Name          string                    `json:"name"`           // 容器名
+	Command       string                    `json:"command"`        // 容器内init运行命令
+	Args          []string                  `json:"args"`           // 用户命令，start 时原样传递给容器 init 进程
+	Env           []string                  `json:"env"`            // -e 指定的环境变量
+	Image         string                    `json:"image"`          // 容器的镜像
+	CreateTime    string                    `json:"create_time"`    // 创建时间
+	Status        State                     `json:"status"`         // 容器的状态
+	Volume        string                    `json:"volume"`         // 容器的数据卷
+	PortMapping   []string                  `json:"port_mapping"`   // 端口映射
+	RootUrl       string                    `json:"root_url"`       // 容器的根目录
+	Devices       []*Device                 `json:"devices"`        // --device 指定的宿主机设备
+	DeviceRules   []*subsystem.DeviceRule   `json:"device_rules"`   // cgroup 设备白名单
+	Resources     *subsystem.ResourceConfig `json:"resources"`      // cgroup 资源限制，dockergsh update 修改后也会更新这里
+	Init          bool                      `json:"init"`           // 1 号进程是否为 dockergsh init
+	ShmSize       int64                     `json:"shm_size_bytes"` // /dev/shm 的大小，单位字节
+	LegacyShmSize string                    `json:"shm_size"`       // 版本 1 的记录中 --shm-size 的原始值，由 store 迁移为 ShmSize
+	CgroupNs      string                    `json:"cgroup_ns"`      // --cgroupns 指定的 cgroup namespace 模式
+	StopSignal    string                    `json:"stop_signal"`    // stop 时发送给容器的信号
+	StartTime     string                    `json:"start_time"`     // 容器主进程的启动时间，用来校验 pid 没有被复用
+	BootId        string                    `json:"boot_id"`        // 容器启动时宿主机的 boot id，用来判断宿主机是否重启过
+	Cgroup        string                    `json:"cgroup"`         // 容器主进程加入 cgroup 后 /proc/<pid>/cgroup 的内容
+	Network       string                    `json:"network"`        // 容器连接的网络
+	IpAddress     string                    `json:"ip_address"`     // 容器在网络中分配到的 ip
+	ExitCode      int                       `json:"exit_code"`      // 容器主进程的退出码
+	FinishTime    string                    `json:"finish_time"`    // 容器主进程的退出时间，为空表示退出码还没有被记录
+	Healthcheck   *HealthConfig             `json:"healthcheck"`    // --health-cmd 指定的健康检查
+	Health        *Health                   `json:"health"`         // 健康检查的状态，没有健康检查时为空
+	Restart       string                    `json:"restart"`        // 重启策略
+	Restarts      int                       `json:"restarts"`       // 因为重启策略重启的次数
+	Labels        map[string]string         `json:"labels"`         // 容器的标签
+	AutoRemove    bool                      `json:"auto_remove"`    // --rm，容器退出后删除容器
+	Hooks         *Hooks                    `json:"hooks"`          // 创建容器时 --hook 和 hooks 目录中的 hook
+}
+
+// InitConfig 父进程通过管道传递给容器 init 进程的配置
+type InitConfig struct {
+	Args    []string  `json:"args"`     // 用户命令
+	Devices []*Device `json:"devices"`  // 除标准设备外，需要额外创建的设备
+	ShmSize int64     `json:"shm_size"` // /dev/shm 的大小，单位字节
+	Init    bool      `json:"init"`     // 是否以 init 模式运行，常驻为 1 号进程
+
+	CgroupNs bool `json:"cgroup_ns"` // 是否使用独立的 cgroup namespace
+	CgroupV2 bool `json:"cgroup_v2"` // 宿主机是否只使用 cgroup v2，决定容器中 /sys/fs/cgroup 的挂载方式
+}
+
+// ExecConfig 父进程通过管道传递给 exec 进程的配置
+type ExecConfig struct {
+	Args []string `json:"args"` // 用户命令，原样传递，不经过 shell
+	Env  []string `json:"env"`  // 容器主进程的环境变量，以及 -e 指定的环境变量
+	Cwd  string   `json:"cwd"`  // 工作目录，为空时使用 /
+	User string   `json:"user"` // user[:group]，可以是名字或者 id，为空时使用 root
+}
+
+/*
+NewContainerInit 为新容器生成 id，并准备容器的 rootfs：
+1. 解压镜像作为 lower 层，创建 upper 和 work 层
+2. 使用 overlay 挂载到 merge 目录，并挂载 volume
+
+容器的 init 进程在 start 时由 NewInitProcess 创建
+*/
+func NewContainerInit(imageName, volume string) *ContainerInit {
+	// todo 从镜像构造容器
+	id := utils.NewId()
+	idBase := utils.EncodeSha256([]byte(id))
+	// 该容器的根目录，以 id 命名
+	rootURL := DefaultFsURL + idBase
+	// 挂载时 挂载目录
+	mergeURL := DefaultFsURL + idBase + "/merge"
+	// 该容器的 镜像
+	imageURL := imageName + ".tar"
+
+	// 准备容器的 rootfs，挂载 overlay 和 volume
+	NewWorkSpace(imageURL, volume, mergeURL, rootURL)
 
+	return &ContainerInit{Id: id, IdBase: idBase, MergeUrl: mergeURL, RootUrl: rootURL, ImageUrl: image.DefaultImageDir + imageName}
+}
+
+// MountWorkSpace 容器退出或者宿主机重启后 merge 层已经卸载，start 之前重新挂载 overlay 和 volume，已经挂载的跳过
+func MountWorkSpace(imageName, volume, rootURL string) error {
+	mergeURL := rootURL + "/merge"
+	if utils.IsMounted(mergeURL) {
+		return nil
+	}
+	// upper 层保留着容器之前的修改
+	if err := image.CreateMountPoint(imageName+".tar", mergeURL, rootURL); err != nil {
+		return err
+	}
+	return CreateVolume(volume, mergeURL)
 }
 
 /*
-该函数父进程，也就是当前进程执行的内容，
+NewInitProcess 为已经准备好 rootfs 的容器创建 init 进程的命令，idBase 为容器目录名
 1. /proc/self/exe 调用，/proc/self/ 指的是当前运行进程自己的环境，exec其实就是自己调用了自己，使用这种方式对创建出来的进程进行初始化
 2. 后面的 args 是参数，其中 init 是传递给本进程的第一个参数，在本例中，其实就是会去调用 initCommand去初始化进程的一些环境和资源
 3. 下面的 clone 参数就是去 fork 出来一个新进程，并且使用了 namespace 隔离新创建的进程和外部环境。
 4. 如果用户指定了 －it 参数，就需要把当前进程的输入输出导入到标准输入输出上
 
-该函数最终返回:
-- ContainerInit 容器初始化的结构体
-- exec.Cmd 命令结构体
-- os.File 一个写管道
+容器每次 start 和重启时都会调用，返回 exec.Cmd 命令结构体和一个写管道
 */
-func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*ContainerInit, *exec.Cmd, *os.File) {
+func NewInitProcess(tty bool, idBase string, envSlice []string) (*exec.Cmd, *os.File) {
 	// 初始化管道, 父进程通过管道，将子进程运行的参数传过去
 	readPipe, writerPipe, err := utils.NewPipe()
 	if err != nil {
 		log.Errorf("New pipe err: %v", err)
-		return nil, nil, nil
+		return nil, nil
 	}
 
 	// 获取当前程序， /proc/self/exec 也就是当前执行的程序
@@ -72,23 +149,13 @@ func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*C
 	initCmd, err := os.Readlink("/proc/self/exe")
 	if err != nil {
 		log.Errorf("get init process error %v", err)
-		return nil, nil, nil
+		return nil, nil
 	}
 
 	// 通过 os/exec 来 fork 一个子进程并且 执行当前程序，传入 init 参数
 	// 也就是在子进程中执行 dockergsh init
 	cmd := exec.Command(initCmd, "init")
 
-	// todo 从镜像构造容器
-	id := utils.NewId()
-	idBase := utils.EncodeSha256([]byte(id))
-	// 该容器的根目录，以 id 命名
-	rootURL := DefaultFsURL + idBase
-	// 挂载时 挂载目录
-	mergeURL := DefaultFsURL + idBase + "/merge"
-	// 该容器的 镜像
-	imageURL := imageName + ".tar"
-
 	// 在子进程中，添加一个文件描述符. 除了 012， 那么该 readPipe 的文件描述符为 3
 	cmd.ExtraFiles = []*os.File{readPipe}
 
@@ -100,8 +167,7 @@ func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*C
 	cmd.Env = append(os.Environ(), envSlice...)
 
 	// 指定 命令的 工作目录
-	NewWorkSpace(imageURL, volume, mergeURL, rootURL)
-	cmd.Dir = mergeURL
+	cmd.Dir = DefaultFsURL + idBase + "/merge"
 	fmt.Println(cmd, readPipe, writerPipe)
 
 	// 设置 CLONE Flag，（Namespace）
@@ -119,7 +185,7 @@ func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*C
 		dirURL := fmt.Sprintf(DefaultInfoLocation, idBase)
 		if err := os.MkdirAll(dirURL, 0622); err != nil && os.IsExist(err) {
 			log.Errorf("NewParentProcess mkdir %s error %v", dirURL, err)
-			return nil, nil, nil
+			return nil, nil
 		}
 
 		// 创建日志文件，/var/run/dockergsh/contain_id/container.log
@@ -127,7 +193,7 @@ func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*C
 		stdLogFile, err := os.OpenFile(stdLogFileAbsPath, os.O_CREATE|os.O_WRONLY|os.O_SYNC|os.O_APPEND, 0755)
 		if err != nil {
 			log.Errorf("NewParentProcess create file %s error %v", stdLogFileAbsPath, err)
-			return nil, nil, nil
+			return nil, nil
 		}
 
 		// 将容器的 输出/错误 重定向到 日志文件
@@ -135,7 +201,7 @@ func NewParentProcess(tty bool, imageName, volume string, envSlice []string) (*C
 		cmd.Stderr = stdLogFile
 	}
 
-	return &ContainerInit{Id: id, IdBase: idBase, MergeUrl: mergeURL, RootUrl: rootURL, ImageUrl: image.DefaultImageDir + imageName}, cmd, writerPipe
+	return cmd, writerPipe
 }
 
 // 创建一个 overlay2 的文件系统，供容器挂载
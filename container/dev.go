@@ -0,0 +1,155 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultShmSize /dev/shm 的默认大小，与 docker 一致为 64m
+const DefaultShmSize int64 = 64 << 20
+
+/*
+在 pivot_root 之前准备容器的 /dev：
+1. 在 rootfs/dev 挂载一个空的 tmpfs
+2. 创建标准设备节点和 --device 指定的设备，如果在 user namespace 中没有 mknod 权限，就 bind mount 宿主机的设备
+必须在 pivot_root 之前做，因为之后就看不到宿主机的 /dev 了
+*/
+func setupDev(rootfs string, devices []*Device) error {
+	devPath := filepath.Join(rootfs, "dev")
+	if err := os.MkdirAll(devPath, 0755); err != nil {
+		return fmt.Errorf("mkdir %s error %v", devPath, err)
+	}
+	//  mount -t tmpfs tmpfs /dev ： tmpfs是一种基于内存的文件系统，可以使用RAM或swap分区来存储。
+	if err := syscall.Mount("tmpfs", devPath, "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755"); err != nil {
+		return fmt.Errorf("mount tmpfs on %s error %v", devPath, err)
+	}
+
+	// mknod 创建的文件权限会受 umask 影响，创建设备期间将 umask 置 0
+	oldMask := syscall.Umask(0)
+	defer syscall.Umask(oldMask)
+
+	bind := inUserNamespace()
+	for _, device := range append(DefaultDevices, devices...) {
+		if err := createDeviceNode(rootfs, device, bind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+在 pivot_root 之后完善 /dev：
+1. 挂载独立实例的 devpts，并将 /dev/ptmx 指向 /dev/pts/ptmx
+2. 挂载 /dev/shm
+3. 创建 /dev/fd、/dev/stdin 等软链接
+*/
+func setupDevPostPivot(shmSize int64) error {
+	if err := os.MkdirAll("/dev/pts", 0755); err != nil {
+		return err
+	}
+	// newinstance 表示使用独立的 devpts 实例，容器看不到宿主机的终端
+	if err := syscall.Mount("devpts", "/dev/pts", "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC, "newinstance,ptmxmode=0666,mode=0620,gid=5"); err != nil {
+		return fmt.Errorf("mount devpts error %v", err)
+	}
+	if err := os.Remove("/dev/ptmx"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink("pts/ptmx", "/dev/ptmx"); err != nil {
+		return fmt.Errorf("symlink /dev/ptmx error %v", err)
+	}
+
+	if shmSize <= 0 {
+		shmSize = DefaultShmSize
+	}
+	if err := os.MkdirAll("/dev/shm", 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("shm", "/dev/shm", "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC|syscall.MS_NODEV, "mode=1777,size="+strconv.FormatInt(shmSize, 10)); err != nil {
+		return fmt.Errorf("mount /dev/shm error %v", err)
+	}
+
+	// 标准的 /dev 软链接
+	links := [][2]string{
+		{"/proc/self/fd", "/dev/fd"},
+		{"/proc/self/fd/0", "/dev/stdin"},
+		{"/proc/self/fd/1", "/dev/stdout"},
+		{"/proc/self/fd/2", "/dev/stderr"},
+	}
+	for _, link := range links {
+		if err := os.Symlink(link[0], link[1]); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("symlink %s -> %s error %v", link[1], link[0], err)
+		}
+	}
+	return nil
+}
+
+// 创建单个设备节点，bind 为 true 时直接 bind mount 宿主机设备
+func createDeviceNode(rootfs string, device *Device, bind bool) error {
+	dest := filepath.Join(rootfs, device.PathInContainer)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if bind {
+		return bindDevice(device, dest)
+	}
+	if err := mknodDevice(dest, device); err != nil {
+		// 没有 mknod 权限时（例如 rootless），退化为 bind mount
+		if os.IsPermission(err) {
+			log.Warnf("Mknod %s not permitted, bind mount from host", dest)
+			return bindDevice(device, dest)
+		}
+		return err
+	}
+	return nil
+}
+
+// 通过 mknod 系统调用创建设备节点
+func mknodDevice(dest string, device *Device) error {
+	fileMode := uint32(device.FileMode)
+	switch device.Type {
+	case "c":
+		fileMode |= unix.S_IFCHR
+	case "b":
+		fileMode |= unix.S_IFBLK
+	default:
+		return fmt.Errorf("%s is not a valid device type for device %s", device.Type, device.PathInContainer)
+	}
+	dev := unix.Mkdev(uint32(device.Major), uint32(device.Minor))
+	if err := unix.Mknod(dest, fileMode, int(dev)); err != nil {
+		return &os.PathError{Op: "mknod", Path: dest, Err: err}
+	}
+	return os.Chown(dest, int(device.Uid), int(device.Gid))
+}
+
+// 创建一个空文件作为挂载点，然后将宿主机的设备 bind mount 过来
+func bindDevice(device *Device, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	if f != nil {
+		f.Close()
+	}
+	if err := syscall.Mount(device.PathOnHost, dest, "bind", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind mount device %s error %v", device.PathOnHost, err)
+	}
+	return nil
+}
+
+// 判断当前进程是否在 user namespace 中
+// 初始 user namespace 的 /proc/self/uid_map 内容为 "0 0 4294967295"
+func inUserNamespace() bool {
+	content, err := os.ReadFile("/proc/self/uid_map")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(content))
+	return !(len(fields) == 3 && fields[0] == "0" && fields[1] == "0" && fields[2] == "4294967295")
+}
@@ -0,0 +1,177 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+	"golang.org/x/sys/unix"
+)
+
+// Device 描述容器 /dev 下的一个设备节点
+type Device struct {
+	Type            string      `json:"type"`              // 设备类型，c 表示字符设备，b 表示块设备
+	PathOnHost      string      `json:"path_on_host"`      // 宿主机上的设备路径
+	PathInContainer string      `json:"path_in_container"` // 容器内的设备路径
+	Major           int64       `json:"major"`             // 主设备号
+	Minor           int64       `json:"minor"`             // 次设备号
+	Permissions     string      `json:"permissions"`       // 访问权限，r 读 w 写 m mknod
+	FileMode        os.FileMode `json:"file_mode"`         // 设备文件权限
+	Uid             uint32      `json:"uid"`               // 设备文件属主
+	Gid             uint32      `json:"gid"`               // 设备文件属组
+}
+
+// DefaultDevices 每个容器都会创建的标准设备节点，与 docker 保持一致
+var DefaultDevices = []*Device{
+	{Type: "c", PathOnHost: "/dev/null", PathInContainer: "/dev/null", Major: 1, Minor: 3, Permissions: "rwm", FileMode: 0666},
+	{Type: "c", PathOnHost: "/dev/zero", PathInContainer: "/dev/zero", Major: 1, Minor: 5, Permissions: "rwm", FileMode: 0666},
+	{Type: "c", PathOnHost: "/dev/full", PathInContainer: "/dev/full", Major: 1, Minor: 7, Permissions: "rwm", FileMode: 0666},
+	{Type: "c", PathOnHost: "/dev/random", PathInContainer: "/dev/random", Major: 1, Minor: 8, Permissions: "rwm", FileMode: 0666},
+	{Type: "c", PathOnHost: "/dev/urandom", PathInContainer: "/dev/urandom", Major: 1, Minor: 9, Permissions: "rwm", FileMode: 0666},
+	{Type: "c", PathOnHost: "/dev/tty", PathInContainer: "/dev/tty", Major: 5, Minor: 0, Permissions: "rwm", FileMode: 0666},
+}
+
+/*
+ParseDevice 解析 --device 参数，格式与 docker 一致：
+- /dev/fuse
+- /dev/fuse:/dev/fuse
+- /dev/fuse:rwm
+- /dev/fuse:/dev/fuse:rwm
+*/
+func ParseDevice(spec string) (*Device, error) {
+	var src, dst string
+	permissions := "rwm"
+
+	arr := strings.Split(spec, ":")
+	switch len(arr) {
+	case 3:
+		if !validDevicePermissions(arr[2]) {
+			return nil, fmt.Errorf("invalid device permissions %q in %s", arr[2], spec)
+		}
+		permissions = arr[2]
+		fallthrough
+	case 2:
+		if len(arr) == 2 && validDevicePermissions(arr[1]) {
+			permissions = arr[1]
+		} else {
+			dst = arr[1]
+		}
+		fallthrough
+	case 1:
+		src = arr[0]
+	default:
+		return nil, fmt.Errorf("invalid device specification: %s", spec)
+	}
+
+	if dst == "" {
+		dst = src
+	}
+	if !filepath.IsAbs(src) || !filepath.IsAbs(dst) {
+		return nil, fmt.Errorf("device path must be absolute: %s", spec)
+	}
+
+	device, err := DeviceFromPath(src, permissions)
+	if err != nil {
+		return nil, err
+	}
+	device.PathInContainer = dst
+	return device, nil
+}
+
+// DeviceFromPath 通过 stat 宿主机上的设备文件，获取设备类型、设备号和权限
+func DeviceFromPath(path, permissions string) (*Device, error) {
+	var stat unix.Stat_t
+	if err := unix.Lstat(path, &stat); err != nil {
+		return nil, fmt.Errorf("stat device %s error %v", path, err)
+	}
+
+	var devType string
+	switch stat.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		devType = "c"
+	case unix.S_IFBLK:
+		devType = "b"
+	default:
+		return nil, fmt.Errorf("%s is not a device node", path)
+	}
+
+	return &Device{
+		Type:            devType,
+		PathOnHost:      path,
+		PathInContainer: path,
+		Major:           int64(unix.Major(stat.Rdev)),
+		Minor:           int64(unix.Minor(stat.Rdev)),
+		Permissions:     permissions,
+		FileMode:        os.FileMode(stat.Mode &^ unix.S_IFMT),
+		Uid:             stat.Uid,
+		Gid:             stat.Gid,
+	}, nil
+}
+
+// CgroupRule 将设备转换成 cgroup 的设备白名单规则
+func (device *Device) CgroupRule() *subsystem.DeviceRule {
+	return &subsystem.DeviceRule{
+		Type:        device.Type,
+		Major:       device.Major,
+		Minor:       device.Minor,
+		Permissions: device.Permissions,
+		Allow:       true,
+	}
+}
+
+// DeviceRules 容器的 cgroup 设备白名单：默认规则加上 --device 指定的设备
+func DeviceRules(devices []*Device) []*subsystem.DeviceRule {
+	rules := append([]*subsystem.DeviceRule{}, subsystem.DefaultDeviceRules...)
+	for _, device := range devices {
+		rules = append(rules, device.CgroupRule())
+	}
+	return rules
+}
+
+// 权限字符串只能由 r、w、m 组成，且不能重复
+func validDevicePermissions(permissions string) bool {
+	if permissions == "" || len(permissions) > 3 {
+		return false
+	}
+	for _, c := range permissions {
+		if !strings.ContainsRune("rwm", c) || strings.Count(permissions, string(c)) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ParseThrottleDevice 解析 --device-read-bps 等参数，格式为 <块设备路径>:<速率>，例如：
+- /dev/sda:1mb，bps 的速率可以带单位
+- /dev/sda:1000，iops 的速率只能是整数
+*/
+func ParseThrottleDevice(spec string, isBps bool) (*subsystem.ThrottleDevice, error) {
+	arr := strings.Split(spec, ":")
+	if len(arr) != 2 || !filepath.IsAbs(arr[0]) {
+		return nil, fmt.Errorf("invalid throttle device specification: %s", spec)
+	}
+
+	device, err := DeviceFromPath(arr[0], "")
+	if err != nil {
+		return nil, err
+	}
+	if device.Type != "b" {
+		return nil, fmt.Errorf("%s is not a block device", arr[0])
+	}
+
+	var rate int64
+	if isBps {
+		rate, err = units.RAMInBytes(arr[1])
+	} else {
+		rate, err = strconv.ParseInt(arr[1], 10, 64)
+	}
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf("invalid rate %q in %s", arr[1], spec)
+	}
+	return &subsystem.ThrottleDevice{Major: device.Major, Minor: device.Minor, Rate: uint64(rate)}, nil
+}
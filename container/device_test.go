@@ -0,0 +1,50 @@
+package container
+
+import "testing"
+
+// 使用 /dev/null（1:3）作为宿主机设备
+func TestParseDevice(t *testing.T) {
+	tests := []struct {
+		spec            string
+		pathInContainer string
+		permissions     string
+		wantErr         bool
+	}{
+		{spec: "/dev/null", pathInContainer: "/dev/null", permissions: "rwm"},
+		{spec: "/dev/null:/dev/mynull", pathInContainer: "/dev/mynull", permissions: "rwm"},
+		{spec: "/dev/null:r", pathInContainer: "/dev/null", permissions: "r"},
+		{spec: "/dev/null:/dev/mynull:rw", pathInContainer: "/dev/mynull", permissions: "rw"},
+		{spec: "/dev/null:/dev/mynull:m", pathInContainer: "/dev/mynull", permissions: "m"},
+		// 权限不合法
+		{spec: "/dev/null:/dev/mynull:rwx", wantErr: true},
+		{spec: "/dev/null:/dev/mynull:rr", wantErr: true},
+		{spec: "/dev/null:/dev/mynull:", wantErr: true},
+		// 两段时第二段不是权限就当作容器内的路径
+		{spec: "/dev/null:x", wantErr: true},
+		{spec: "dev/null", wantErr: true},
+		{spec: "/dev/null:mynull:rw", wantErr: true},
+		{spec: "/dev/null:/dev/a:rw:extra", wantErr: true},
+		{spec: "/dev/does-not-exist", wantErr: true},
+		{spec: "/etc/hostname", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		device, err := ParseDevice(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDevice(%q) = %+v, want error", tt.spec, device)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDevice(%q) error %v", tt.spec, err)
+			continue
+		}
+		if device.Type != "c" || device.Major != 1 || device.Minor != 3 || device.PathOnHost != "/dev/null" {
+			t.Errorf("ParseDevice(%q) = %+v, want /dev/null 1:3", tt.spec, device)
+		}
+		if device.PathInContainer != tt.pathInContainer || device.Permissions != tt.permissions {
+			t.Errorf("ParseDevice(%q) = %s %s, want %s %s", tt.spec, device.PathInContainer, device.Permissions, tt.pathInContainer, tt.permissions)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ExecPipeFd exec 进程从 fd 3 读取父进程传入的 ExecConfig，与 nsenter 中的 EXEC_PIPE_FD 一致
+	ExecPipeFd = 3
+	// ExecsDir 容器目录下保存 exec 记录和 -d 模式日志的目录
+	ExecsDir = "execs"
+)
+
+// ExecInfo exec 的记录，保存在容器目录下的 execs/<id>.json
+type ExecInfo struct {
+	Id          string   `json:"id"`           // exec id
+	ContainerId string   `json:"container_id"` // 所属的容器
+	Command     []string `json:"command"`      // 用户命令
+	User        string   `json:"user"`         // -u 指定的用户
+	WorkDir     string   `json:"work_dir"`     // -w 指定的工作目录
+	Detach      bool     `json:"detach"`       // 是否为 -d 模式，输出写到 execs/<id>.log
+	Pid         int      `json:"pid"`          // 用户命令在宿主机上的 pid
+	Running     bool     `json:"running"`      // 用户命令是否还在运行
+	CreateTime  string   `json:"create_time"`  // 创建时间
+	StartTime   string   `json:"start_time"`   // 用户命令的启动时间，用来校验 pid 没有被复用
+	BootId      string   `json:"boot_id"`      // 启动时宿主机的 boot id
+	ExitCode    int      `json:"exit_code"`    // 用户命令的退出码
+	FinishTime  string   `json:"finish_time"`  // 用户命令的退出时间
+}
+
+// ExecInfoPath exec 记录的路径，rootURL 为容器的根目录
+func ExecInfoPath(rootURL, execId string) string {
+	return filepath.Join(rootURL, ExecsDir, execId+".json")
+}
+
+// ExecLogPath -d 模式下 exec 输出的日志路径
+func ExecLogPath(rootURL, execId string) string {
+	return filepath.Join(rootURL, ExecsDir, execId+".log")
+}
+
+// OpenExecProcess 打开 exec 的用户命令进程，与容器主进程一样校验 boot id 和进程启动时间
+func OpenExecProcess(execInfo *ExecInfo) (*Process, error) {
+	return OpenProcess(&ContainerInfo{
+		Pid:       strconv.Itoa(execInfo.Pid),
+		StartTime: execInfo.StartTime,
+		BootId:    execInfo.BootId,
+	})
+}
+
+/*
+RunExecProcess 在 exec 进程中执行用户命令
+执行到这里时，nsenter 已经进入了容器的所有 namespace 和 cgroup，并 chroot 到了容器的根目录，
+只需要按照 ExecConfig 切换用户和工作目录，然后替换为用户命令，用户命令的退出码由 nsenter 返回给父进程
+*/
+func RunExecProcess() error {
+	pipe := os.NewFile(uintptr(ExecPipeFd), "pipe")
+	msg, err := io.ReadAll(pipe)
+	pipe.Close()
+	if err != nil {
+		return fmt.Errorf("read exec config error %v", err)
+	}
+	var execConfig ExecConfig
+	if err := json.Unmarshal(msg, &execConfig); err != nil {
+		return fmt.Errorf("unmarshal exec config error %v", err)
+	}
+	if len(execConfig.Args) == 0 {
+		return fmt.Errorf("missing exec command")
+	}
+
+	execUser, err := lookupUser(execConfig.User)
+	if err != nil {
+		return err
+	}
+	env := execConfig.Env
+	if !hasEnv(env, "HOME") {
+		env = append(env, "HOME="+execUser.Home)
+	}
+
+	// 使用容器的环境变量查找命令，而不是宿主机的 PATH
+	os.Clearenv()
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			_ = os.Setenv(key, value)
+		}
+	}
+	cmdPath, err := exec.LookPath(execConfig.Args[0])
+	if err != nil {
+		return err
+	}
+
+	// 先切换组再切换用户，切换用户后就没有权限修改组了
+	if err := syscall.Setgroups(execUser.Groups); err != nil {
+		return fmt.Errorf("setgroups error %v", err)
+	}
+	if err := syscall.Setgid(execUser.Gid); err != nil {
+		return fmt.Errorf("setgid %d error %v", execUser.Gid, err)
+	}
+	if err := syscall.Setuid(execUser.Uid); err != nil {
+		return fmt.Errorf("setuid %d error %v", execUser.Uid, err)
+	}
+
+	cwd := execConfig.Cwd
+	if cwd == "" {
+		cwd = "/"
+	}
+	if err := syscall.Chdir(cwd); err != nil {
+		return fmt.Errorf("chdir %s error %v", cwd, err)
+	}
+
+	return syscall.Exec(cmdPath, execConfig.Args, env)
+}
+
+func hasEnv(env []string, key string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return true
+		}
+	}
+	return false
+}
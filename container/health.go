@@ -0,0 +1,43 @@
+package container
+
+import "time"
+
+// 健康检查的状态
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// 重启策略
+const (
+	RestartNo          = "no"
+	RestartOnUnhealthy = "on-unhealthy"
+)
+
+// 保留最近几次健康检查的结果
+const HealthLogSize = 5
+
+// HealthConfig --health-* 指定的健康检查配置
+type HealthConfig struct {
+	Test        []string      `json:"test"`         // 在容器中执行的检查命令，退出码为 0 表示健康
+	Interval    time.Duration `json:"interval"`     // 两次检查之间的间隔
+	Timeout     time.Duration `json:"timeout"`      // 单次检查的超时时间，超时视为失败
+	StartPeriod time.Duration `json:"start_period"` // 容器启动后的这段时间内，检查失败不计入连续失败次数
+	Retries     int           `json:"retries"`      // 连续失败多少次后认为容器不健康
+}
+
+// Health 容器的健康状态
+type Health struct {
+	Status        string          `json:"status"`         // starting、healthy 或 unhealthy
+	FailingStreak int             `json:"failing_streak"` // 连续失败的次数
+	Log           []*HealthResult `json:"log"`            // 最近 HealthLogSize 次检查的结果
+}
+
+// HealthResult 一次健康检查的结果
+type HealthResult struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
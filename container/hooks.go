@@ -0,0 +1,167 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hook 执行的阶段，与 OCI 运行时规范一致
+const (
+	HookPrestart      = "prestart"      // 容器命令执行之前，已废弃，与 createRuntime 一起执行
+	HookCreateRuntime = "createRuntime" // 容器的 namespace、cgroup 和网络准备好之后，容器命令执行之前
+	HookPoststart     = "poststart"     // 容器命令开始执行之后
+	HookPoststop      = "poststop"      // 容器退出并释放资源之后
+)
+
+// OciVersion 传给 hook 的容器状态遵循的 OCI 运行时规范版本
+const OciVersion = "1.0.2"
+
+// HooksDirName hooks 目录在 /var/lib/dockergsh 下的名字
+const HooksDirName = "hooks"
+
+// HooksDir hooks 目录，目录中的每个 json 文件与 OCI config.json 中的 hooks 格式相同，创建容器时加入容器的 hook
+var HooksDir = filepath.Join(DefaultFsURL, HooksDirName)
+
+// Hook 一个 hook，与 OCI 运行时规范一致
+type Hook struct {
+	Path    string   `json:"path"`              // hook 可执行文件的绝对路径
+	Args    []string `json:"args,omitempty"`    // hook 的参数，包括 argv[0]，为空时 argv[0] 为 path
+	Env     []string `json:"env,omitempty"`     // hook 的环境变量，不继承 dockergsh 的环境变量
+	Timeout *int     `json:"timeout,omitempty"` // 超时时间，单位秒
+}
+
+// Hooks 容器在各个阶段执行的 hook
+type Hooks struct {
+	Prestart      []Hook `json:"prestart,omitempty"`
+	CreateRuntime []Hook `json:"createRuntime,omitempty"`
+	Poststart     []Hook `json:"poststart,omitempty"`
+	Poststop      []Hook `json:"poststop,omitempty"`
+}
+
+// OciState 执行 hook 时通过 stdin 传给 hook 的容器状态，rootfs 是 OCI 规范之外的字段
+type OciState struct {
+	OciVersion  string            `json:"ociVersion"`
+	Id          string            `json:"id"`
+	Status      string            `json:"status"` // created、running 或 stopped
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Rootfs      string            `json:"rootfs"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// 返回阶段对应的 hook 列表，阶段不存在时返回 nil
+func (h *Hooks) stage(stage string) *[]Hook {
+	switch stage {
+	case HookPrestart:
+		return &h.Prestart
+	case HookCreateRuntime:
+		return &h.CreateRuntime
+	case HookPoststart:
+		return &h.Poststart
+	case HookPoststop:
+		return &h.Poststop
+	}
+	return nil
+}
+
+// Stage 返回某个阶段的所有 hook，h 为 nil 时返回 nil
+func (h *Hooks) Stage(stage string) []Hook {
+	if h == nil {
+		return nil
+	}
+	if hooks := h.stage(stage); hooks != nil {
+		return *hooks
+	}
+	return nil
+}
+
+// Add 把 hook 加到某个阶段的末尾
+func (h *Hooks) Add(stage string, hook Hook) error {
+	hooks := h.stage(stage)
+	if hooks == nil {
+		return fmt.Errorf("invalid hook stage %q, it should be %s, %s, %s or %s", stage, HookPrestart, HookCreateRuntime, HookPoststart, HookPoststop)
+	}
+	if err := hook.validate(); err != nil {
+		return err
+	}
+	*hooks = append(*hooks, hook)
+	return nil
+}
+
+// Merge 把 other 中的 hook 依次加到 h 中
+func (h *Hooks) Merge(other *Hooks) {
+	for _, stage := range []string{HookPrestart, HookCreateRuntime, HookPoststart, HookPoststop} {
+		hooks := h.stage(stage)
+		*hooks = append(*hooks, other.Stage(stage)...)
+	}
+}
+
+// Empty 是否没有任何 hook
+func (h *Hooks) Empty() bool {
+	return h == nil || len(h.Prestart)+len(h.CreateRuntime)+len(h.Poststart)+len(h.Poststop) == 0
+}
+
+func (hook *Hook) validate() error {
+	if !filepath.IsAbs(hook.Path) {
+		return fmt.Errorf("hook path %q must be an absolute path", hook.Path)
+	}
+	if hook.Timeout != nil && *hook.Timeout <= 0 {
+		return fmt.Errorf("hook %s timeout must be greater than zero", hook.Path)
+	}
+	return nil
+}
+
+/*
+ParseHook 解析 --hook 参数，格式为 stage=path [args...]，返回阶段和 hook
+例如 --hook "poststart=/usr/local/bin/audit --tag web"，args 按空格拆分，argv[0] 为 path
+*/
+func ParseHook(spec string) (string, *Hook, error) {
+	stage, command, ok := strings.Cut(spec, "=")
+	fields := strings.Fields(command)
+	if !ok || stage == "" || len(fields) == 0 {
+		return "", nil, fmt.Errorf("invalid hook %q, it should be stage=path [args...]", spec)
+	}
+	hook := &Hook{Path: fields[0], Args: fields}
+	return stage, hook, nil
+}
+
+// LoadHooksDir 按照文件名的顺序（os.ReadDir 已经排序）读取 hooks 目录中的所有 json 文件，目录不存在时返回空的 Hooks
+func LoadHooksDir(dir string) (*Hooks, error) {
+	hooks := &Hooks{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hooks, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// 阶段名写错的 hook 不会被执行，直接报错
+		fileHooks := &Hooks{}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(fileHooks); err != nil {
+			return nil, fmt.Errorf("parse hooks file %s error %v", entry.Name(), err)
+		}
+		// 逐个校验，错误信息中带上文件名
+		for _, stage := range []string{HookPrestart, HookCreateRuntime, HookPoststart, HookPoststop} {
+			for _, hook := range fileHooks.Stage(stage) {
+				if err := hooks.Add(stage, hook); err != nil {
+					return nil, fmt.Errorf("hooks file %s: %v", entry.Name(), err)
+				}
+			}
+		}
+	}
+	return hooks, nil
+}
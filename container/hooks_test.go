@@ -0,0 +1,132 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHook(t *testing.T) {
+	tests := []struct {
+		spec      string
+		wantStage string
+		wantHook  *Hook
+		wantErr   bool
+	}{
+		{
+			spec:      "poststart=/usr/local/bin/audit",
+			wantStage: HookPoststart,
+			wantHook:  &Hook{Path: "/usr/local/bin/audit", Args: []string{"/usr/local/bin/audit"}},
+		},
+		{
+			spec:      "prestart=/bin/hook --tag web  --verbose",
+			wantStage: HookPrestart,
+			wantHook:  &Hook{Path: "/bin/hook", Args: []string{"/bin/hook", "--tag", "web", "--verbose"}},
+		},
+		// = 之后的内容都属于命令
+		{
+			spec:      "poststop=/bin/hook --env=a",
+			wantStage: HookPoststop,
+			wantHook:  &Hook{Path: "/bin/hook", Args: []string{"/bin/hook", "--env=a"}},
+		},
+		{spec: "/bin/hook", wantErr: true},
+		{spec: "=/bin/hook", wantErr: true},
+		{spec: "poststart=", wantErr: true},
+		{spec: "poststart=   ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		stage, hook, err := ParseHook(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseHook(%q) error = nil, want error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHook(%q) error %v", tt.spec, err)
+			continue
+		}
+		if stage != tt.wantStage || !reflect.DeepEqual(hook, tt.wantHook) {
+			t.Errorf("ParseHook(%q) = %s, %+v, want %s, %+v", tt.spec, stage, hook, tt.wantStage, tt.wantHook)
+		}
+	}
+}
+
+func writeHooksFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 按照文件名的顺序加载，非 json 文件和子目录被忽略
+func TestLoadHooksDir(t *testing.T) {
+	dir := t.TempDir()
+	writeHooksFile(t, dir, "20-second.json", `{"poststart": [{"path": "/bin/second"}], "poststop": [{"path": "/bin/cleanup", "timeout": 5}]}`)
+	writeHooksFile(t, dir, "10-first.json", `{"poststart": [{"path": "/bin/first", "args": ["first", "-v"], "env": ["A=1"]}]}`)
+	writeHooksFile(t, dir, "README", `not a hooks file`)
+	if err := os.Mkdir(filepath.Join(dir, "disabled.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hooks, err := LoadHooksDir(dir)
+	if err != nil {
+		t.Fatalf("LoadHooksDir error %v", err)
+	}
+	var paths []string
+	for _, hook := range hooks.Stage(HookPoststart) {
+		paths = append(paths, hook.Path)
+	}
+	if want := []string{"/bin/first", "/bin/second"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("poststart hooks = %v, want %v", paths, want)
+	}
+	if first := hooks.Poststart[0]; !reflect.DeepEqual(first.Args, []string{"first", "-v"}) || !reflect.DeepEqual(first.Env, []string{"A=1"}) {
+		t.Errorf("first hook = %+v", first)
+	}
+	if len(hooks.Poststop) != 1 || hooks.Poststop[0].Timeout == nil || *hooks.Poststop[0].Timeout != 5 {
+		t.Errorf("poststop hooks = %+v", hooks.Poststop)
+	}
+
+	// 目录不存在时没有 hook
+	hooks, err = LoadHooksDir(filepath.Join(dir, "missing"))
+	if err != nil || !hooks.Empty() {
+		t.Errorf("LoadHooksDir(missing) = %+v, %v, want empty hooks", hooks, err)
+	}
+}
+
+func TestLoadHooksDirInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"stage", `{"poststart": [{"path": "/bin/ok"}], "created": [{"path": "/bin/hook"}]}`, `unknown field "created"`},
+		{"relative path", `{"prestart": [{"path": "bin/hook"}]}`, `must be an absolute path`},
+		{"timeout", `{"poststop": [{"path": "/bin/hook", "timeout": 0}]}`, `timeout must be greater than zero`},
+		{"json", `{"poststart": `, `parse hooks file`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeHooksFile(t, dir, "hooks.json", tt.content)
+			_, err := LoadHooksDir(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "hooks.json") {
+				t.Errorf("LoadHooksDir error = %v, want %q with the file name", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHooksAddInvalidStage(t *testing.T) {
+	hooks := &Hooks{}
+	if err := hooks.Add("created", Hook{Path: "/bin/hook"}); err == nil || !strings.Contains(err.Error(), "invalid hook stage") {
+		t.Errorf("Add error = %v, want invalid hook stage", err)
+	}
+	if !hooks.Empty() {
+		t.Errorf("hooks = %+v, want empty", hooks)
+	}
+}
@@ -1,12 +1,12 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
@@ -21,14 +21,22 @@ var old_root = ".pivot_root"
 func RunContainerInitProcess() error {
 	// 容器的初始化 init 进程
 
-	// 设置挂载点, mount proc 文件系统
-	setUpMount()
-
-	//  读取传入的命令
-	cmdArray := readUserCommand()
-	if cmdArray == nil || len(cmdArray) == 0 {
+	//  读取父进程传入的配置，包括用户命令
+	initConfig := readInitConfig()
+	if initConfig == nil || len(initConfig.Args) == 0 {
 		return fmt.Errorf("Run container get user command")
 	}
+	cmdArray := initConfig.Args
+
+	// 此时父进程已经把容器进程加入了 cgroup
+	if initConfig.CgroupNs {
+		if err := unshareCgroupNamespace(); err != nil {
+			return err
+		}
+	}
+
+	// 设置挂载点, mount proc 文件系统，准备 /dev
+	setUpMount(initConfig)
 
 	// 获取子进程执行的 dockergsh 程序的绝对路径
 	// 这个函数帮我们在当前系统的PATH里面去寻找命令的绝对路径，然后运行起来。
@@ -40,6 +48,11 @@ func RunContainerInitProcess() error {
 	}
 	log.Infof("Find path %s", cmdPath)
 
+	// --init 模式下，init 进程常驻为 1 号进程，负责回收僵尸进程和转发信号
+	if initConfig.Init {
+		return runAsInit(cmdPath, cmdArray)
+	}
+
 	// 使用 syscall.Exec 执行命令, 执行 docker run 最后跟的命令
 	// 最终运行用户进程的地方
 	// 这里最终运行的是通过 管道进来的 docker run 的用户命令而不是 init
@@ -53,15 +66,15 @@ func RunContainerInitProcess() error {
 }
 
 /*
-子进程，也就是 container init 进程，通过 pipe 管道读取命令选项
+子进程，也就是 container init 进程，通过 pipe 管道读取 json 格式的 InitConfig
 在通过 namespace 隔离后，文件描述符也被隔离，因此 在 container 子进程中，
 1 是标准输出（stdout）
 2 是标准错误输出（stderr）
 0 是标准输入（stdin）
 那么 3 就是在传入子进程的 文件描述符
 */
-func readUserCommand() []string {
-	log.Infof("Read parent pipe cmd.")
+func readInitConfig() *InitConfig {
+	log.Infof("Read parent pipe init config.")
 	// 打开 管道
 	pipe := os.NewFile(uintptr(3), "pipe")
 	// 从管道中读取 命令选项
@@ -70,16 +83,21 @@ func readUserCommand() []string {
 		log.Errorf("Init read pipe error %v", err)
 		return nil
 	}
-	msgStr := string(msg)
-	log.Infof("receive %s", msgStr)
-	return strings.Split(msgStr, " ")
+	log.Infof("receive %s", string(msg))
+
+	var initConfig InitConfig
+	if err := json.Unmarshal(msg, &initConfig); err != nil {
+		log.Errorf("Init unmarshal config error %v", err)
+		return nil
+	}
+	return &initConfig
 }
 
 /*
 *
 Init 挂载点
 */
-func setUpMount() {
+func setUpMount(initConfig *InitConfig) {
 	// 获取当前路径
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -91,12 +109,16 @@ func setUpMount() {
 	// 原因 pivot root 不允许 parent mount point 和 new mount point 是 shared。
 	syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, "")
 
+	// 在 pivot_root 之前准备 /dev，此时还能访问宿主机的设备
+	if err := setupDev(pwd, initConfig.Devices); err != nil {
+		log.Errorf("Setup /dev error %v", err)
+	}
+
 	if err := pivotRoot(pwd); err != nil {
 		log.Errorf("Error when call pivotRoot %v", err)
 	}
 
 	//  mount -t proc proc /proc
-	//  mount -t tmpfs tmpfs /dev ： tmpfs是一种基于内存的文件系统，可以使用RAM或swap分区来存储。
 	// syscall.Mount(source string, target string, fstype string, flags uintptr, data string)
 	// 这里的 MountFlag 的意思如下:
 	// 1. MS_NOEXEC - 在本文件系统中不允许运行其他程序。
@@ -104,7 +126,18 @@ func setUpMount() {
 	// 3. MS_NODEV - 这个参数是自从 Linux2.4 以来，所有 mount 的系统都会默认设定的参数。
 	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NODEV | syscall.MS_NOSUID
 	syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755")
+
+	// 挂载 devpts、shm，创建 /dev 下的软链接
+	if err := setupDevPostPivot(initConfig.ShmSize); err != nil {
+		log.Errorf("Setup /dev after pivot_root error %v", err)
+	}
+
+	// 使用独立的 cgroup namespace 时，容器中只读挂载自己的 cgroup
+	if initConfig.CgroupNs {
+		if err := mountCgroup(initConfig.CgroupV2); err != nil {
+			log.Errorf("Mount cgroup error %v", err)
+		}
+	}
 
 }
 
@@ -0,0 +1,181 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Nevermore12321/dockergsh/pkg/pidfd"
+	log "github.com/sirupsen/logrus"
+)
+
+const bootIdPath = "/proc/sys/kernel/random/boot_id"
+
+// StaleProcessError 记录的 pid 已经不是容器的主进程：进程已经退出、宿主机重启过或者 pid 被其他进程复用
+type StaleProcessError struct {
+	Pid    int
+	Reason string
+}
+
+func (e *StaleProcessError) Error() string {
+	return fmt.Sprintf("container process %d is stale: %s", e.Pid, e.Reason)
+}
+
+// Process 经过身份校验的容器主进程
+// 内核支持 pidfd 时，通过 pidfd 操作进程，否则退化为使用 pid
+type Process struct {
+	Pid       int
+	startTime string
+	fd        int
+}
+
+/*
+OpenProcess 打开容器的主进程，并校验它确实是启动容器时记录的进程：
+1. 宿主机的 boot id 与记录的一致，否则说明宿主机重启过
+2. /proc/<pid>/stat 中的进程启动时间与记录的一致，否则说明 pid 被复用
+3. 进程所在的 cgroup 与容器启动时加入的 cgroup 一致
+先打开 pidfd 再校验，校验通过后 pidfd 一定指向被校验的进程，后续操作不会再受 pid 复用的影响
+*/
+func OpenProcess(info *ContainerInfo) (*Process, error) {
+	pid, err := strconv.Atoi(info.Pid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container pid %q", info.Pid)
+	}
+
+	fd, err := pidfd.Open(pid)
+	if err != nil {
+		if err == syscall.ESRCH {
+			return nil, &StaleProcessError{Pid: pid, Reason: "process has exited"}
+		}
+		if !pidfd.IsNotSupported(err) {
+			return nil, fmt.Errorf("pidfd open %d error %v", pid, err)
+		}
+		log.Debugf("pidfd is not supported, fall back to pid")
+		fd = -1
+	}
+
+	process := &Process{Pid: pid, startTime: info.StartTime, fd: fd}
+	if err := verifyProcess(pid, info); err != nil {
+		process.Close()
+		return nil, err
+	}
+	return process, nil
+}
+
+func verifyProcess(pid int, info *ContainerInfo) error {
+	if info.BootId != "" {
+		bootId, err := BootId()
+		if err != nil {
+			return err
+		}
+		if bootId != info.BootId {
+			return &StaleProcessError{Pid: pid, Reason: "host has rebooted since the container started"}
+		}
+	}
+
+	startTime, err := ProcessStartTime(pid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &StaleProcessError{Pid: pid, Reason: "process has exited"}
+		}
+		return err
+	}
+	if info.StartTime != "" && startTime != info.StartTime {
+		return &StaleProcessError{Pid: pid, Reason: "pid has been reused by another process"}
+	}
+
+	// 进程所在的 cgroup 要与容器启动时加入的 cgroup 一致
+	if info.Cgroup != "" {
+		cgroup, err := ProcessCgroup(pid)
+		if err != nil {
+			return err
+		}
+		if cgroup != info.Cgroup {
+			return &StaleProcessError{Pid: pid, Reason: "process is not in the container cgroup"}
+		}
+	}
+	return nil
+}
+
+// Fd 返回进程的 pidfd，内核不支持时返回 -1
+func (p *Process) Fd() int {
+	return p.fd
+}
+
+// Signal 向进程发送信号
+func (p *Process) Signal(sig syscall.Signal) error {
+	if p.fd >= 0 {
+		return pidfd.SendSignal(p.fd, sig)
+	}
+	return syscall.Kill(p.Pid, sig)
+}
+
+// Exited 判断进程是否已经退出，僵尸进程也认为已经退出
+func (p *Process) Exited() bool {
+	if p.fd >= 0 {
+		exited, err := pidfd.Exited(p.fd)
+		if err == nil {
+			return exited
+		}
+		log.Warnf("Poll pidfd of process %d error %v", p.Pid, err)
+	}
+
+	stat, err := ReadProcStat(p.Pid)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	// 启动时间变了，说明原来的进程已经退出，pid 被复用
+	return stat[0] == "Z" || (p.startTime != "" && stat[19] != p.startTime)
+}
+
+// Close 关闭 pidfd
+func (p *Process) Close() {
+	if p.fd >= 0 {
+		syscall.Close(p.fd)
+		p.fd = -1
+	}
+}
+
+// ProcessStartTime 读取进程的启动时间，即 /proc/<pid>/stat 的第 22 个字段，单位是系统启动后的 clock tick
+func ProcessStartTime(pid int) (string, error) {
+	stat, err := ReadProcStat(pid)
+	if err != nil {
+		return "", err
+	}
+	return stat[19], nil
+}
+
+// ProcessCgroup 读取进程所在的 cgroup，即 /proc/<pid>/cgroup 的内容
+func ProcessCgroup(pid int) (string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", fmt.Errorf("read cgroup of process %d error %v", pid, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// BootId 读取宿主机本次启动的 boot id，每次重启都会变化
+func BootId() (string, error) {
+	content, err := os.ReadFile(bootIdPath)
+	if err != nil {
+		return "", fmt.Errorf("read %s error %v", bootIdPath, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// ReadProcStat 读取 /proc/<pid>/stat，返回从第 3 个字段 state 开始的所有字段
+// 格式为 pid (comm) state ...，comm 中可能有空格，从最后一个 ) 开始解析
+func ReadProcStat(pid int) ([]string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	stat := string(content)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("invalid /proc/%d/stat: %s", pid, stat)
+	}
+	return fields, nil
+}
@@ -0,0 +1,114 @@
+package container
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Nevermore12321/dockergsh/pkg/terminal"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+转发给用户进程的信号：
+- SIGSEGV 等同步信号只会由 init 自己触发，SIGTTIN、SIGTTOU、SIGPIPE 也是发给 init 自己的，都不转发
+- SIGKILL、SIGSTOP 无法捕获
+- 实时信号全部转发，例如 systemd 使用 SIGRTMIN+3 关机
+*/
+var forwardedSignals = func() []os.Signal {
+	signals := []os.Signal{
+		syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM,
+		syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGALRM, syscall.SIGCONT,
+		syscall.SIGTSTP, syscall.SIGPWR, syscall.SIGWINCH,
+	}
+	for sig := sigrtmin; sig <= sigrtmax; sig++ {
+		signals = append(signals, syscall.Signal(sig))
+	}
+	return signals
+}()
+
+// 由终端发给前台进程组的信号，终端模式下用户进程在前台进程组中已经收到，再转发会收到两次
+var terminalSignals = map[os.Signal]bool{
+	syscall.SIGINT:   true,
+	syscall.SIGQUIT:  true,
+	syscall.SIGTSTP:  true,
+	syscall.SIGWINCH: true,
+}
+
+// 实时信号的范围，glibc 保留了 32 和 33
+const (
+	sigrtmin = 34
+	sigrtmax = 64
+)
+
+/*
+--init 模式：dockergsh init 进程不再 exec 成用户进程，而是常驻为容器的 1 号进程，类似 tini
+1. fork 出用户进程
+2. 将 forwardedSignals 中的信号转发给用户进程（非终端模式下转发给整个进程组），终端模式下不转发终端产生的信号
+3. 回收所有子进程，包括托管给 1 号进程的孤儿进程，避免僵尸进程
+4. 用户进程退出后，以用户进程的退出码退出
+*/
+func runAsInit(cmdPath string, args []string) error {
+	// 必须在 fork 之前注册，否则用户进程很快退出时会丢失 SIGCHLD
+	signals := make(chan os.Signal, 128)
+	signal.Notify(signals, append(forwardedSignals, syscall.SIGCHLD)...)
+
+	// 非终端模式下，用户进程放到独立的进程组，信号转发给整个进程组
+	// 终端模式下用户进程需要留在前台进程组，否则会失去对终端的控制
+	groupSignal := !terminal.IsTerminal(os.Stdin.Fd())
+
+	childPid, err := syscall.ForkExec(cmdPath, args, &syscall.ProcAttr{
+		Env:   os.Environ(),
+		Files: []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()},
+		Sys:   &syscall.SysProcAttr{Setpgid: groupSignal},
+	})
+	if err != nil {
+		log.Errorf("Fork user process %s error %v", cmdPath, err)
+		return err
+	}
+	log.Infof("Init forked user process %d", childPid)
+
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGCHLD:
+			if exited, exitCode := reapChildren(childPid); exited {
+				os.Exit(exitCode)
+			}
+		default:
+			// 终端产生的信号也要注册，否则 go runtime 默认的处理会让 init 退出
+			if !groupSignal && terminalSignals[sig] {
+				continue
+			}
+			target := childPid
+			if groupSignal {
+				target = -childPid
+			}
+			if err := syscall.Kill(target, sig.(syscall.Signal)); err != nil && err != syscall.ESRCH {
+				log.Warnf("Forward signal %v to %d error %v", sig, target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 回收所有已经退出的子进程，SIGCHLD 可能会合并，所以每次都要循环回收到没有为止
+// 返回用户进程是否已经退出，以及它的退出码
+func reapChildren(childPid int) (bool, int) {
+	exited, exitCode := false, 0
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err != nil || pid <= 0 {
+			return exited, exitCode
+		}
+		if pid != childPid {
+			continue
+		}
+		exited = true
+		if status.Signaled() {
+			exitCode = 128 + int(status.Signal())
+		} else {
+			exitCode = status.ExitStatus()
+		}
+	}
+}
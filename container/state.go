@@ -0,0 +1,72 @@
+package container
+
+import "fmt"
+
+// State 容器的状态
+type State string
+
+const (
+	CREATED    State = "created"
+	RUNNING    State = "running"
+	PAUSED     State = "paused"
+	RESTARTING State = "restarting"
+	STOP       State = "stopped"
+	EXIT       State = "exited"
+	REMOVING   State = "removing"
+	REMOVED    State = "removed"
+)
+
+/*
+容器的状态机，记录每个状态允许转换到的状态：
+1. created -> running，第一次 start
+2. running <-> paused，pause 和 unpause
+3. running/paused/restarting -> stopped/exited，stop 命令停止的是 stopped，容器自己退出的是 exited
+4. stop 命令停止容器时，monitor 进程先记录为 exited，stop 命令随后改为 stopped
+5. running -> restarting -> running，重启策略为 on-unhealthy 时重启不健康的容器
+6. created/stopped/exited -> removing -> removed，删除容器，removed 之后容器记录不再存在
+*/
+var transitions = map[State][]State{
+	CREATED:    {RUNNING, REMOVING},
+	RUNNING:    {PAUSED, RESTARTING, STOP, EXIT},
+	PAUSED:     {RUNNING, STOP, EXIT},
+	RESTARTING: {RUNNING, STOP, EXIT},
+	STOP:       {RUNNING, REMOVING},
+	EXIT:       {RUNNING, STOP, REMOVING},
+	REMOVING:   {REMOVED},
+}
+
+// CanTransition 是否允许从当前状态转换到 to
+func (s State) CanTransition(to State) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Is 是否为 states 中的某个状态
+func (s State) Is(states ...State) bool {
+	for _, state := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Alive 容器是否有正在运行的进程，暂停和重启中的容器也算
+func (s State) Alive() bool {
+	return s.Is(RUNNING, PAUSED, RESTARTING)
+}
+
+// StateError 容器当前的状态不允许执行某个操作
+type StateError struct {
+	Id    string
+	Op    string
+	State State
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s container %s: container is %s", e.Op, e.Id, e.State)
+}
@@ -0,0 +1,79 @@
+package container
+
+import "testing"
+
+// 状态机中的每一条边，不在 legal 中的边都不允许
+func TestCanTransition(t *testing.T) {
+	states := []State{CREATED, RUNNING, PAUSED, RESTARTING, STOP, EXIT, REMOVING, REMOVED}
+	legal := map[State][]State{
+		CREATED:    {RUNNING, REMOVING},
+		RUNNING:    {PAUSED, RESTARTING, STOP, EXIT},
+		PAUSED:     {RUNNING, STOP, EXIT},
+		RESTARTING: {RUNNING, STOP, EXIT},
+		STOP:       {RUNNING, REMOVING},
+		EXIT:       {RUNNING, STOP, REMOVING},
+		REMOVING:   {REMOVED},
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			want := to.Is(legal[from]...)
+			if got := from.CanTransition(to); got != want {
+				t.Errorf("%s -> %s = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+// 容易出错的边单独列出来，状态机修改时这些用例需要一起检查
+func TestCanTransitionCases(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{CREATED, RUNNING, true},
+		{CREATED, PAUSED, false},
+		{CREATED, STOP, false},
+		{RUNNING, REMOVING, false},
+		{RUNNING, RUNNING, false},
+		{PAUSED, PAUSED, false},
+		{PAUSED, REMOVING, false},
+		{RESTARTING, PAUSED, false},
+		{STOP, PAUSED, false},
+		{STOP, EXIT, false},
+		{EXIT, PAUSED, false},
+		{EXIT, STOP, true},
+		{REMOVING, RUNNING, false},
+		{REMOVING, REMOVING, false},
+		{REMOVED, CREATED, false},
+		{REMOVED, RUNNING, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestAlive(t *testing.T) {
+	tests := []struct {
+		state State
+		want  bool
+	}{
+		{CREATED, false},
+		{RUNNING, true},
+		{PAUSED, true},
+		{RESTARTING, true},
+		{STOP, false},
+		{EXIT, false},
+		{REMOVING, false},
+		{REMOVED, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.Alive(); got != tt.want {
+			t.Errorf("%s.Alive() = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 容器中的用户和组文件，测试时可以指向临时文件
+var (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// ExecUser exec 进程的用户
+type ExecUser struct {
+	Uid    int
+	Gid    int
+	Groups []int  // 附加组
+	Home   string // 用户的家目录，用于设置 HOME
+}
+
+/*
+lookupUser 解析 user[:group]，在 chroot 之后调用，读取的是容器中的 /etc/passwd 和 /etc/group
+- user 为名字时必须存在于 /etc/passwd，为数字时可以不存在，此时 gid 默认与 uid 相同
+- 没有指定 group 时使用 /etc/passwd 中的主组，附加组为 /etc/group 中包含该用户的组
+*/
+func lookupUser(spec string) (*ExecUser, error) {
+	userSpec, groupSpec, hasGroup := strings.Cut(spec, ":")
+	if userSpec == "" {
+		userSpec = "0"
+	}
+
+	users, err := readColonFile(passwdPath, 7)
+	if err != nil {
+		return nil, err
+	}
+	execUser := &ExecUser{Uid: -1, Home: "/"}
+	var userName string
+	for _, fields := range users {
+		if fields[0] == userSpec || fields[2] == userSpec {
+			userName = fields[0]
+			execUser.Uid, _ = strconv.Atoi(fields[2])
+			execUser.Gid, _ = strconv.Atoi(fields[3])
+			execUser.Home = fields[5]
+			break
+		}
+	}
+	if execUser.Uid == -1 {
+		uid, err := strconv.Atoi(userSpec)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find user %s: no matching entries in passwd file", userSpec)
+		}
+		execUser.Uid = uid
+		execUser.Gid = uid
+	}
+
+	groups, err := readColonFile(groupPath, 4)
+	if err != nil {
+		return nil, err
+	}
+	if hasGroup {
+		execUser.Gid = -1
+		for _, fields := range groups {
+			if fields[0] == groupSpec || fields[2] == groupSpec {
+				execUser.Gid, _ = strconv.Atoi(fields[2])
+				break
+			}
+		}
+		if execUser.Gid == -1 {
+			gid, err := strconv.Atoi(groupSpec)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find group %s: no matching entries in group file", groupSpec)
+			}
+			execUser.Gid = gid
+		}
+		return execUser, nil
+	}
+
+	// 只有指定了用户时才使用 /etc/group 中的附加组
+	for _, fields := range groups {
+		if userName == "" {
+			break
+		}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member == userName {
+				if gid, err := strconv.Atoi(fields[2]); err == nil {
+					execUser.Groups = append(execUser.Groups, gid)
+				}
+				break
+			}
+		}
+	}
+	return execUser, nil
+}
+
+// 读取以冒号分割的文件，忽略注释和字段数不足的行，文件不存在时返回空
+func readColonFile(path string, fieldCount int) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < fieldCount {
+			continue
+		}
+		entries = append(entries, fields)
+	}
+	return entries, scanner.Err()
+}
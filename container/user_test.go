@@ -0,0 +1,96 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPasswd = `# 注释和字段数不足的行被忽略
+root:x:0:0:root:/root:/bin/sh
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+broken:x:5
+app:x:1000:1000:app:/home/app:/bin/sh
+`
+
+const testGroup = `root:x:0:
+daemon:x:1:
+wheel:x:10:app,root
+docker:x:999:app
+app:x:1000:
+staff:x:50:
+`
+
+func useTestUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldPasswd, oldGroup := passwdPath, groupPath
+	passwdPath, groupPath = filepath.Join(dir, "passwd"), filepath.Join(dir, "group")
+	t.Cleanup(func() { passwdPath, groupPath = oldPasswd, oldGroup })
+	if err := os.WriteFile(passwdPath, []byte(testPasswd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(groupPath, []byte(testGroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	useTestUserFiles(t)
+
+	tests := []struct {
+		spec    string
+		want    *ExecUser
+		wantErr string
+	}{
+		{spec: "", want: &ExecUser{Uid: 0, Gid: 0, Groups: []int{10}, Home: "/root"}},
+		{spec: "root", want: &ExecUser{Uid: 0, Gid: 0, Groups: []int{10}, Home: "/root"}},
+		// 名字和 uid 都可以找到 passwd 中的用户，附加组来自 /etc/group
+		{spec: "app", want: &ExecUser{Uid: 1000, Gid: 1000, Groups: []int{10, 999}, Home: "/home/app"}},
+		{spec: "1000", want: &ExecUser{Uid: 1000, Gid: 1000, Groups: []int{10, 999}, Home: "/home/app"}},
+		// passwd 中没有的 uid，gid 与 uid 相同，没有附加组
+		{spec: "4242", want: &ExecUser{Uid: 4242, Gid: 4242, Home: "/"}},
+		// 指定组时不使用附加组
+		{spec: "app:staff", want: &ExecUser{Uid: 1000, Gid: 50, Home: "/home/app"}},
+		{spec: "app:999", want: &ExecUser{Uid: 1000, Gid: 999, Home: "/home/app"}},
+		{spec: "4242:4343", want: &ExecUser{Uid: 4242, Gid: 4343, Home: "/"}},
+		{spec: ":wheel", want: &ExecUser{Uid: 0, Gid: 10, Home: "/root"}},
+		{spec: "nobody", wantErr: "unable to find user nobody"},
+		{spec: "broken", wantErr: "unable to find user broken"},
+		{spec: "app:nogroup", wantErr: "unable to find group nogroup"},
+	}
+
+	for _, tt := range tests {
+		got, err := lookupUser(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("lookupUser(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lookupUser(%q) error %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookupUser(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// 容器中没有 /etc/passwd 和 /etc/group 时只能使用数字
+func TestLookupUserWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldPasswd, oldGroup := passwdPath, groupPath
+	passwdPath, groupPath = filepath.Join(dir, "passwd"), filepath.Join(dir, "group")
+	t.Cleanup(func() { passwdPath, groupPath = oldPasswd, oldGroup })
+
+	got, err := lookupUser("1000:1001")
+	if err != nil || !reflect.DeepEqual(got, &ExecUser{Uid: 1000, Gid: 1001, Home: "/"}) {
+		t.Errorf("lookupUser(1000:1001) = %+v, %v", got, err)
+	}
+	if _, err := lookupUser("root"); err == nil {
+		t.Errorf("lookupUser(root) without passwd file error = nil")
+	}
+}
@@ -1,6 +1,7 @@
 package container
 
 import (
+	"github.com/Nevermore12321/dockergsh/utils"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"os/exec"
@@ -85,6 +86,9 @@ func MountVolume(volumeURLs []string, mergeURL string) error  {
 func DeleteVolumeMountPoint(volumeURLs []string, mergeURL string) error {
 	// 容器中的 volume 实际目录在 merge layer 下
 	containerURL := mergeURL + "/" + volumeURLs[1]
+	if !utils.IsMounted(containerURL) {
+		return nil
+	}
 
 	// umount volume
 	umountCmd := exec.Command("umount", containerURL)
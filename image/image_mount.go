@@ -166,6 +166,10 @@ func DeleteWriteLayer(rootURL string) error {
 解除挂载 merge layer
 */
 func DeleteMountPoint(mergeURL string) error {
+	// 已经卸载过的不再卸载，容器退出和删除时都会调用
+	if !utils.IsMounted(mergeURL) {
+		return nil
+	}
 	umountCmd := exec.Command("umount", mergeURL)
 	umountCmd.Stderr = os.Stderr
 	umountCmd.Stdout = os.Stdout
@@ -1,7 +1,113 @@
 package events
 
-// events 为 Dockergsh 注册了多个event事件
-// 功能是给Docker用户提供API，使得用户可以通过这些API查看Docker内部的:
-// 1. events信息
-// 2. log信息
-// 3. subscribers_count信息
+/*
+events 记录容器、网络和 volume 的生命周期事件：
+1. 每个事件以一行 json 追加到 /var/lib/dockergsh/events.log，追加时加 events.lock 排他锁，多个命令同时写入不会交错
+2. 日志超过 maxJournalSize 后轮转为 events.log.1，只保留一个旧文件
+3. dockergsh events 按时间顺序读取旧文件和当前文件，并可以持续输出新的事件
+记录事件失败不影响命令本身的执行，只输出警告
+*/
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	log "github.com/sirupsen/logrus"
+)
+
+// 事件的类型
+const (
+	ContainerEventType = "container"
+	NetworkEventType   = "network"
+	VolumeEventType    = "volume"
+)
+
+const (
+	journalName     = "events.log"
+	journalLockName = "events.lock"
+)
+
+var (
+	// Root 事件日志所在的目录
+	Root = container.DefaultFsURL
+	// 日志超过这个大小后轮转
+	maxJournalSize int64 = 16 * 1024 * 1024
+)
+
+// Event 一个生命周期事件
+type Event struct {
+	Type       string            `json:"type"`                 // container、network 或 volume
+	Action     string            `json:"action"`               // 事件名，例如 create、start、die
+	Id         string            `json:"id"`                   // 容器 id、网络名或者 volume 名
+	Attributes map[string]string `json:"attributes,omitempty"` // 事件的其他信息，例如容器名、退出码
+	Time       int64             `json:"time"`                 // 事件发生的时间，unix 纳秒时间戳
+}
+
+func journalPath() string {
+	return filepath.Join(Root, journalName)
+}
+
+// 轮转后的旧日志
+func rotatedJournalPath() string {
+	return journalPath() + ".1"
+}
+
+// Log 记录一个事件
+func Log(eventType, action, id string, attributes map[string]string) {
+	event := &Event{
+		Type:       eventType,
+		Action:     action,
+		Id:         id,
+		Attributes: attributes,
+		Time:       time.Now().UnixNano(),
+	}
+	if err := appendEvent(event); err != nil {
+		log.Warnf("Record %s event %s of %s error %v", eventType, action, id, err)
+	}
+}
+
+// 加锁后把事件追加到日志末尾，日志过大时先轮转
+func appendEvent(event *Event) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Root, 0755); err != nil {
+		return err
+	}
+	lockFile, err := os.OpenFile(filepath.Join(Root, journalLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+	if err := flock(lockFile, syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	if stat, err := os.Stat(journalPath()); err == nil && stat.Size() >= maxJournalSize {
+		if err := os.Rename(journalPath(), rotatedJournalPath()); err != nil {
+			return err
+		}
+	}
+	journal, err := os.OpenFile(journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	_, err = journal.Write(append(content, '\n'))
+	return err
+}
+
+// 加 flock，被信号打断时重试，文件关闭时锁自动释放
+func flock(file *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func useTempRoot(t *testing.T) {
+	oldRoot := Root
+	Root = t.TempDir()
+	t.Cleanup(func() { Root = oldRoot })
+}
+
+func collect(t *testing.T, since, until time.Time) []string {
+	var actions []string
+	if err := Stream(since, until, false, nil, func(event *Event) error {
+		actions = append(actions, event.Action)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return actions
+}
+
+func TestStream(t *testing.T) {
+	useTempRoot(t)
+	if actions := collect(t, time.Time{}, time.Time{}); len(actions) != 0 {
+		t.Fatalf("expected no events, got %v", actions)
+	}
+
+	Log(ContainerEventType, "create", "abc123", map[string]string{"name": "web"})
+	time.Sleep(10 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	Log(ContainerEventType, "start", "abc123", nil)
+	Log(ContainerEventType, "die", "abc123", map[string]string{"exitCode": "0"})
+
+	tests := []struct {
+		since, until time.Time
+		want         []string
+	}{
+		{time.Time{}, time.Time{}, []string{"create", "start", "die"}},
+		{middle, time.Time{}, []string{"start", "die"}},
+		{time.Time{}, middle, []string{"create"}},
+	}
+	for _, test := range tests {
+		actions := collect(t, test.since, test.until)
+		if len(actions) != len(test.want) {
+			t.Fatalf("Stream(%v, %v) = %v, want %v", test.since, test.until, actions, test.want)
+		}
+		for i := range actions {
+			if actions[i] != test.want[i] {
+				t.Fatalf("Stream(%v, %v) = %v, want %v", test.since, test.until, actions, test.want)
+			}
+		}
+	}
+}
+
+func TestFollowRotation(t *testing.T) {
+	useTempRoot(t)
+	oldSize := maxJournalSize
+	maxJournalSize = 1
+	t.Cleanup(func() { maxJournalSize = oldSize })
+
+	Log(ContainerEventType, "create", "abc123", nil)
+	received := make(chan string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Stream(time.Time{}, time.Time{}, true, stop, func(event *Event) error {
+			received <- event.Action
+			return nil
+		})
+	}()
+
+	// 每次写入都会轮转，follow 需要读完旧文件后切换到新文件
+	for _, action := range []string{"start", "die"} {
+		time.Sleep(2 * followInterval)
+		Log(ContainerEventType, action, "abc123", nil)
+	}
+	for _, want := range []string{"create", "start", "die"} {
+		select {
+		case action := <-received:
+			if action != want {
+				t.Fatalf("expected %s, got %s", want, action)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// follow 模式下检查新事件的间隔
+const followInterval = 200 * time.Millisecond
+
+// 事件的时间超过 until，停止读取
+var errUntilReached = errors.New("until reached")
+
+// 读取一个日志文件，记录读到的位置，之后只读取新追加的事件
+type journalReader struct {
+	file    *os.File
+	stat    os.FileInfo
+	pending []byte // 还没有读到换行符的半行，写入方还没有写完
+}
+
+// 打开日志文件，文件不存在时返回 nil
+func openJournal(path string) (*journalReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &journalReader{file: file, stat: stat}, nil
+}
+
+// 读取上次读到的位置之后所有完整的事件
+func (r *journalReader) readEvents(fn func(*Event) error) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.file.Read(buf)
+		if n > 0 {
+			r.pending = append(r.pending, buf[:n]...)
+			for {
+				i := bytes.IndexByte(r.pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := r.pending[:i]
+				r.pending = r.pending[i+1:]
+				event := &Event{}
+				if err := json.Unmarshal(line, event); err != nil {
+					log.Warnf("Skip invalid event %q: %v", line, err)
+					continue
+				}
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *journalReader) close() {
+	if r != nil {
+		r.file.Close()
+	}
+}
+
+// 加共享锁同时打开旧日志和当前日志，避免打开的过程中日志被轮转
+func openJournals() (*journalReader, *journalReader, error) {
+	lockFile, err := os.OpenFile(filepath.Join(Root, journalLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	defer lockFile.Close()
+	if err := flock(lockFile, syscall.LOCK_SH); err != nil {
+		return nil, nil, err
+	}
+
+	rotated, err := openJournal(rotatedJournalPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := openJournal(journalPath())
+	if err != nil {
+		rotated.close()
+		return nil, nil, err
+	}
+	return rotated, current, nil
+}
+
+/*
+Stream 按时间顺序把 since 和 until 之间的事件交给 fn，since、until 为零值表示不限制：
+1. 先读取轮转后的旧日志，再读取当前日志
+2. follow 为 true 时，读完已有的事件后继续等待新的事件，直到超过 until 或者 stop 被关闭
+3. 等待期间日志被轮转时，读完旧文件中剩下的事件后切换到新的日志
+*/
+func Stream(since, until time.Time, follow bool, stop <-chan struct{}, fn func(*Event) error) error {
+	emit := func(event *Event) error {
+		if !since.IsZero() && event.Time < since.UnixNano() {
+			return nil
+		}
+		if !until.IsZero() && event.Time > until.UnixNano() {
+			return errUntilReached
+		}
+		return fn(event)
+	}
+
+	rotated, current, err := openJournals()
+	if err != nil {
+		return err
+	}
+	defer func() { current.close() }()
+	if rotated != nil {
+		err = rotated.readEvents(emit)
+		rotated.close()
+		if err != nil {
+			return ignoreUntil(err)
+		}
+	}
+	if current != nil {
+		if err := current.readEvents(emit); err != nil {
+			return ignoreUntil(err)
+		}
+	}
+	if !follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		if !until.IsZero() && time.Now().After(until) {
+			return nil
+		}
+		if current != nil {
+			if err := current.readEvents(emit); err != nil {
+				return ignoreUntil(err)
+			}
+			// 当前日志没有被轮转，继续读取这个文件
+			if stat, err := os.Stat(journalPath()); err != nil || os.SameFile(stat, current.stat) {
+				continue
+			}
+			// 轮转之后旧文件中可能还有没有读到的事件
+			if err := current.readEvents(emit); err != nil {
+				return ignoreUntil(err)
+			}
+			current.close()
+		}
+		if current, err = openJournal(journalPath()); err != nil {
+			return err
+		}
+		if current != nil {
+			if err := current.readEvents(emit); err != nil {
+				return ignoreUntil(err)
+			}
+		}
+	}
+}
+
+func ignoreUntil(err error) error {
+	if errors.Is(err, errUntilReached) {
+		return nil
+	}
+	return err
+}
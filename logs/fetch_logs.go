@@ -8,30 +8,16 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
-	"path/filepath"
 )
 
 func LogContainer(containerArg string) error {
-	// 先在 named_containers 目录下找有没有容器，判断该容器有没有命名
-	// 表示有名字的容器
-	containersUrl := fmt.Sprintf(container.DefaultInfoLocation, container.NamedContainersDir)
-	files, err := os.ReadDir(containersUrl)
-	// idFlag 表示 containerArg 表示的是否是 id
-	for _, file := range files {
-		// 如果是 containerName
-		if file.Name() == containerArg {
-			configURL := filepath.Join(containersUrl, file.Name(), container.ConfigName)
-			// 读取配置文件 config.json
-			info, err := cmdExec.GetContainerInfo(configURL)
-			if err != nil {
-				if err != os.ErrInvalid {
-					logrus.Errorf("Get container info error %v", err)
-				}
-			}
-			containerArg = info.Id
-			break
-		}
+	// 容器名和 id 前缀都解析为完整的容器 id
+	info, err := cmdExec.GetContainerInfoByArg(containerArg)
+	if err != nil {
+		logrus.Errorf("Get container info error %v", err)
+		return err
 	}
+	containerArg = info.Id
 
 	hashId := utils.EncodeSha256([]byte(containerArg))
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/Nevermore12321/dockergsh/cmdExec"
 	cmd "github.com/Nevermore12321/dockergsh/command"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -15,7 +16,10 @@ func main() {
 	// 初始化命令行 app
 	app := cli.NewApp()
 
-	log.Println(usage)
+	// exec 进程的输出就是用户命令的输出，不打印 usage
+	if os.Getenv(cmdExec.ENV_EXEC_PID) == "" {
+		log.Println(usage)
+	}
 
 	// 配置命令行 app
 	app.Name = "dockergsh"
@@ -23,19 +27,37 @@ func main() {
 	app.Commands = []*cli.Command{
 		cmd.InitCommand,
 		cmd.RunCommand,
+		cmd.CreateCommand,
+		cmd.StartCommand,
 		cmd.CommitCommand,
 		cmd.ListCommand,
 		cmd.LogsCommand,
 		cmd.ExecCommand,
 		cmd.StopCommand,
+		cmd.KillCommand,
+		cmd.WaitCommand,
+		cmd.PauseCommand,
+		cmd.UnpauseCommand,
+		cmd.TopCommand,
+		cmd.StatsCommand,
+		cmd.UpdateCommand,
 		cmd.RemoveCommand,
+		cmd.RenameCommand,
 		cmd.NetworkCommand,
+		cmd.InspectCommand,
+		cmd.EventsCommand,
+		cmd.SystemCommand,
+		cmd.VolumeCommand,
 	}
 
 	// 命令运行前的初始化 logrus 的日志配置
 	app.Before = func(context *cli.Context) error {
 		log.SetFormatter(&log.JSONFormatter{})
 		log.SetOutput(os.Stdout)
+		// 宿主机重启后先修复残留的容器状态，容器内的 init 进程和 exec 进程不需要检查
+		if context.Args().First() != cmd.InitCommand.Name && os.Getenv(cmdExec.ENV_EXEC_PID) == "" {
+			cmdExec.ReconcileAfterReboot()
+		}
 		return nil
 	}
 
@@ -135,6 +135,20 @@ func (bd *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) err
 
 // Linux-bridge 将新建的网络端点删除，断开连接
 func (bd *BridgeNetworkDriver) Disconnect(network *Network, endpoint *Endpoint) error {
+	// 宿主机上的 veth 端点名就是 endpoint-id 的前5位
+	// 容器的 network namespace 销毁时，veth 设备可能已经被内核删除了
+	link, err := netlink.LinkByName(endpoint.Device.Name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return fmt.Errorf("error find Endpoint Device %s: %v", endpoint.Device.Name, err)
+	}
+
+	// 删除 veth 的一端，另一端也会一起被删除
+	if err = netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("error delete Endpoint Device %s: %v", endpoint.Device.Name, err)
+	}
 	return nil
 }
 
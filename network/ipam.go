@@ -1,7 +1,9 @@
 package network
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net"
 	"os"
@@ -219,3 +221,47 @@ func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) (err error) {
 
 	return
 }
+
+/*
+Reconcile 对比位图和正在使用的 ip，释放已分配但是没有使用者的 ip（例如容器异常退出后没有释放的 ip）
+inUse 为正在使用的 ip，包括网络的网关和运行中容器的 ip
+返回没有使用者的 ip，dryRun 为 true 时只返回，不修改 subnet.json
+*/
+func (ipam *IPAM) Reconcile(inUse []net.IP, dryRun bool) ([]string, error) {
+	ipam.Subnets = &map[string][]byte{}
+	if err := ipam.load(); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, ip := range inUse {
+		used[ip.String()] = true
+	}
+
+	var leaked []string
+	for subnetStr, ipalloc := range *ipam.Subnets {
+		_, subnet, err := net.ParseCIDR(subnetStr)
+		if err != nil {
+			log.Errorf("Subnet convert to IPNet err: %v", err)
+			continue
+		}
+		for index, allocated := range ipalloc {
+			if allocated == 0 {
+				continue
+			}
+			// 与 Allocate 一致，位图的第 index 位对应网段中的第 index+1 个 ip
+			ip := make(net.IP, net.IPv4len)
+			binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(subnet.IP.To4())+uint32(index)+1)
+			if used[ip.String()] {
+				continue
+			}
+			leaked = append(leaked, fmt.Sprintf("%s in %s", ip, subnetStr))
+			ipalloc[index] = 0
+		}
+	}
+
+	if dryRun || len(leaked) == 0 {
+		return leaked, nil
+	}
+	return leaked, ipam.dump()
+}
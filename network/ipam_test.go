@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"path/filepath"
 	"testing"
 )
 
@@ -21,3 +22,39 @@ func TestRelease(t *testing.T) {
 	IpAllocator.Release(ipnet, &ip)
 	fmt.Println(ip)
 }
+
+func TestReconcile(t *testing.T) {
+	ipam := &IPAM{SubnetAllocatorPath: filepath.Join(t.TempDir(), "subnet.json")}
+	_, ipnet, _ := net.ParseCIDR("192.168.10.0/24")
+	// 依次分配网关 .1 和容器 .2 .3
+	for i := 0; i < 3; i++ {
+		if _, err := ipam.Allocate(ipnet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 只有网关和 .3 还在使用，.2 没有使用者
+	inUse := []net.IP{net.ParseIP("192.168.10.1"), net.ParseIP("192.168.10.3")}
+	leaked, err := ipam.Reconcile(inUse, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaked) != 1 || leaked[0] != "192.168.10.2 in 192.168.10.0/24" {
+		t.Fatalf("dry run leaked = %v", leaked)
+	}
+	if leaked, _ = ipam.Reconcile(inUse, false); len(leaked) != 1 {
+		t.Fatalf("leaked = %v", leaked)
+	}
+	if leaked, _ = ipam.Reconcile(inUse, false); len(leaked) != 0 {
+		t.Fatalf("leaked after reconcile = %v", leaked)
+	}
+
+	// 释放的 ip 可以重新分配
+	ip, err := ipam.Allocate(ipnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "192.168.10.2" {
+		t.Errorf("Allocate after reconcile = %s, want 192.168.10.2", ip)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/internal/events"
+	"github.com/Nevermore12321/dockergsh/pkg/filters"
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
@@ -26,9 +28,10 @@ var (
 
 // Network 网络驱动 driver
 type Network struct {
-	Name    string     // 网络名
-	IpRange *net.IPNet // 网络地址段
-	Driver  string     // 网络驱动名
+	Name    string            // 网络名
+	IpRange *net.IPNet        // 网络地址段
+	Driver  string            // 网络驱动名
+	Labels  map[string]string // 网络的标签
 }
 
 // Endpoint 网络端点
@@ -188,7 +191,7 @@ func Init() error {
 }
 
 // CreateNetwork 创建网络
-func CreateNetwork(driver, subnet, name string) error {
+func CreateNetwork(driver, subnet, name string, labels map[string]string) error {
 	// ParseCIDR 将 网段的 ip 地址转换成 net.IpNet 对象，例如 ParseCIDR("192.0.2.1/24")
 	_, cidr, _ := net.ParseCIDR(subnet)
 	// 通过 IPAM 组件，分配网关 IP 地址，获取网络的 第一个 IP 地址作为 网关 IP
@@ -203,14 +206,15 @@ func CreateNetwork(driver, subnet, name string) error {
 	if err != nil {
 		return err
 	}
+	network.Labels = labels
 
 	// 保存网络信息，将网络信息保存在网络的配置文件中，以便查询和在网络上连接网络端点。
 	return network.dump(networkDefaultPath)
 
 }
 
-// 展示所有的网络
-func ListNetwork() error {
+// 展示所有的网络，只显示满足 filterArgs 的网络
+func ListNetwork(filterArgs filters.Args) error {
 	// 通过 tabwrite 格式化输出
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	_, err := fmt.Fprintf(w, "Name\tIpRange\tDriver\n")
@@ -220,6 +224,9 @@ func ListNetwork() error {
 
 	// 遍历 init 中读取到的所有网络的全局变量，显示
 	for _, nw := range networks {
+		if !filterArgs.MatchLabels(nw.Labels) {
+			continue
+		}
 		_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", nw.Name, nw.IpRange.String(), nw.Driver)
 		if err != nil {
 			return err
@@ -243,10 +250,15 @@ func ConnectNetwork(networkName string, containerInfo *container.ContainerInfo)
 		return fmt.Errorf("No Such Network: %s", networkName)
 	}
 
-	// 通过 IPAM 从网络的网段中，分配一个可用的 IP 地址
-	ip, err := IpAllocator.Allocate(network.IpRange)
-	if err != nil {
-		return err
+	// create 时已经分配过 ip 的直接使用，否则通过 IPAM 从网络的网段中，分配一个可用的 IP 地址
+	var ip net.IP
+	if containerInfo.Network == networkName && containerInfo.IpAddress != "" {
+		ip = net.ParseIP(containerInfo.IpAddress)
+	} else {
+		var err error
+		if ip, err = IpAllocator.Allocate(network.IpRange); err != nil {
+			return err
+		}
 	}
 
 	// 创建网络端点 endpoint
@@ -260,19 +272,70 @@ func ConnectNetwork(networkName string, containerInfo *container.ContainerInfo)
 
 	// 通过调用 network driver 的 connect 方法，将网络端点与网络进行连接，这里是以  linux-bridge 为例
 	// 第一步，将endpoint 的一端连接到
-	if err = drivers[network.Driver].Connect(network, endpoint); err != nil {
+	if err := drivers[network.Driver].Connect(network, endpoint); err != nil {
 		return err
 	}
 
 	// 第二步，将 endpoint Veth 的另一端连接到 容器的 namespace
-	if err = configEndpointIpAddressAndRoute(endpoint, containerInfo); err != nil {
+	if err := configEndpointIpAddressAndRoute(endpoint, containerInfo); err != nil {
 		return err
 	}
 
+	// 记录容器连接的网络和分配到的 ip，容器停止时用来释放网络资源
+	containerInfo.Network = networkName
+	containerInfo.IpAddress = ip.String()
+	events.Log(events.NetworkEventType, "connect", networkName, map[string]string{"container": containerInfo.Id, "type": network.Driver})
 	return nil
 	// todo portmapping
 }
 
+// AllocateIp 创建容器时从网络中为容器分配 ip，容器 start 时 ConnectNetwork 使用该 ip 创建网络端点
+func AllocateIp(networkName string, containerInfo *container.ContainerInfo) error {
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("No Such Network: %s", networkName)
+	}
+	ip, err := IpAllocator.Allocate(network.IpRange)
+	if err != nil {
+		return err
+	}
+	containerInfo.Network = networkName
+	containerInfo.IpAddress = ip.String()
+	return nil
+}
+
+// DisconnectNetwork 容器停止时，删除容器的网络端点，并释放容器的 ip
+func DisconnectNetwork(containerInfo *container.ContainerInfo) error {
+	if containerInfo.Network == "" {
+		return nil
+	}
+	network, ok := networks[containerInfo.Network]
+	if !ok {
+		return fmt.Errorf("no Such Network: %s", containerInfo.Network)
+	}
+
+	// endpoint 的信息与 ConnectNetwork 中创建时保持一致
+	linkAttrs := netlink.NewLinkAttrs()
+	linkAttrs.Name = endpointDeviceName(containerInfo.Id, containerInfo.Network)
+	endpoint := &Endpoint{
+		Id:      fmt.Sprintf("%s-%s", containerInfo.Id, containerInfo.Network),
+		Device:  netlink.Veth{LinkAttrs: linkAttrs},
+		Network: network,
+	}
+	if err := drivers[network.Driver].Disconnect(network, endpoint); err != nil {
+		return err
+	}
+
+	if containerInfo.IpAddress != "" {
+		ip := net.ParseIP(containerInfo.IpAddress)
+		if err := IpAllocator.Release(network.IpRange, &ip); err != nil {
+			return fmt.Errorf("release ip %s error: %v", containerInfo.IpAddress, err)
+		}
+	}
+	events.Log(events.NetworkEventType, "disconnect", containerInfo.Network, map[string]string{"container": containerInfo.Id, "type": network.Driver})
+	return nil
+}
+
 // 容器有自己的 network namespace，因此需要将 上一步创建的 veth 设备的一端，添加到容器的 namespace 中
 // 才能将该 容器 插上网线，连接到此网络
 func configEndpointIpAddressAndRoute(endpoint *Endpoint, containerInfo *container.ContainerInfo) error {
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/vishvananda/netlink"
+)
+
+// 容器在宿主机上的 veth 端点名，与 bridge 驱动 Connect 时创建的设备名一致
+func endpointDeviceName(containerId, networkName string) string {
+	return fmt.Sprintf("%s-%s", containerId, networkName)[:5]
+}
+
+/*
+Prune 清理没有容器使用的网络资源，containers 为正在运行的容器：
+1. 连接在 dockergsh 网络的 bridge 上，但是不属于任何运行中容器的 veth 设备
+2. subnet.json 中已分配，但既不是网关也不属于运行中容器的 ip
+返回发现的问题，dryRun 为 true 时只返回，不做修改
+*/
+func Prune(containers []*container.ContainerInfo, dryRun bool) ([]string, error) {
+	if err := Init(); err != nil {
+		return nil, err
+	}
+
+	liveEndpoints := make(map[string]bool)
+	var inUse []net.IP
+	for _, nw := range networks {
+		inUse = append(inUse, nw.IpRange.IP)
+	}
+	for _, info := range containers {
+		if info.Network == "" {
+			continue
+		}
+		liveEndpoints[endpointDeviceName(info.Id, info.Network)] = true
+		if ip := net.ParseIP(info.IpAddress); ip != nil {
+			inUse = append(inUse, ip)
+		}
+	}
+
+	var problems []string
+	// bridge 设备的 index 到网络名
+	bridges := make(map[int]string)
+	for _, nw := range networks {
+		if nw.Driver != "bridge" {
+			continue
+		}
+		if br, err := netlink.LinkByName(nw.Name); err == nil {
+			bridges[br.Attrs().Index] = nw.Name
+		}
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("list links error %v", err)
+	}
+	for _, link := range links {
+		attrs := link.Attrs()
+		bridgeName, ok := bridges[attrs.MasterIndex]
+		if link.Type() != "veth" || !ok || liveEndpoints[attrs.Name] {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("dangling veth %s on bridge %s", attrs.Name, bridgeName))
+		if dryRun {
+			continue
+		}
+		if err := netlink.LinkDel(link); err != nil {
+			return problems, fmt.Errorf("delete veth %s error %v", attrs.Name, err)
+		}
+	}
+
+	leaked, err := IpAllocator.Reconcile(inUse, dryRun)
+	if err != nil {
+		return problems, err
+	}
+	for _, ip := range leaked {
+		problems = append(problems, fmt.Sprintf("ip %s has no owner", ip))
+	}
+	return problems, nil
+}
@@ -8,52 +8,165 @@ package nsenter
 #include <stdlib.h>
 #include <string.h>
 #include <fcntl.h>
+#include <signal.h>
 #include <unistd.h>
+#include <sys/stat.h>
+#include <sys/syscall.h>
+#include <sys/wait.h>
 
+#ifndef __NR_pidfd_send_signal
+#define __NR_pidfd_send_signal 424
+#endif
+
+#ifndef CLONE_NEWCGROUP
+#define CLONE_NEWCGROUP 0x02000000
+#endif
+
+// 父进程通过 fd 3 的管道传递配置，先发送一个字节表示已经把当前进程加入了容器的 cgroup
+#define EXEC_PIPE_FD 3
+// fork 之后通过 fd 4 的管道把用户命令在宿主机上的 pid 告诉父进程
+#define EXEC_PID_PIPE_FD 4
+
+// 判断容器进程的 namespace 与当前进程的是否为同一个
+static int same_namespace(const char *pid, const char *ns) {
+	char nspath[1024];
+	struct stat self_st, target_st;
+	sprintf(nspath, "/proc/self/ns/%s", ns);
+	if (stat(nspath, &self_st) == -1) {
+		return 0;
+	}
+	sprintf(nspath, "/proc/%s/ns/%s", pid, ns);
+	if (stat(nspath, &target_st) == -1) {
+		return 0;
+	}
+	return self_st.st_dev == target_st.st_dev && self_st.st_ino == target_st.st_ino;
+}
+
+// 通过 /proc/<pid>/ns 逐个进入 namespace，user namespace 要第一个进入，之后才有权限进入它拥有的其他 namespace
+static void setns_by_path(const char *pid, int pidfd, int join_user) {
+	char *namespaces[] = {"user", "ipc", "uts", "net", "pid", "mnt", "cgroup"};
+	int fds[7];
+	int i;
+	// 暂存不同 namespace 文件路径
+	char nspath[1024];
+	// 先打开所有的 namespace 文件
+	for (i = 0; i < 7; i++) {
+		fds[i] = -1;
+		if (i == 0 && !join_user) {
+			continue;
+		}
+		sprintf(nspath, "/proc/%s/ns/%s", pid, namespaces[i]);
+		fds[i] = open(nspath, O_RDONLY);
+		if (fds[i] == -1) {
+			fprintf(stderr, "open %s failed: %s\n", nspath, strerror(errno));
+			exit(1);
+		}
+	}
+	// 打开之后 pidfd 指向的进程还存活，说明打开的是校验过的容器进程的 namespace，而不是复用了 pid 的其他进程
+	if (pidfd >= 0 && syscall(__NR_pidfd_send_signal, pidfd, 0, NULL, 0) == -1) {
+		fprintf(stderr, "container process %s has exited: %s\n", pid, strerror(errno));
+		exit(1);
+	}
+	for (i = 0; i < 7; i++) {
+		if (fds[i] == -1) {
+			continue;
+		}
+		// 将当前进程加入到指定的 namespace 中
+		if (setns(fds[i], 0) == -1) {
+			fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
+			exit(1);
+		}
+		close(fds[i]);
+	}
+}
 
 //这里的__attribute__((constructor))指的是， 一旦这个包被引用，那么这个函数就会被自动执行
-//类似于构造函数，会在程序一启动的时候运行
+//类似于构造函数，会在程序一启动的时候运行，此时 go runtime 还没有启动，进程是单线程的，可以进入 mnt 和 user namespace
 __attribute__((constructor)) void enter_namespace(void) {
 	char *dockergsh_pid;
-	// 从环境变量中读取 容器的 pid
+	// 从环境变量中读取 容器的 pid，没有设置说明不是 exec 的子进程
 	dockergsh_pid = getenv("dockergsh_pid");
-	if (dockergsh_pid) {
-		fprintf(stdout, "got dockergsh_pid=%s\n", dockergsh_pid);
-	} else {
-		fprintf(stdout, "missing dockergsh_pid env skip nsenter\n");
+	if (!dockergsh_pid) {
 		return;
 	}
 
-	char *dockergsh_cmd;
-	// 从环境变量中读取 exec 的命令
-	dockergsh_cmd = getenv("dockergsh_cmd");
-	if (dockergsh_pid) {
-		fprintf(stdout, "got dockergsh_cmd=%s\n", dockergsh_cmd);
-	} else {
-		fprintf(stdout, "missing dockergsh_cmd env skip nsenter\n");
-		return;
+	// 等待父进程把当前进程加入容器的 cgroup，之后 fork 出的进程也会在容器的 cgroup 中
+	char sync;
+	if (read(EXEC_PIPE_FD, &sync, 1) != 1) {
+		fprintf(stderr, "read sync from parent failed\n");
+		exit(1);
 	}
 
-	// 需要设置的 5 中 namespace
-	char *namespaces[] = {"ipc", "uts", "net", "pid", "mnt"};
-	int i;
-	// 暂存不同 namespace 文件路径
-	char nspath[1024];
-	for (i = 0; i < 5; i++) {
-		sprintf(nspath, "/proc/%s/ns/%s", dockergsh_pid, namespaces[i]);
-		int fd =open(nspath,O_RDONLY);
-		// 将当前进程加入到指定的 namespace 中
-		if (setns(fd, 0) == -1) {
-			fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
-		} else {
-			fprintf(stdout, "setns on %s namespace succeeded\n", namespaces[i]);
+	// 父进程校验过容器进程后，会通过 fd 传入进程的 pidfd
+	int pidfd = -1;
+	char *dockergsh_pidfd = getenv("dockergsh_pidfd");
+	if (dockergsh_pidfd) {
+		pidfd = atoi(dockergsh_pidfd);
+	}
+
+	// 进入 mnt namespace 之前打开容器的根目录，之后 chroot 到这里
+	char rootpath[1024];
+	sprintf(rootpath, "/proc/%s/root", dockergsh_pid);
+	int rootfd = open(rootpath, O_RDONLY | O_DIRECTORY);
+	if (rootfd == -1) {
+		fprintf(stderr, "open %s failed: %s\n", rootpath, strerror(errno));
+		exit(1);
+	}
+
+	// 容器与宿主机使用同一个 user namespace 时不能再次进入，setns 会返回 EINVAL
+	int join_user = !same_namespace(dockergsh_pid, "user");
+
+	// 优先通过 pidfd 一次进入所有 namespace（Linux 5.8+），不会受 pid 复用影响
+	int entered = 0;
+	if (pidfd >= 0) {
+		int flags = CLONE_NEWIPC | CLONE_NEWUTS | CLONE_NEWNET | CLONE_NEWPID | CLONE_NEWNS | CLONE_NEWCGROUP;
+		if (join_user) {
+			flags |= CLONE_NEWUSER;
+		}
+		if (setns(pidfd, flags) == 0) {
+			entered = 1;
 		}
 	}
+	if (!entered) {
+		setns_by_path(dockergsh_pid, pidfd, join_user);
+	}
+
+	if (fchdir(rootfd) == -1 || chroot(".") == -1 || chdir("/") == -1) {
+		fprintf(stderr, "chroot to container root failed: %s\n", strerror(errno));
+		exit(1);
+	}
+	close(rootfd);
+	if (pidfd >= 0) {
+		close(pidfd);
+	}
 
-	// 将当前的进程加入到 namespace 中后，执行 docker exec 后的命令
-	int res = system(dockergsh_cmd);
-	exit(0);
-	return;
+	// 进入 pid namespace 只对之后创建的子进程生效，fork 出的子进程继续启动 go runtime 执行用户命令
+	pid_t child = fork();
+	if (child == -1) {
+		fprintf(stderr, "fork failed: %s\n", strerror(errno));
+		exit(1);
+	}
+	if (child == 0) {
+		close(EXEC_PID_PIPE_FD);
+		return;
+	}
+	dprintf(EXEC_PID_PIPE_FD, "%d", child);
+	close(EXEC_PID_PIPE_FD);
+
+	// 当前进程只负责等待子进程，并把子进程的退出码原样返回给 dockergsh exec
+	close(EXEC_PIPE_FD);
+	signal(SIGINT, SIG_IGN);
+	signal(SIGQUIT, SIG_IGN);
+	int status;
+	while (waitpid(child, &status, 0) == -1) {
+		if (errno != EINTR) {
+			exit(1);
+		}
+	}
+	if (WIFSIGNALED(status)) {
+		exit(128 + WTERMSIG(status));
+	}
+	exit(WEXITSTATUS(status));
 }
 */
 import "C"
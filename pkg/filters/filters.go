@@ -0,0 +1,64 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Args 命令行中多个 --filter 参数解析后的结果，key 到 value 列表，同一个 key 可以指定多次
+type Args map[string][]string
+
+/*
+Parse 解析 --filter key=value 格式的参数，allowed 为支持的 key
+label 的 value 可以是 k 或者 k=v，只在第一个 = 处分割，例如 label=env=prod 解析为 label: ["env=prod"]
+*/
+func Parse(specs []string, allowed ...string) (Args, error) {
+	args := Args{}
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("bad format of filter %q, expected name=value", spec)
+		}
+		if !contains(allowed, key) {
+			return nil, fmt.Errorf("invalid filter %q, supported filters: %s", key, strings.Join(allowed, ", "))
+		}
+		args[key] = append(args[key], value)
+	}
+	return args, nil
+}
+
+// Get 返回 key 对应的所有 value
+func (args Args) Get(key string) []string {
+	return args[key]
+}
+
+// MatchLabels 判断 labels 是否满足所有 label 过滤条件：label=k 要求存在 k，label=k=v 要求 k 的值为 v
+func (args Args) MatchLabels(labels map[string]string) bool {
+	for _, spec := range args["label"] {
+		key, value, hasValue := strings.Cut(spec, "=")
+		actual, ok := labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match 判断 value 是否等于 key 的某个过滤值，没有指定 key 时总是满足
+func (args Args) Match(key, value string) bool {
+	values, ok := args[key]
+	if !ok {
+		return true
+	}
+	return contains(values, value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package filters
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	args, err := Parse([]string{"label=env", "label=job=ci=42", "status=running"}, "label", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := args.Get("label"); len(got) != 2 || got[0] != "env" || got[1] != "job=ci=42" {
+		t.Errorf("label filters = %q", got)
+	}
+
+	for _, spec := range []string{"label", "label=", "=env", "name=foo"} {
+		if _, err := Parse([]string{spec}, "label", "status"); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"env": "prod", "job": "ci=42", "empty": ""}
+	tests := []struct {
+		filters []string
+		want    bool
+	}{
+		{nil, true},
+		{[]string{"label=env"}, true},
+		{[]string{"label=env=prod"}, true},
+		{[]string{"label=env=dev"}, false},
+		{[]string{"label=job=ci=42"}, true},
+		{[]string{"label=empty"}, true},
+		{[]string{"label=empty="}, true},
+		{[]string{"label=missing"}, false},
+		{[]string{"label=env", "label=job=ci=42"}, true},
+		{[]string{"label=env", "label=missing"}, false},
+	}
+	for _, test := range tests {
+		args, err := Parse(test.filters, "label")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := args.MatchLabels(labels); got != test.want {
+			t.Errorf("MatchLabels(%q) = %v, want %v", test.filters, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pidfd
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+pidfd 是指向进程的文件描述符（Linux 5.3+）：
+与 pid 不同，进程退出后 pidfd 不会指向别的进程，通过 pidfd 发送信号、进入 namespace 不会因为 pid 复用而误操作无关进程
+*/
+
+// Open 调用 pidfd_open 打开进程，内核不支持时返回 ENOSYS
+func Open(pid int) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// SendSignal 调用 pidfd_send_signal 向进程发送信号
+func SendSignal(fd int, sig syscall.Signal) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PIDFD_SEND_SIGNAL, uintptr(fd), uintptr(sig), uintptr(unsafe.Pointer(nil)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Exited 进程退出后 pidfd 变为可读，不需要是进程的父进程也可以判断
+func Exited(fd int) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, 0)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// IsNotSupported 判断错误是否是因为内核不支持 pidfd
+func IsNotSupported(err error) bool {
+	return err == unix.ENOSYS
+}
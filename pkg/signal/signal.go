@@ -0,0 +1,89 @@
+package signal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	sigrtmin = 34
+	sigrtmax = 64
+)
+
+// SignalMap 信号名到信号的映射，信号名不带 SIG 前缀，与 docker 保持一致
+var SignalMap = map[string]syscall.Signal{
+	"ABRT":   syscall.SIGABRT,
+	"ALRM":   syscall.SIGALRM,
+	"BUS":    syscall.SIGBUS,
+	"CHLD":   syscall.SIGCHLD,
+	"CLD":    syscall.SIGCLD,
+	"CONT":   syscall.SIGCONT,
+	"FPE":    syscall.SIGFPE,
+	"HUP":    syscall.SIGHUP,
+	"ILL":    syscall.SIGILL,
+	"INT":    syscall.SIGINT,
+	"IO":     syscall.SIGIO,
+	"IOT":    syscall.SIGIOT,
+	"KILL":   syscall.SIGKILL,
+	"PIPE":   syscall.SIGPIPE,
+	"POLL":   syscall.SIGPOLL,
+	"PROF":   syscall.SIGPROF,
+	"PWR":    syscall.SIGPWR,
+	"QUIT":   syscall.SIGQUIT,
+	"SEGV":   syscall.SIGSEGV,
+	"STKFLT": syscall.SIGSTKFLT,
+	"STOP":   syscall.SIGSTOP,
+	"SYS":    syscall.SIGSYS,
+	"TERM":   syscall.SIGTERM,
+	"TRAP":   syscall.SIGTRAP,
+	"TSTP":   syscall.SIGTSTP,
+	"TTIN":   syscall.SIGTTIN,
+	"TTOU":   syscall.SIGTTOU,
+	"URG":    syscall.SIGURG,
+	"USR1":   syscall.SIGUSR1,
+	"USR2":   syscall.SIGUSR2,
+	"VTALRM": syscall.SIGVTALRM,
+	"WINCH":  syscall.SIGWINCH,
+	"XCPU":   syscall.SIGXCPU,
+	"XFSZ":   syscall.SIGXFSZ,
+	"RTMIN":  sigrtmin,
+	"RTMAX":  sigrtmax,
+}
+
+/*
+ParseSignal 将用户输入的信号转换成 syscall.Signal，支持以下格式：
+- 数字，例如 9、15
+- 带或不带 SIG 前缀的信号名，大小写不敏感，例如 SIGTERM、term
+- 实时信号，例如 RTMIN+3、SIGRTMAX-1
+*/
+func ParseSignal(rawSignal string) (syscall.Signal, error) {
+	if s, err := strconv.Atoi(rawSignal); err == nil {
+		if s <= 0 || s > sigrtmax {
+			return -1, fmt.Errorf("invalid signal: %s", rawSignal)
+		}
+		return syscall.Signal(s), nil
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(rawSignal), "SIG")
+	if sig, ok := SignalMap[name]; ok {
+		return sig, nil
+	}
+
+	// RTMIN+n 和 RTMAX-n
+	for base, op := range map[string]string{"RTMIN": "+", "RTMAX": "-"} {
+		if !strings.HasPrefix(name, base+op) {
+			continue
+		}
+		offset, err := strconv.Atoi(strings.TrimPrefix(name, base+op))
+		if err != nil || offset < 0 || offset > sigrtmax-sigrtmin {
+			break
+		}
+		if op == "+" {
+			return syscall.Signal(sigrtmin + offset), nil
+		}
+		return syscall.Signal(sigrtmax - offset), nil
+	}
+	return -1, fmt.Errorf("invalid signal: %s", rawSignal)
+}
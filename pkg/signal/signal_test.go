@@ -0,0 +1,57 @@
+package signal
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    syscall.Signal
+		wantErr bool
+	}{
+		{raw: "SIGTERM", want: syscall.SIGTERM},
+		{raw: "TERM", want: syscall.SIGTERM},
+		{raw: "term", want: syscall.SIGTERM},
+		{raw: "SigKill", want: syscall.SIGKILL},
+		{raw: "15", want: syscall.SIGTERM},
+		{raw: "9", want: syscall.SIGKILL},
+		{raw: "64", want: 64},
+		{raw: "RTMIN", want: 34},
+		{raw: "RTMIN+3", want: 37},
+		{raw: "SIGRTMIN+0", want: 34},
+		{raw: "RTMAX", want: 64},
+		{raw: "RTMAX-1", want: 63},
+		{raw: "sigrtmax-30", want: 34},
+		// 超出范围
+		{raw: "0", wantErr: true},
+		{raw: "-1", wantErr: true},
+		{raw: "65", wantErr: true},
+		{raw: "RTMIN+31", wantErr: true},
+		{raw: "RTMAX-31", wantErr: true},
+		{raw: "RTMIN-1", wantErr: true},
+		{raw: "RTMAX+1", wantErr: true},
+		{raw: "RTMIN+-1", wantErr: true},
+		{raw: "RTMIN+x", wantErr: true},
+		// 不认识的信号
+		{raw: "", wantErr: true},
+		{raw: "SIG", wantErr: true},
+		{raw: "FOO", wantErr: true},
+		{raw: "SIGTERM2", wantErr: true},
+		{raw: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		sig, err := ParseSignal(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSignal(%q) = %d, want error", tt.raw, sig)
+			}
+			continue
+		}
+		if err != nil || sig != tt.want {
+			t.Errorf("ParseSignal(%q) = %d, %v, want %d", tt.raw, sig, err, tt.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package sysinfo
 
 import (
-	"github.com/Nevermore12321/dockergsh/external/libcontainer/cgroups"
+	"github.com/Nevermore12321/dockergsh/cgroup"
+	subSysV1 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v1"
+	subSysV2 "github.com/Nevermore12321/dockergsh/cgroup/subsystem/v2"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"path/filepath"
@@ -16,43 +19,32 @@ const (
 type SysInfo struct {
 	MemoryLimit            bool // 容器的内存限制功能
 	SwapLimit              bool // 交换区内存限制功能
+	MemoryReservation      bool // 内存软限制功能
+	OomKillDisable         bool // 禁止 OOM killer 功能，只有 cgroup v1 支持
+	OomKillGroup           bool // OOM 时杀死 cgroup 中所有进程的功能，只有 cgroup v2 支持
+	PidsLimit              bool // 进程数限制功能
+	BlkioWeight            bool // 块设备 IO 权重功能
+	BlkioThrottle          bool // 块设备读写限速功能
+	HugetlbLimit           bool // 大页内存限制功能
 	IPv4ForwardingDisabled bool // 数据转发功能
 	AppArmor               bool // AppArmor安全功能
 }
 
 func New(quiet bool) *SysInfo {
 	sysInfo := &SysInfo{}
-	if cgroupMemoryMountPoint, err := cgroups.GetCgroupPath("memory"); err != nil {
-		if !quiet {
-			log.Warnf("WARNING: Failed to find memory mount point: %s", err)
-		}
+	if cgroup.IsUnified() {
+		checkCgroupV2(sysInfo)
 	} else {
-		// check MemoryLimit and SwapLimit
-		var err1, err2, err3 error
-		switch cgroups.Version {
-		case "CgroupV1":
-			_, err1 = os.ReadFile(filepath.Join(cgroupMemoryMountPoint, "memory.limit_in_bytes"))
-			_, err2 = os.ReadFile(filepath.Join(cgroupMemoryMountPoint, "memory.soft_limit_in_bytes"))
-			_, err3 = os.ReadFile(filepath.Join(cgroupMemoryMountPoint, "memory.memsw.limit_in_bytes"))
-		case "CgroupV2":
-			cgroupBasePath, err := cgroups.GetCgroupPath("")
-			if err != nil {
-				log.Warnf("WARNING: Failed to find cgroup base path: %s", err)
-				break
-			}
-			_, err1 = os.ReadFile(filepath.Join(cgroupBasePath, "memory.max"))
-			_, err2 = os.ReadFile(filepath.Join(cgroupBasePath, "memory.low"))
-			_, err3 = os.ReadFile(filepath.Join(cgroupBasePath, "memory.swap.max"))
-		}
-		sysInfo.MemoryLimit = err1 == nil && err2 == nil
-		if !sysInfo.MemoryLimit && !quiet {
-			log.Infof("WARNING: Your kernel does not support cgroup memory limit.")
-		}
-
-		sysInfo.SwapLimit = err3 == nil
-		if !sysInfo.SwapLimit && !quiet {
-			log.Infof("WARNING: Your kernel does not support cgroup swap limit.")
-		}
+		checkCgroupV1(sysInfo, quiet)
+	}
+	if !sysInfo.MemoryLimit && !quiet {
+		log.Infof("WARNING: Your kernel does not support cgroup memory limit.")
+	}
+	if !sysInfo.SwapLimit && !quiet {
+		log.Infof("WARNING: Your kernel does not support cgroup swap limit.")
+	}
+	if !sysInfo.PidsLimit && !quiet {
+		log.Infof("WARNING: Your kernel does not support cgroup pids limit.")
 	}
 
 	// Check AppArmor
@@ -69,3 +61,55 @@ func New(quiet bool) *SysInfo {
 
 	return sysInfo
 }
+
+// cgroup v1 中每个 subsystem 单独挂载，通过挂载目录中的配置文件判断是否支持
+func checkCgroupV1(sysInfo *SysInfo, quiet bool) {
+	if cgroupMemoryMountPoint := subSysV1.FindCgroupMountPoint("memory"); cgroupMemoryMountPoint == "" {
+		if !quiet {
+			log.Warnf("WARNING: Failed to find memory mount point")
+		}
+	} else {
+		// check MemoryLimit and SwapLimit
+		_, err1 := os.Stat(filepath.Join(cgroupMemoryMountPoint, "memory.limit_in_bytes"))
+		_, err2 := os.Stat(filepath.Join(cgroupMemoryMountPoint, "memory.soft_limit_in_bytes"))
+		_, err3 := os.Stat(filepath.Join(cgroupMemoryMountPoint, "memory.memsw.limit_in_bytes"))
+		_, err4 := os.Stat(filepath.Join(cgroupMemoryMountPoint, "memory.oom_control"))
+		sysInfo.MemoryLimit = err1 == nil && err2 == nil
+		sysInfo.SwapLimit = err3 == nil
+		sysInfo.MemoryReservation = err2 == nil
+		sysInfo.OomKillDisable = err4 == nil
+	}
+
+	// check pids, blkio and hugetlb
+	sysInfo.PidsLimit = subSysV1.FindCgroupMountPoint("pids") != ""
+	if blkioMountPoint := subSysV1.FindCgroupMountPoint("blkio"); blkioMountPoint != "" {
+		// 使用 bfq 调度器的内核只有 blkio.bfq.weight，并且根 cgroup 中没有这个文件，通过 bfq 的统计文件判断
+		_, err1 := os.Stat(filepath.Join(blkioMountPoint, "blkio.weight"))
+		_, err2 := os.Stat(filepath.Join(blkioMountPoint, "blkio.bfq.io_service_bytes"))
+		_, err3 := os.Stat(filepath.Join(blkioMountPoint, "blkio.throttle.read_bps_device"))
+		sysInfo.BlkioWeight = err1 == nil || err2 == nil
+		sysInfo.BlkioThrottle = err3 == nil
+	}
+	sysInfo.HugetlbLimit = subSysV1.FindCgroupMountPoint("hugetlb") != ""
+}
+
+// cgroup v2 中可以使用的控制器都列在根 cgroup 的 cgroup.controllers 中，根 cgroup 中没有各个控制器的配置文件
+func checkCgroupV2(sysInfo *SysInfo) {
+	controllers, _ := os.ReadFile(filepath.Join(subSysV2.CgroupRoot, "cgroup.controllers"))
+	for _, controller := range strings.Fields(string(controllers)) {
+		switch controller {
+		case "memory":
+			sysInfo.MemoryLimit = true
+			sysInfo.SwapLimit = true
+			sysInfo.MemoryReservation = true
+			sysInfo.OomKillGroup = true
+		case "pids":
+			sysInfo.PidsLimit = true
+		case "io":
+			sysInfo.BlkioWeight = true
+			sysInfo.BlkioThrottle = true
+		case "hugetlb":
+			sysInfo.HugetlbLimit = true
+		}
+	}
+}
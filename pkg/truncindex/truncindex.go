@@ -11,6 +11,7 @@ import (
 var (
 	ErrNoId              = errors.New("prefix can't be empty")
 	ErrIdHasSpaceIllegal = errors.New("illegal character: ' '")
+	ErrAmbiguousPrefix   = errors.New("trie found two entries") // 前缀匹配到了多个 id
 )
 
 func init() {
@@ -111,7 +112,7 @@ func (idx *TruncIndex) Get(s string) (string, error) {
 	subTreeVisitFunc := func(prefix patricia.Prefix, item patricia.Item) error {
 		if id != "" { // 说明已经找到了一个与 s 相同的
 			id = ""
-			return fmt.Errorf("trie found two entries")
+			return ErrAmbiguousPrefix
 		}
 		id = string(prefix)
 		return nil
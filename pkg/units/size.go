@@ -0,0 +1,48 @@
+package units
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var binaryAbbrs = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// 数字加上可选的单位，例如 512m、1.5g、100KiB
+var sizeRegex = regexp.MustCompile(`^(\d+(\.\d+)?) *([kKmMgGtTpP]?)(i?[bB])?$`)
+
+var binaryMap = map[string]int64{
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// BytesSize 将字节数转换成便于阅读的二进制单位，例如 BytesSize(1536) = "1.5KiB"
+func BytesSize(size float64) string {
+	i := 0
+	for size >= 1024 && i < len(binaryAbbrs)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.4g%s", size, binaryAbbrs[i])
+}
+
+// RAMInBytes 将内存大小转换成字节数，单位按 1024 进制计算，例如 RAMInBytes("512m") = 536870912
+func RAMInBytes(size string) (int64, error) {
+	matches := sizeRegex.FindStringSubmatch(strings.TrimSpace(size))
+	if len(matches) != 5 {
+		return -1, fmt.Errorf("invalid size: '%s'", size)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return -1, fmt.Errorf("invalid size: '%s'", size)
+	}
+	if unit := strings.ToLower(matches[3]); unit != "" {
+		value *= float64(binaryMap[unit])
+	}
+	return int64(value), nil
+}
@@ -0,0 +1,78 @@
+package units
+
+import "testing"
+
+func TestRAMInBytes(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "0", want: 0},
+		{size: "32", want: 32},
+		{size: "32b", want: 32},
+		{size: "32B", want: 32},
+		{size: "32k", want: 32 << 10},
+		{size: "32K", want: 32 << 10},
+		{size: "32kb", want: 32 << 10},
+		{size: "32KiB", want: 32 << 10},
+		{size: "512m", want: 512 << 20},
+		{size: "512MB", want: 512 << 20},
+		{size: "1g", want: 1 << 30},
+		{size: "1GiB", want: 1 << 30},
+		{size: "2t", want: 2 << 40},
+		{size: "1p", want: 1 << 50},
+		{size: "  64m  ", want: 64 << 20},
+		{size: "10 MB", want: 10 << 20},
+		// 小数
+		{size: "1.5g", want: 3 << 29},
+		{size: "0.5k", want: 512},
+		{size: "1.25KiB", want: 1280},
+		// 负数
+		{size: "-1", wantErr: true},
+		{size: "-1m", wantErr: true},
+		// 不合法的输入
+		{size: "", wantErr: true},
+		{size: "m", wantErr: true},
+		{size: "abc", wantErr: true},
+		{size: "1x", wantErr: true},
+		{size: "1mm", wantErr: true},
+		{size: "1.m", wantErr: true},
+		{size: ".5m", wantErr: true},
+		{size: "1e3", wantErr: true},
+		{size: "1IB", wantErr: true},
+		{size: "1m1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := RAMInBytes(tt.size)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("RAMInBytes(%q) = %d, want error", tt.size, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("RAMInBytes(%q) = %d, %v, want %d", tt.size, got, err, tt.want)
+		}
+	}
+}
+
+func TestBytesSize(t *testing.T) {
+	tests := []struct {
+		size float64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KiB"},
+		{64 << 20, "64MiB"},
+		{1 << 60, "1EiB"},
+	}
+
+	for _, tt := range tests {
+		if got := BytesSize(tt.size); got != tt.want {
+			t.Errorf("BytesSize(%v) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
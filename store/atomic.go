@@ -0,0 +1,52 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+原子地写入文件：
+1. 在同一个目录下写临时文件，并 fsync 到磁盘
+2. rename 覆盖原文件，读者看到的要么是旧文件，要么是完整的新文件
+3. fsync 目录，保证宿主机掉电后 rename 不会丢失
+*/
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0644); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// fsync 目录，使目录中的 rename、创建和删除持久化
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
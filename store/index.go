@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// 全局索引文件，记录所有容器的 id 和名字
+	indexName = "index.json"
+	// 修改索引时加的锁，容器名的唯一性由这把锁保证
+	indexLockName = "index.lock"
+)
+
+// 当前进程是否在 WithIndexLock 中持有索引的排他锁
+var indexLockHeld bool
+
+// 容器的全局索引，替代之前 named_containers 目录下的软链接
+type index struct {
+	Version    int               `json:"version"`
+	Containers map[string]string `json:"containers"` // 容器 id -> 容器名
+}
+
+// 根据容器名查找容器 id
+func (idx *index) lookupName(name string) (string, bool) {
+	for id, containerName := range idx.Containers {
+		if containerName == name {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+/*
+加锁后读取索引并调用 fn，fn 返回 true 时把修改后的索引写回
+how 为 syscall.LOCK_SH 或者 syscall.LOCK_EX，只有 LOCK_EX 时才能修改索引
+索引不存在时（第一次使用或者从旧版本升级），加 LOCK_EX 从容器目录重建索引
+*/
+func withIndex(how int, fn func(idx *index) (bool, error)) error {
+	if err := os.MkdirAll(Root, 0755); err != nil {
+		return err
+	}
+	var lock *fileLock
+	if indexLockHeld {
+		// 在 WithIndexLock 中调用，已经持有排他锁，再次 flock 会等待自己释放
+		how = syscall.LOCK_EX
+	} else {
+		var err error
+		if lock, err = lockFile(filepath.Join(Root, indexLockName), how); err != nil {
+			return err
+		}
+		defer func() { lock.unlock() }()
+	}
+
+	idx, err := readIndex()
+	if os.IsNotExist(err) {
+		// 共享锁下不能重建索引，升级为排他锁后重新读取，其他进程可能已经重建过了
+		if how != syscall.LOCK_EX {
+			lock.unlock()
+			if lock, err = lockFile(filepath.Join(Root, indexLockName), syscall.LOCK_EX); err != nil {
+				return err
+			}
+			how = syscall.LOCK_EX
+			idx, err = readIndex()
+		}
+		if os.IsNotExist(err) {
+			idx, err = rebuildIndex()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	dirty, err := fn(idx)
+	if err != nil || !dirty {
+		return err
+	}
+	if how != syscall.LOCK_EX {
+		return fmt.Errorf("modify container index without exclusive lock")
+	}
+	return writeIndex(idx)
+}
+
+/*
+WithIndexLock 持有索引的排他锁执行 fn，期间其他进程不能创建、重命名和删除容器
+fn 中可以继续调用 store 的函数，不会重复加锁，但是其他 goroutine 不能同时使用 store
+*/
+func WithIndexLock(fn func() error) error {
+	if err := os.MkdirAll(Root, 0755); err != nil {
+		return err
+	}
+	lock, err := lockFile(filepath.Join(Root, indexLockName), syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	indexLockHeld = true
+	defer func() {
+		indexLockHeld = false
+		lock.unlock()
+	}()
+	return fn()
+}
+
+func readIndex() (*index, error) {
+	content, err := os.ReadFile(filepath.Join(Root, indexName))
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, err
+	}
+	if idx.Containers == nil {
+		idx.Containers = make(map[string]string)
+	}
+	return idx, nil
+}
+
+func writeIndex(idx *index) error {
+	idx.Version = SchemaVersion
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(Root, indexName), content)
+}
+
+/*
+从容器目录重建索引，调用方需要持有索引的排他锁：
+1. 遍历 /var/lib/dockergsh 下所有包含 container/config.json 的目录，旧版本的记录同时升级到当前版本
+2. 写入索引后删除旧版本的 named_containers 软链接目录
+*/
+func rebuildIndex() (*index, error) {
+	idx := &index{Containers: make(map[string]string)}
+	entries, err := os.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		configURL := filepath.Join(Root, entry.Name(), container.ContainerConfigPath, container.ConfigName)
+		if _, err := os.Stat(configURL); err != nil {
+			continue
+		}
+		info, err := readFile(configURL)
+		if err != nil {
+			log.Warnf("Read container config %s error %v", configURL, err)
+			continue
+		}
+		if _, exists := idx.lookupName(info.Name); exists {
+			// 旧版本没有保证容器名唯一，重名的容器使用 id 作为名字
+			log.Warnf("Container name %s is duplicated, rename container %s to its id", info.Name, info.Id)
+			info.Name = info.Id
+		}
+		if _, err := Update(info.Id, func(current *container.ContainerInfo) error {
+			current.Name = info.Name
+			return nil
+		}); err != nil {
+			log.Warnf("Migrate container %s error %v", info.Id, err)
+			continue
+		}
+		idx.Containers[info.Id] = info.Name
+	}
+	if err := writeIndex(idx); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(filepath.Join(Root, container.NamedContainersDir)); err != nil {
+		log.Warnf("Remove legacy %s error %v", container.NamedContainersDir, err)
+	}
+	return idx, nil
+}
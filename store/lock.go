@@ -0,0 +1,36 @@
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// 基于 flock 的文件锁，进程退出时内核自动释放，不会因为命令异常退出留下死锁
+type fileLock struct {
+	file *os.File
+}
+
+// 对 path 加锁，how 为 syscall.LOCK_SH 或者 syscall.LOCK_EX，锁文件不存在时创建
+func lockFile(path string, how int) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileLock{file: file}, nil
+}
+
+// 释放锁
+func (l *fileLock) unlock() {
+	_ = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
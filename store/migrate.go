@@ -0,0 +1,62 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/units"
+)
+
+// SchemaVersion 当前容器记录的版本，没有 schema_version 字段的旧记录为 0
+const SchemaVersion = 3
+
+// migrations[i] 把版本 i 的容器记录升级到版本 i+1
+var migrations = []func(info *container.ContainerInfo){
+	migrateV0,
+	migrateV1,
+	migrateV2,
+}
+
+/*
+版本 0 到 1：
+1. create/start 拆分之前的记录没有 args，使用 command 按空格拆分
+2. 没有指定 --name 的容器以 id 作为名字
+*/
+func migrateV0(info *container.ContainerInfo) {
+	if len(info.Args) == 0 && info.Command != "" {
+		info.Args = strings.Fields(info.Command)
+	}
+	if info.Name == "" {
+		info.Name = info.Id
+	}
+}
+
+// 版本 1 到 2：/dev/shm 的大小改为记录字节数，旧记录中是 --shm-size 的原始值，不合法的按默认大小处理
+func migrateV1(info *container.ContainerInfo) {
+	if info.LegacyShmSize == "" {
+		return
+	}
+	if shmSize, err := units.RAMInBytes(info.LegacyShmSize); err == nil {
+		info.ShmSize = shmSize
+	}
+	info.LegacyShmSize = ""
+}
+
+// 版本 2 到 3：设备白名单之前的记录没有 device_rules，重新启动时 cgroup 没有白名单，按照默认规则和 --device 生成
+func migrateV2(info *container.ContainerInfo) {
+	if len(info.DeviceRules) == 0 {
+		info.DeviceRules = container.DeviceRules(info.Devices)
+	}
+}
+
+// 依次执行迁移，把容器记录升级到当前版本，返回记录是否被修改
+func migrate(info *container.ContainerInfo) bool {
+	if info.SchemaVersion >= SchemaVersion {
+		return false
+	}
+	for version := info.SchemaVersion; version < SchemaVersion; version++ {
+		migrations[version](info)
+	}
+	info.SchemaVersion = SchemaVersion
+	return true
+}
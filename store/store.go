@@ -0,0 +1,272 @@
+/*
+Package store 保存容器的状态，所有命令都通过 store 读写容器记录：
+1. 每个容器的记录保存在 /var/lib/dockergsh/<sha256(id)>/container/config.json，读写时对同目录下的 config.lock 加 flock
+2. 写入时先写临时文件并 fsync，再 rename 覆盖，命令异常退出或者宿主机掉电时不会留下写了一半的记录
+3. 记录中带有 schema_version，读取旧版本的记录时依次执行迁移
+4. 全局索引 index.json 记录所有容器的 id 和名字，创建、重命名和删除容器时加 index.lock 排他锁，保证容器名唯一
+*/
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/pkg/truncindex"
+	"github.com/Nevermore12321/dockergsh/utils"
+)
+
+// 容器记录加锁使用的锁文件
+const lockName = "config.lock"
+
+// Root 容器状态的根目录
+var Root = container.DefaultFsURL
+
+// ErrNotExist 容器不存在或者已经被删除
+var ErrNotExist = errors.New("no such container")
+
+// 容器记录所在的目录 /var/lib/dockergsh/<sha256(id)>/container
+func configDir(id string) string {
+	return filepath.Join(Root, utils.EncodeSha256([]byte(id)), container.ContainerConfigPath)
+}
+
+// 对容器记录加锁，容器目录已经被删除时返回 ErrNotExist
+func lockContainer(id string, how int) (*fileLock, error) {
+	lock, err := lockFile(filepath.Join(configDir(id), lockName), how)
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return lock, err
+}
+
+// 读取容器记录，并迁移到当前版本
+func readFile(configURL string) (*container.ContainerInfo, error) {
+	content, err := os.ReadFile(configURL)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	info := &container.ContainerInfo{}
+	if err := json.Unmarshal(content, info); err != nil {
+		return nil, fmt.Errorf("unmarshal %s error %v", configURL, err)
+	}
+	migrate(info)
+	return info, nil
+}
+
+// 写入容器记录，调用方需要持有容器记录的排他锁
+func writeFile(info *container.ContainerInfo) error {
+	info.SchemaVersion = SchemaVersion
+	content, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(configDir(info.Id), container.ConfigName), content)
+}
+
+// Get 加共享锁读取容器记录
+func Get(id string) (*container.ContainerInfo, error) {
+	lock, err := lockContainer(id, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+	return readFile(filepath.Join(configDir(id), container.ConfigName))
+}
+
+// Save 加排他锁整体写入容器记录，只用于调用方独占容器的场景，例如 monitor 进程启动容器
+func Save(info *container.ContainerInfo) error {
+	lock, err := lockContainer(info.Id, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+	if _, err := os.Stat(filepath.Join(configDir(info.Id), container.ConfigName)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotExist
+		}
+		return err
+	}
+	return writeFile(info)
+}
+
+/*
+Update 加排他锁读取最新的容器记录，调用 fn 修改后写回
+fn 返回错误时不写回，多个命令同时修改同一个容器时不会互相覆盖
+*/
+func Update(id string, fn func(info *container.ContainerInfo) error) (*container.ContainerInfo, error) {
+	lock, err := lockContainer(id, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	info, err := readFile(filepath.Join(configDir(id), container.ConfigName))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(info); err != nil {
+		return nil, err
+	}
+	if err := writeFile(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Create 写入新容器的记录并加入索引，容器名已经被使用时返回错误
+func Create(info *container.ContainerInfo) error {
+	return withIndex(syscall.LOCK_EX, func(idx *index) (bool, error) {
+		if _, exists := idx.lookupName(info.Name); exists {
+			return false, fmt.Errorf("the container name %q is already in use", info.Name)
+		}
+		if err := os.MkdirAll(configDir(info.Id), 0755); err != nil {
+			return false, err
+		}
+		lock, err := lockContainer(info.Id, syscall.LOCK_EX)
+		if err != nil {
+			return false, err
+		}
+		defer lock.unlock()
+		if err := writeFile(info); err != nil {
+			return false, err
+		}
+		idx.Containers[info.Id] = info.Name
+		return true, nil
+	})
+}
+
+// Delete 从索引中删除容器，并删除容器记录所在的目录
+func Delete(id string) error {
+	return withIndex(syscall.LOCK_EX, func(idx *index) (bool, error) {
+		if err := os.RemoveAll(configDir(id)); err != nil {
+			return false, err
+		}
+		if _, exists := idx.Containers[id]; !exists {
+			return false, nil
+		}
+		delete(idx.Containers, id)
+		return true, nil
+	})
+}
+
+// Rename 修改容器名，索引和容器记录在索引的排他锁下一起修改
+func Rename(id, newName string) error {
+	return withIndex(syscall.LOCK_EX, func(idx *index) (bool, error) {
+		if _, exists := idx.Containers[id]; !exists {
+			return false, ErrNotExist
+		}
+		if _, exists := idx.lookupName(newName); exists {
+			return false, fmt.Errorf("the container name %q is already in use", newName)
+		}
+		if _, err := Update(id, func(info *container.ContainerInfo) error {
+			info.Name = newName
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		idx.Containers[id] = newName
+		return true, nil
+	})
+}
+
+// NameInUse 容器名是否已经被使用
+func NameInUse(name string) (bool, error) {
+	var exists bool
+	err := withIndex(syscall.LOCK_SH, func(idx *index) (bool, error) {
+		_, exists = idx.lookupName(name)
+		return false, nil
+	})
+	return exists, err
+}
+
+/*
+Resolve 把用户输入的参数解析为容器 id，与 docker 一致，依次按照以下顺序查找：
+1. 完整的容器 id
+2. 容器名
+3. 唯一的 id 前缀，匹配到多个容器时返回错误
+*/
+func Resolve(arg string) (string, error) {
+	var id string
+	err := withIndex(syscall.LOCK_SH, func(idx *index) (bool, error) {
+		if _, exists := idx.Containers[arg]; exists {
+			id = arg
+			return false, nil
+		}
+		if nameId, exists := idx.lookupName(arg); exists {
+			id = nameId
+			return false, nil
+		}
+		ids := make([]string, 0, len(idx.Containers))
+		for containerId := range idx.Containers {
+			ids = append(ids, containerId)
+		}
+		prefixId, err := truncindex.NewTruncIndex(ids).Get(arg)
+		if err != nil {
+			if errors.Is(err, truncindex.ErrAmbiguousPrefix) {
+				return false, fmt.Errorf("multiple containers match id prefix %s", arg)
+			}
+			return false, ErrNotExist
+		}
+		id = prefixId
+		return false, nil
+	})
+	return id, err
+}
+
+// Ids 返回索引中所有容器的 id 和名字
+func Ids() (map[string]string, error) {
+	containers := make(map[string]string)
+	err := withIndex(syscall.LOCK_SH, func(idx *index) (bool, error) {
+		for id, name := range idx.Containers {
+			containers[id] = name
+		}
+		return false, nil
+	})
+	return containers, err
+}
+
+// RemoveIndexEntry 从索引中删除容器，用于清理容器目录已经不存在的索引项
+func RemoveIndexEntry(id string) error {
+	return withIndex(syscall.LOCK_EX, func(idx *index) (bool, error) {
+		if _, exists := idx.Containers[id]; !exists {
+			return false, nil
+		}
+		delete(idx.Containers, id)
+		return true, nil
+	})
+}
+
+// List 读取所有容器的记录，按照创建时间排序
+func List() ([]*container.ContainerInfo, error) {
+	ids, err := Ids()
+	if err != nil {
+		return nil, err
+	}
+	var containers []*container.ContainerInfo
+	for id := range ids {
+		info, err := Get(id)
+		if err != nil {
+			// 容器在读取索引之后被删除
+			if errors.Is(err, ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		containers = append(containers, info)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].CreateTime != containers[j].CreateTime {
+			return containers[i].CreateTime < containers[j].CreateTime
+		}
+		return containers[i].Id < containers[j].Id
+	})
+	return containers, nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Nevermore12321/dockergsh/cgroup/subsystem"
+	"github.com/Nevermore12321/dockergsh/container"
+	"github.com/Nevermore12321/dockergsh/utils"
+)
+
+func useTempRoot(t *testing.T) {
+	oldRoot := Root
+	Root = t.TempDir()
+	t.Cleanup(func() { Root = oldRoot })
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	useTempRoot(t)
+	if err := Create(&container.ContainerInfo{Id: "abc123", Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个 Update 都在锁中读取最新的记录，并发修改不会丢失
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Update("abc123", func(info *container.ContainerInfo) error {
+				info.Restarts++
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	info, err := Get("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Restarts != workers {
+		t.Fatalf("expected %d restarts, got %d", workers, info.Restarts)
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	useTempRoot(t)
+	if err := Create(&container.ContainerInfo{Id: "abc123", Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(&container.ContainerInfo{Id: "abd456", Name: "web"}); err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+	if err := Create(&container.ContainerInfo{Id: "abd456", Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename("abd456", "web"); err == nil {
+		t.Fatal("expected rename to a used name to be rejected")
+	}
+	if err := Rename("abd456", "cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arg     string
+		id      string
+		wantErr bool
+	}{
+		{"abc123", "abc123", false},
+		{"web", "abc123", false},
+		{"cache", "abd456", false},
+		{"abd", "abd456", false},
+		{"ab", "", true},
+		{"db", "", true},
+	}
+	for _, test := range tests {
+		id, err := Resolve(test.arg)
+		if (err != nil) != test.wantErr || id != test.id {
+			t.Errorf("Resolve(%q) = %q, %v", test.arg, id, err)
+		}
+	}
+
+	if err := Delete("abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("web"); err != ErrNotExist {
+		t.Fatalf("expected ErrNotExist after delete, got %v", err)
+	}
+}
+
+func TestMigrateLegacyLayout(t *testing.T) {
+	useTempRoot(t)
+	// 旧版本的记录：没有 schema_version 和 args，容器名通过 named_containers 软链接查找
+	legacy := map[string]interface{}{"id": "abc123", "name": "web", "command": "sleep 100", "status": "exited", "shm_size": "128m"}
+	content, _ := json.Marshal(legacy)
+	dir := filepath.Join(Root, utils.EncodeSha256([]byte("abc123")), container.ContainerConfigPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, container.ConfigName), content, 0622); err != nil {
+		t.Fatal(err)
+	}
+	namedDir := filepath.Join(Root, container.NamedContainersDir)
+	if err := os.MkdirAll(namedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(namedDir, "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := Resolve("web")
+	if err != nil || id != "abc123" {
+		t.Fatalf("Resolve(web) = %q, %v", id, err)
+	}
+	info, err := Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SchemaVersion != SchemaVersion || len(info.Args) != 2 || info.Args[0] != "sleep" || info.ShmSize != 128<<20 || info.LegacyShmSize != "" {
+		t.Fatalf("record is not migrated: %+v", info)
+	}
+	// 旧记录没有设备白名单，按照默认规则补上
+	if len(info.DeviceRules) != len(subsystem.DefaultDeviceRules) {
+		t.Fatalf("device rules = %v, want default rules", info.DeviceRules)
+	}
+	if _, err := os.Stat(namedDir); !os.IsNotExist(err) {
+		t.Fatalf("legacy %s should be removed", container.NamedContainersDir)
+	}
+}
+
+func TestWithIndexLock(t *testing.T) {
+	useTempRoot(t)
+	err := WithIndexLock(func() error {
+		// 其他进程不能拿到索引锁
+		lock, err := lockFile(filepath.Join(Root, indexLockName), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			lock.unlock()
+			t.Errorf("index lock is not held")
+		}
+		// 持有锁时调用 store 的函数不会死锁
+		if err := Create(&container.ContainerInfo{Id: "abc123", Name: "web"}); err != nil {
+			return err
+		}
+		if err := Rename("abc123", "db"); err != nil {
+			return err
+		}
+		ids, err := Ids()
+		if err != nil {
+			return err
+		}
+		if ids["abc123"] != "db" {
+			t.Errorf("ids = %v, want abc123 named db", ids)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexLockHeld {
+		t.Errorf("index lock is still held")
+	}
+}
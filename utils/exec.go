@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -45,6 +47,21 @@ func EncodeSha256(b []byte) string {
 	return hashCode
 }
 
+/*
+判断 s 是否是 EncodeSha256 的结果，也就是 64 位小写的十六进制字符串
+*/
+func IsSha256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 /*
 判断文件夹是否存在
 */
@@ -58,3 +75,22 @@ func PathExists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+/*
+判断 path 是否是挂载点，也就是 /proc/self/mountinfo 中第五个字段为 path 的挂载
+重复卸载时用来跳过已经卸载的挂载点
+*/
+func IsMounted(path string) bool {
+	content, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	path = filepath.Clean(path)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == path {
+			return true
+		}
+	}
+	return false
+}
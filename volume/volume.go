@@ -0,0 +1,195 @@
+package volume
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// 每个 volume 目录下的配置文件
+	configName = "config.json"
+	// volume 中实际挂载到容器的数据目录
+	dataDir = "_data"
+)
+
+// DefaultVolumeRoot 所有命名 volume 的根目录
+var DefaultVolumeRoot = "/var/lib/dockergsh/volumes"
+
+// 与 docker 一致，volume 名只能包含字母、数字和 _.-
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// Volume 命名 volume 的信息，保存在 /var/lib/dockergsh/volumes/<name>/config.json
+type Volume struct {
+	Name       string            `json:"name"`
+	Mountpoint string            `json:"mountpoint"` // 宿主机上的数据目录
+	Labels     map[string]string `json:"labels"`
+	CreatedAt  string            `json:"created_at"`
+	Anonymous  bool              `json:"anonymous"` // -v 只指定了容器中的路径，由 dockergsh 生成的 volume
+}
+
+// Create 创建命名 volume，已经存在时返回原来的 volume
+func Create(name string, labels map[string]string) (*Volume, error) {
+	return create(name, labels, false)
+}
+
+func create(name string, labels map[string]string, anonymous bool) (*Volume, error) {
+	if !nameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid volume name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	if volume, err := Get(name); err == nil {
+		return volume, nil
+	}
+
+	volumeDir := filepath.Join(DefaultVolumeRoot, name)
+	volume := &Volume{
+		Name:       name,
+		Mountpoint: filepath.Join(volumeDir, dataDir),
+		Labels:     labels,
+		CreatedAt:  time.Now().Format("2006-01-02 15:04:05"),
+		Anonymous:  anonymous,
+	}
+	if err := os.MkdirAll(volume.Mountpoint, 0755); err != nil {
+		return nil, fmt.Errorf("mkdir %s error %v", volume.Mountpoint, err)
+	}
+	content, err := json.Marshal(volume)
+	if err != nil {
+		return nil, err
+	}
+	// 先写临时文件再 rename，并发创建同名 volume 时读到的总是完整的配置
+	configPath := filepath.Join(volumeDir, configName)
+	tmpPath := configPath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// Get 读取命名 volume 的信息
+func Get(name string) (*Volume, error) {
+	content, err := os.ReadFile(filepath.Join(DefaultVolumeRoot, name, configName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no such volume: %s", name)
+		}
+		return nil, err
+	}
+	var volume Volume
+	if err := json.Unmarshal(content, &volume); err != nil {
+		return nil, err
+	}
+	return &volume, nil
+}
+
+// List 列出所有命名 volume，按名字排序
+func List() ([]*Volume, error) {
+	entries, err := os.ReadDir(DefaultVolumeRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var volumes []*Volume
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		volume, err := Get(entry.Name())
+		if err != nil {
+			continue
+		}
+		volumes = append(volumes, volume)
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+// Remove 删除命名 volume 及其中的数据，调用方需要保证 volume 没有被容器使用
+func Remove(name string) error {
+	if _, err := Get(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(DefaultVolumeRoot, name))
+}
+
+/*
+ResolveSpec 解析 -v 参数：
+- hostPath:containerPath，宿主机路径为绝对路径，原样返回
+- name:containerPath，使用命名 volume，不存在时自动创建，返回 volume 数据目录:containerPath
+- containerPath，创建一个随机名字的匿名 volume，--rm 的容器删除时一起删除
+*/
+func ResolveSpec(spec string) (string, error) {
+	if spec == "" {
+		return "", nil
+	}
+	if !strings.Contains(spec, ":") && filepath.IsAbs(spec) {
+		volume, err := create(anonymousName(), nil, true)
+		if err != nil {
+			return "", err
+		}
+		return volume.Mountpoint + ":" + spec, nil
+	}
+	source, target, ok := strings.Cut(spec, ":")
+	if !ok || source == "" || target == "" {
+		return "", fmt.Errorf("invalid volume %q, it should be hostPath:containerPath, name:containerPath or containerPath", spec)
+	}
+	if filepath.IsAbs(source) {
+		return spec, nil
+	}
+	volume, err := Create(source, nil)
+	if err != nil {
+		return "", err
+	}
+	return volume.Mountpoint + ":" + target, nil
+}
+
+// 与 docker 一致，匿名 volume 的名字为 64 位随机十六进制字符串
+func anonymousName() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Of 返回容器的 -v 参数使用的 volume，挂载的是宿主机路径时返回 nil
+func Of(spec string) *Volume {
+	if spec == "" {
+		return nil
+	}
+	volumes, err := List()
+	if err != nil {
+		return nil
+	}
+	for _, volume := range volumes {
+		if volume.InUse(spec) {
+			return volume
+		}
+	}
+	return nil
+}
+
+// AnonymousOf 返回容器的 -v 参数使用的匿名 volume，没有使用匿名 volume 时返回 nil
+func AnonymousOf(spec string) *Volume {
+	if volume := Of(spec); volume != nil && volume.Anonymous {
+		return volume
+	}
+	return nil
+}
+
+// InUse 判断容器的 -v 参数是否使用了该 volume
+func (v *Volume) InUse(spec string) bool {
+	source, _, _ := strings.Cut(spec, ":")
+	return source == v.Mountpoint
+}